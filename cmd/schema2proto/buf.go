@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/adimarco/bifrost/pkg/converter"
+)
+
+// bufConfigFile is the buf module configuration written beside the output
+// with -buf
+const bufConfigFile = "buf.yaml"
+
+// versionSuffix matches the package components buf's PACKAGE_VERSION_SUFFIX
+// rule accepts, such as v1, v1beta1 or v2test
+var versionSuffix = regexp.MustCompile(`^v\d+((alpha|beta)\d*|test\w*)?$`)
+
+// bufConfig returns a minimal buf.yaml for a module holding the generated
+// files. Generated output passes the STANDARD lint category, except for the
+// rules that depend on where the files live and what their packages are
+// called: the files sit at the module root, which PACKAGE_DIRECTORY_MATCH
+// rejects for any package; PACKAGE_VERSION_SUFFIX is only kept when every
+// package ends in a version such as v1; and DIRECTORY_SAME_PACKAGE is only
+// kept when all files share a package, which -package-per-definition breaks.
+func bufConfig(files map[string]*converter.ProtoFile) string {
+	packages := make(map[string]bool)
+	versioned := true
+	for _, file := range files {
+		packages[file.Package] = true
+		last := file.Package[strings.LastIndex(file.Package, ".")+1:]
+		if !versionSuffix.MatchString(last) {
+			versioned = false
+		}
+	}
+	except := []string{"PACKAGE_DIRECTORY_MATCH"}
+	if !versioned {
+		except = append(except, "PACKAGE_VERSION_SUFFIX")
+	}
+	if len(packages) > 1 {
+		except = append(except, "DIRECTORY_SAME_PACKAGE")
+	}
+	sort.Strings(except)
+
+	var b strings.Builder
+	b.WriteString("# Generated by schema2proto -buf.\n")
+	b.WriteString("version: v2\n")
+	b.WriteString("lint:\n")
+	b.WriteString("  use:\n")
+	b.WriteString("    - STANDARD\n")
+	b.WriteString("  except:\n")
+	for _, rule := range except {
+		fmt.Fprintf(&b, "    - %s\n", rule)
+	}
+	return b.String()
+}
+
+// writeBufConfig writes buf.yaml into dir for the generated files, unless
+// the directory already has one, which is left for the user to maintain
+func writeBufConfig(dir string, files map[string]*converter.ProtoFile) (string, error) {
+	path := filepath.Join(dir, bufConfigFile)
+	if _, err := os.Stat(path); err == nil {
+		return "", nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("checking %s: %v", bufConfigFile, err)
+	}
+	if err := os.WriteFile(path, []byte(bufConfig(files)), 0644); err != nil {
+		return "", fmt.Errorf("writing %s: %v", bufConfigFile, err)
+	}
+	return path, nil
+}
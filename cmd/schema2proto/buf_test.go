@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/adimarco/bifrost/pkg/converter"
+)
+
+func TestBufConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages []string
+		except   string
+	}{
+		{
+			name:     "unversioned package",
+			packages: []string{"schema"},
+			except:   "    - PACKAGE_DIRECTORY_MATCH\n    - PACKAGE_VERSION_SUFFIX\n",
+		},
+		{
+			name:     "versioned package",
+			packages: []string{"acme.orders.v1beta1"},
+			except:   "    - PACKAGE_DIRECTORY_MATCH\n",
+		},
+		{
+			name:     "several packages",
+			packages: []string{"acme.orders.v1", "acme.users.v1"},
+			except:   "    - DIRECTORY_SAME_PACKAGE\n    - PACKAGE_DIRECTORY_MATCH\n",
+		},
+		{
+			name:     "some packages unversioned",
+			packages: []string{"acme.orders.v1", "acme.users"},
+			except:   "    - DIRECTORY_SAME_PACKAGE\n    - PACKAGE_DIRECTORY_MATCH\n    - PACKAGE_VERSION_SUFFIX\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string]*converter.ProtoFile)
+			for _, pkg := range tt.packages {
+				files[pkg+".proto"] = &converter.ProtoFile{Package: pkg}
+			}
+			want := "# Generated by schema2proto -buf.\n" +
+				"version: v2\n" +
+				"lint:\n" +
+				"  use:\n" +
+				"    - STANDARD\n" +
+				"  except:\n" + tt.except
+			assert.Equal(t, want, bufConfig(files))
+		})
+	}
+}
+
+func TestWriteBufConfig(t *testing.T) {
+	job := newTestJob(t, `{"type": "object", "properties": {"a": {"type": "string"}}}`)
+	job.buf = true
+	require.NoError(t, job.run())
+
+	path := filepath.Join(filepath.Dir(job.outputFile), bufConfigFile)
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, bufConfig(map[string]*converter.ProtoFile{"schema.proto": {Package: "schema"}}), string(written))
+	assert.Contains(t, job.outputs, path)
+
+	// An existing buf.yaml belongs to the user and is left as it is
+	custom := "version: v2\nlint:\n  use:\n    - MINIMAL\n"
+	require.NoError(t, os.WriteFile(path, []byte(custom), 0644))
+	require.NoError(t, job.run())
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, custom, string(current))
+
+	got, err := writeBufConfig(filepath.Dir(path), nil)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
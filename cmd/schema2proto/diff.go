@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/adimarco/bifrost/pkg/converter"
+)
+
+// diff generates the output in memory and prints a unified diff against the
+// files currently on disk, treating missing files as empty. It reports whether
+// any output differs, and when write is set overwrites the files that do.
+func (j *generateJob) diff(w io.Writer, write bool) (bool, error) {
+	outputs, err := j.generate()
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, path := range sortedPaths(outputs) {
+		generated := converter.RenderProto(outputs[path])
+		current, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("reading existing output: %v", err)
+		}
+		if string(current) == generated {
+			continue
+		}
+		changed = true
+
+		// SplitLines turns empty input into a single blank line
+		var before []string
+		if len(current) > 0 {
+			before = difflib.SplitLines(string(current))
+		}
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        before,
+			B:        difflib.SplitLines(generated),
+			FromFile: path,
+			ToFile:   path + " (generated)",
+			Context:  3,
+		})
+		if err != nil {
+			return false, fmt.Errorf("diffing %s: %v", path, err)
+		}
+		fmt.Fprint(w, text)
+
+		if write {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return false, fmt.Errorf("creating output directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(generated), 0644); err != nil {
+				return false, fmt.Errorf("writing proto file: %v", err)
+			}
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/adimarco/bifrost/pkg/converter"
+)
+
+// newTestJob returns a job converting schema, written to schema.json in a
+// temporary directory, into schema.proto beside it
+func newTestJob(t *testing.T, schema string) *generateJob {
+	t.Helper()
+	dir := t.TempDir()
+	input := filepath.Join(dir, "schema.json")
+	require.NoError(t, os.WriteFile(input, []byte(schema), 0644))
+
+	opts := converter.DefaultOptions()
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &generateJob{
+		inputFile:   input,
+		inputFormat: "auto",
+		outputFile:  filepath.Join(dir, "schema.proto"),
+		opts:        opts,
+	}
+}
+
+func TestDiff(t *testing.T) {
+	job := newTestJob(t, `{"type": "object", "properties": {"a": {"type": "string"}}}`)
+	require.NoError(t, job.run())
+	original, err := os.ReadFile(job.outputFile)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	changed, err := job.diff(&out, false)
+	require.NoError(t, err)
+	assert.False(t, changed, "unchanged output")
+	assert.Empty(t, out.String())
+
+	// A difference is reported, which makes -diff exit 1, and the file is
+	// left alone without -write
+	require.NoError(t, os.WriteFile(job.inputFile, []byte(`{"type": "object", "properties": {"b": {"type": "string"}}}`), 0644))
+	changed, err = job.diff(&out, false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, out.String(), "--- "+job.outputFile+"\n")
+	assert.Contains(t, out.String(), "+++ "+job.outputFile+" (generated)\n")
+	assert.Contains(t, out.String(), "-  string a = 1;\n")
+	assert.Contains(t, out.String(), "+  string b = 1;\n")
+	current, err := os.ReadFile(job.outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(current))
+}
+
+func TestDiffMissingOutput(t *testing.T) {
+	job := newTestJob(t, `{"type": "object", "properties": {"a": {"type": "string"}}}`)
+
+	var out bytes.Buffer
+	changed, err := job.diff(&out, false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, out.String(), "@@ -0,0 +1,")
+	assert.Contains(t, out.String(), "+syntax = \"proto3\";\n")
+	assert.Contains(t, out.String(), "+  string a = 1;\n")
+	assert.NoFileExists(t, job.outputFile)
+}
+
+func TestDiffWrite(t *testing.T) {
+	job := newTestJob(t, `{"type": "object", "properties": {"a": {"type": "string"}}}`)
+	job.outputFile = filepath.Join(filepath.Dir(job.inputFile), "gen", "schema.proto")
+
+	var out bytes.Buffer
+	changed, err := job.diff(&out, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, out.String(), "+  string a = 1;\n")
+
+	// The diff is printed against what was there before, and the generated
+	// output is written afterwards, so a second run finds nothing to change
+	written, err := os.ReadFile(job.outputFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(written), "string a = 1;")
+	out.Reset()
+	changed, err = job.diff(&out, true)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// defaultHeader is the -header-comment banner, following the Go convention
+// for marking generated files. It leaves out {version}, which changes from
+// one build to the next, so regenerating with another build gives the same
+// output and -diff stays quiet.
+const defaultHeader = "Code generated by schema2proto from {input}. DO NOT EDIT."
+
+// header returns the comment placed at the top of the generated files, or ""
+// when it is disabled
+func (j *generateJob) header(now time.Time) string {
+	if j.headerComment == "" {
+		return ""
+	}
+	// Only the base name, so the output doesn't depend on where the input
+	// was read from
+	input := filepath.Base(j.inputFile)
+	if j.inputFile == stdinPath {
+		input = "stdin"
+	}
+	header := strings.NewReplacer("{input}", input, "{version}", toolVersion()).Replace(j.headerComment)
+	if j.headerTimestamp {
+		header += "\nGenerated at " + now.UTC().Format(time.RFC3339) + "."
+	}
+	return header
+}
+
+// toolVersion returns " " followed by the module version schema2proto was
+// installed at, or "" for development builds
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" {
+		return ""
+	}
+	return " " + info.Main.Version
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		job  generateJob
+		want string
+	}{
+		{
+			name: "input path reduced to its base name",
+			job:  generateJob{inputFile: "/tmp/specs/order.json", headerComment: defaultHeader},
+			want: "Code generated by schema2proto from order.json. DO NOT EDIT.",
+		},
+		{
+			name: "relative input path",
+			job:  generateJob{inputFile: "schemas/order.yaml", headerComment: defaultHeader},
+			want: "Code generated by schema2proto from order.yaml. DO NOT EDIT.",
+		},
+		{
+			name: "stdin",
+			job:  generateJob{inputFile: stdinPath, headerComment: defaultHeader},
+			want: "Code generated by schema2proto from stdin. DO NOT EDIT.",
+		},
+		{
+			name: "timestamp",
+			job:  generateJob{inputFile: "order.json", headerComment: "From {input}.", headerTimestamp: true},
+			want: "From order.json.\nGenerated at 2024-05-01T12:00:00Z.",
+		},
+		{
+			name: "disabled",
+			job:  generateJob{inputFile: "order.json", headerTimestamp: true},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.job.header(now))
+		})
+	}
+}
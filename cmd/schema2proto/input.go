@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stdinPath is the -input value that reads the schema from standard input
+const stdinPath = "-"
+
+// readInput reads the input schema and returns it as JSON. The format is
+// taken from -input-format; with "auto" it follows the file extension, and
+// input without a .json, .yaml or .yml extension, such as stdin, is parsed as
+// JSON when possible and as YAML otherwise.
+func (j *generateJob) readInput() ([]byte, error) {
+	var data []byte
+	var err error
+	if j.inputFile == stdinPath {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(j.inputFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading schema file: %v", err)
+	}
+
+	format := j.inputFormat
+	if format == "" || format == "auto" {
+		switch strings.ToLower(filepath.Ext(j.inputFile)) {
+		case ".json":
+			format = "json"
+		case ".yaml", ".yml":
+			format = "yaml"
+		}
+	}
+
+	switch format {
+	case "json":
+		return data, nil
+	case "yaml":
+		out, err := yamlToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing YAML schema: %v", err)
+		}
+		return out, nil
+	case "", "auto":
+		if json.Valid(data) {
+			return data, nil
+		}
+		out, yamlErr := yamlToJSON(data)
+		if yamlErr != nil {
+			var v interface{}
+			jsonErr := json.Unmarshal(data, &v)
+			return nil, fmt.Errorf("input is neither valid JSON (%v) nor valid YAML (%v)", jsonErr, yamlErr)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported input format %q; use json, yaml or auto", format)
+	}
+}
+
+// yamlToJSON converts a YAML document into the equivalent JSON. The document
+// must be a mapping, as JSON Schemas and OpenAPI documents are.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	obj, ok := jsonValue(doc).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("document is not a mapping")
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(obj); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonValue converts decoded YAML into values encoding/json can marshal,
+// turning mappings with non-string keys into string-keyed objects
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonValue(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			obj[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return obj
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // empty reads from stdin
+		format  string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "json extension",
+			file:    "schema.json",
+			content: `{"type": "string"}`,
+			want:    `{"type": "string"}`,
+		},
+		{
+			name:    "yaml extension",
+			file:    "schema.yaml",
+			content: "type: object\nproperties:\n  a: {type: string}\n",
+			want:    `{"properties": {"a": {"type": "string"}}, "type": "object"}`,
+		},
+		{
+			name:    "yml extension",
+			file:    "schema.YML",
+			content: "type: string\n",
+			want:    `{"type": "string"}`,
+		},
+		{
+			name:    "yaml mapping with integer keys",
+			file:    "schema.yaml",
+			content: "responses:\n  200: {description: OK}\n",
+			want:    `{"responses": {"200": {"description": "OK"}}}`,
+		},
+		{
+			name:    "extensionless json",
+			file:    "schema",
+			content: `{"type": "string"}`,
+			want:    `{"type": "string"}`,
+		},
+		{
+			name:    "extensionless yaml",
+			file:    "schema",
+			content: "type: string\n",
+			want:    `{"type": "string"}`,
+		},
+		{
+			name:    "stdin json",
+			content: `{"type": "string"}`,
+			want:    `{"type": "string"}`,
+		},
+		{
+			name:    "stdin yaml",
+			content: "type: string\n",
+			want:    `{"type": "string"}`,
+		},
+		{
+			name:    "format overrides extension",
+			file:    "schema.json",
+			format:  "yaml",
+			content: "type: string\n",
+			want:    `{"type": "string"}`,
+		},
+		{
+			name:    "top-level yaml list",
+			file:    "schema.yaml",
+			content: "- type: string\n",
+			wantErr: "parsing YAML schema: document is not a mapping",
+		},
+		{
+			name:    "extensionless top-level yaml list",
+			file:    "schema",
+			content: "- type: string\n",
+			wantErr: "input is neither valid JSON",
+		},
+		{
+			name:    "unsupported format",
+			file:    "schema.json",
+			format:  "toml",
+			content: `{"type": "string"}`,
+			wantErr: `unsupported input format "toml"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := tt.file
+			if name == "" {
+				name = "stdin"
+			}
+			path := filepath.Join(t.TempDir(), name)
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+
+			job := &generateJob{inputFile: path, inputFormat: tt.format}
+			if tt.file == "" {
+				f, err := os.Open(path)
+				require.NoError(t, err)
+				defer f.Close()
+				stdin := os.Stdin
+				os.Stdin = f
+				defer func() { os.Stdin = stdin }()
+				job.inputFile = stdinPath
+			}
+
+			got, err := job.readInput()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestReadInputMissingFile(t *testing.T) {
+	job := &generateJob{inputFile: filepath.Join(t.TempDir(), "missing.json")}
+	_, err := job.readInput()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reading schema file")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// lineHandler is a slog.Handler writing one "level: path: message" line per
+// record, matching the converter's default "warning: ..." output
+type lineHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Level
+	attrs []slog.Attr
+}
+
+// newLogger returns a logger writing records at level and above to w
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(&lineHandler{mu: &sync.Mutex{}, w: w, level: level})
+}
+
+func (h *lineHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *lineHandler) Handle(_ context.Context, r slog.Record) error {
+	var line strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		line.WriteString("error: ")
+	case r.Level >= slog.LevelWarn:
+		line.WriteString("warning: ")
+	case r.Level >= slog.LevelInfo:
+	default:
+		line.WriteString("debug: ")
+	}
+	var extra []string
+	addAttr := func(a slog.Attr) bool {
+		if a.Key == "path" {
+			fmt.Fprintf(&line, "%s: ", a.Value)
+		} else {
+			extra = append(extra, a.String())
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		addAttr(a)
+	}
+	r.Attrs(addAttr)
+	line.WriteString(r.Message)
+	for _, e := range extra {
+		line.WriteString(" " + e)
+	}
+	line.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line.String())
+	return err
+}
+
+func (h *lineHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &next
+}
+
+// WithGroup is a no-op: the converter doesn't group its attributes
+func (h *lineHandler) WithGroup(string) slog.Handler {
+	return h
+}
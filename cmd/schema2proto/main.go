@@ -1,34 +1,123 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/adimarco/bifrost/pkg/converter"
 )
 
 func main() {
-	inputFile := flag.String("input", "", "Input JSON Schema file")
+	inputFile := flag.String("input", "", "Input JSON Schema file, or - to read from stdin")
+	inputFormat := flag.String("input-format", "auto", "Input format: json, yaml, or auto to use the file extension and otherwise try JSON then YAML")
 	outputFile := flag.String("output", "", "Output .proto file")
 	packageName := flag.String("package", "schema", "Package name for the generated proto file")
+	syntax := flag.String("syntax", "proto3", "Proto syntax to generate: proto3 or proto2")
+	rootName := flag.String("root-name", "Root", "Name of the message generated for the schema's top-level properties")
+	titleRootName := flag.Bool("title-root-name", false, "Name the root message after the schema's title, e.g. PurchaseOrder for \"Purchase Order\", instead of -root-name")
 	goPackage := flag.String("go-package", "", "Go package path (e.g., github.com/user/project)")
-	imports := flag.String("imports", "google/protobuf/any.proto", "Comma-separated list of proto imports")
+	fileOptions := flag.String("options", "", "Comma-separated list of file options in format 'name=value' (e.g., 'java_package=com.example,optimize_for=SPEED')")
+	imports := flag.String("imports", "", "Comma-separated list of additional proto imports")
+	openAPI := flag.Bool("openapi", false, "Treat the input as an OpenAPI 3 document and convert its components.schemas")
+	validate := flag.Bool("validate", false, "Check the generated proto for structural errors before writing it")
+	split := flag.Bool("split", false, "Write one .proto file per top-level definition into the -output directory")
+	packagePerDefinition := flag.Bool("package-per-definition", false, "With -split, put each file in its own package nested under -package, e.g. schema.order")
+	watch := flag.Bool("watch", false, "Watch the input for changes and regenerate the output on every save")
+	watchDir := flag.String("watch-dir", "", "Directory to watch instead of the input file when using -watch")
+	diff := flag.Bool("diff", false, "Print a unified diff against the existing output instead of writing it; exits 1 if they differ")
+	write := flag.Bool("write", false, "With -diff, also write the output after printing the diff")
+	deriveNaming := flag.Bool("derive-naming", false, "Derive the package and, when -output is omitted, the output file name from the schema's $id")
+	draft := flag.String("draft", "", "JSON Schema draft to interpret the input as, overriding its $schema: draft-04, draft-06, draft-07, 2019-09 or 2020-12")
+	integerType := flag.String("integer-type", "", "Proto type for JSON Schema integers instead of int32 (e.g., int64)")
+	numberType := flag.String("number-type", "", "Proto type for JSON Schema numbers instead of double (e.g., float)")
+	freeFormType := flag.String("free-form-type", "any", "Type for properties that accept any JSON value: any, value (google.protobuf.Value) or struct (google.protobuf.Struct)")
+	splitReadWrite := flag.Bool("split-read-write", false, "Generate <Name>Request and <Name>Response messages for schemas with readOnly or writeOnly properties")
+	allOfStyle := flag.String("allof-style", "flatten", "How allOf members that $ref an object are converted: flatten merges their properties, compose embeds the referenced message as a base field")
+	dedupe := flag.Bool("dedupe", false, "Merge structurally identical inline messages into one")
+	wrapRootRef := flag.Bool("wrap-root-ref", false, "Wrap a root $ref in a message with a value field instead of treating it as an alias")
+	nestEnums := flag.Bool("nest-enums", false, "Declare each enum used by only one message inside that message")
+	singleMessage := flag.Bool("single-message", false, "Generate a single root message with every type it uses nested within it, resolving and inlining $refs")
+	fieldNumberStep := flag.Int("field-number-step", 1, "Step between the field numbers assigned to a message's fields, e.g. 10 numbers them 1, 11, 21")
+	enumValueComments := flag.Bool("enum-value-comments", false, "Note the original string after enum values whose names don't spell it verbatim")
+	sortEnumValues := flag.Bool("sort-enum-values", false, "Number enum values in sorted order instead of schema order")
+	contentMediaTypes := flag.Bool("content-media-types", false, "Choose the proto type of string properties from their contentMediaType, e.g. google.protobuf.Struct for application/json and bytes for application/octet-stream")
+	aliasEnumCollisions := flag.Bool("alias-enum-collisions", false, "Give enum values whose names collide, such as in-progress and in_progress, the same number under allow_alias instead of numbers of their own")
+	preset := flag.String("preset", "", "Start from a named set of options: buf, grpc or openapi. Other flags are applied on top")
+	buf := flag.Bool("buf", false, "Generate buf-lint-clean output (snake_case fields, PascalCase messages, UNSPECIFIED enum zero values) and write a buf.yaml beside it if there is none")
+	preserveFieldNames := flag.Bool("preserve-field-names", false, "Keep property names that are already valid proto identifiers, such as createdAt, as field names")
+	headerComment := flag.String("header-comment", defaultHeader, "Comment placed at the top of generated files; {input} and {version} are replaced by the input file name and the schema2proto version. Pass an empty value to omit it")
+	headerTimestamp := flag.Bool("header-timestamp", false, "Add the generation time to the header comment; the output then differs on every run, so -diff always reports a change")
+	sourceComments := flag.Bool("source-comments", false, "Add a source: comment to every message and enum naming the schema it was generated from, e.g. common.json#/definitions/Money")
+	vendorExtensions := flag.Bool("vendor-extensions", false, "Turn the x-proto-message-options of definitions into message options")
+	vendorExtensionComments := flag.Bool("vendor-extension-comments", false, "With -vendor-extensions, document other x- keys of definitions as comments instead of dropping them")
+	maxDepth := flag.Int("max-depth", converter.DefaultMaxDepth, "Maximum nesting depth of subschemas; deeper schemas are an error. 0 or less removes the limit")
+	quiet := flag.Bool("quiet", false, "Don't print conversion warnings")
+	verbose := flag.Bool("verbose", false, "Also print progress, such as each definition converted and file written")
 	typeAliases := flag.String("type-aliases", "", "Comma-separated list of type aliases in format 'type=alias' (e.g., 'Requestid=string,RequestId=string')")
 	flag.Parse()
 
-	if *inputFile == "" || *outputFile == "" {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if *inputFile == "" || (*outputFile == "" && !(*deriveNaming && !*split)) {
 		fmt.Println("Please provide both input and output file paths")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if *packagePerDefinition && !*split {
+		fmt.Println("-package-per-definition requires -split")
+		os.Exit(1)
+	}
+	if *vendorExtensionComments && !*vendorExtensions {
+		fmt.Println("-vendor-extension-comments requires -vendor-extensions")
+		os.Exit(1)
+	}
+	if *singleMessage && *split {
+		fmt.Println("-single-message can't be combined with -split")
+		os.Exit(1)
+	}
+	if *buf && (*preserveFieldNames || *aliasEnumCollisions) {
+		fmt.Println("-buf can't be combined with -preserve-field-names or -alias-enum-collisions")
+		os.Exit(1)
+	}
+	base := converter.DefaultOptions()
+	if *preset != "" {
+		var err error
+		if base, err = converter.Preset(*preset); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if base.SnakeCaseFieldNames && (*preserveFieldNames || *aliasEnumCollisions) {
+			fmt.Printf("-preset %s can't be combined with -preserve-field-names or -alias-enum-collisions\n", *preset)
+			os.Exit(1)
+		}
+	}
+	if *quiet && *verbose {
+		fmt.Println("-quiet can't be combined with -verbose")
+		os.Exit(1)
+	}
+	level := slog.LevelWarn
+	if *quiet {
+		level = slog.LevelError
+	} else if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := newLogger(os.Stderr, level)
+
 	// Parse imports
-	importList := strings.Split(*imports, ",")
-	for i, imp := range importList {
-		importList[i] = strings.TrimSpace(imp)
+	var importList []string
+	if *imports != "" {
+		for _, imp := range strings.Split(*imports, ",") {
+			importList = append(importList, strings.TrimSpace(imp))
+		}
 	}
 
 	// Parse type aliases
@@ -43,59 +132,250 @@ func main() {
 		}
 	}
 
-	// Read and parse the JSON Schema
-	schemaData, err := os.ReadFile(*inputFile)
-	if err != nil {
-		fmt.Printf("Error reading schema file: %v\n", err)
-		os.Exit(1)
+	// Parse file options
+	var fileOptionList []converter.FileOption
+	if *fileOptions != "" {
+		for _, opt := range strings.Split(*fileOptions, ",") {
+			parts := strings.SplitN(strings.TrimSpace(opt), "=", 2)
+			if len(parts) == 2 {
+				fileOptionList = append(fileOptionList, converter.FileOption{
+					Name:  strings.TrimSpace(parts[0]),
+					Value: strings.TrimSpace(parts[1]),
+				})
+			}
+		}
+	}
+
+	// Source comments name the input file, unless the schema has an $id
+	sourceName := ""
+	if *inputFile != stdinPath {
+		sourceName = filepath.Base(*inputFile)
+	}
+
+	// A zero MaxDepth means the default limit, so ask for no limit explicitly
+	depthLimit := *maxDepth
+	if depthLimit <= 0 {
+		depthLimit = -1
 	}
 
 	// Create converter options
-	opts := &converter.Options{
-		PackageName:  *packageName,
-		TypeMappings: typeAliasMap,
+	opts := converter.MergeOptions(base, &converter.Options{
+		PackageName:             *packageName,
+		DeriveNamingFromId:      *deriveNaming && !explicit["package"],
+		Syntax:                  *syntax,
+		Draft:                   converter.Draft(*draft),
+		RootMessageName:         *rootName,
+		UseTitleAsRootName:      *titleRootName,
+		TypeMappings:            typeAliasMap,
+		IntegerType:             *integerType,
+		NumberType:              *numberType,
+		FreeFormType:            converter.FreeFormType(*freeFormType),
+		SortEnumValues:          *sortEnumValues,
+		EmitEnumValueComments:   *enumValueComments,
+		FieldNumberStep:         *fieldNumberStep,
+		AliasEnumCollisions:     *aliasEnumCollisions,
+		UseContentMediaTypes:    *contentMediaTypes,
+		PreserveFieldNames:      *preserveFieldNames,
+		SnakeCaseFieldNames:     *buf,
+		PascalCaseDefinitions:   *buf,
+		UnspecifiedEnumZero:     *buf,
+		EmitVendorExtensions:    *vendorExtensions,
+		EmitSourceComments:      *sourceComments,
+		SourceName:              sourceName,
+		VendorExtensionComments: *vendorExtensionComments,
+		WrapRootRef:             *wrapRootRef,
+		Flatten:                 *singleMessage,
+		NestEnums:               *nestEnums,
+		DedupeMessages:          *dedupe,
+		AllOfStyle:              converter.AllOfStyle(*allOfStyle),
+		SplitReadWrite:          *splitReadWrite,
+		PackagePerDefinition:    *packagePerDefinition,
+		MaxDepth:                depthLimit,
+		Logger:                  logger,
+		GoPackage:               *goPackage,
+		FileOptions:             fileOptionList,
+		Imports:                 importList,
+	})
+
+	job := &generateJob{
+		inputFile:       *inputFile,
+		inputFormat:     *inputFormat,
+		outputFile:      *outputFile,
+		openAPI:         *openAPI,
+		validate:        *validate,
+		split:           *split,
+		buf:             *buf,
+		headerComment:   *headerComment,
+		headerTimestamp: *headerTimestamp,
+		opts:            opts,
 	}
 
-	// Convert schema to proto
-	protoContent, err := converter.ConvertJSONSchemaToProto(string(schemaData), opts)
-	if err != nil {
-		fmt.Printf("Error converting schema: %v\n", err)
-		os.Exit(1)
+	if *diff {
+		if *watch {
+			fmt.Println("-diff can't be combined with -watch")
+			os.Exit(1)
+		}
+		changed, err := job.diff(os.Stdout, *write)
+		if err != nil {
+			fmt.Printf("Error %v\n", err)
+			os.Exit(1)
+		}
+		if changed {
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(*outputFile), 0755); err != nil {
-		fmt.Printf("Error creating output directory: %v\n", err)
-		os.Exit(1)
+	if *watch {
+		if *inputFile == stdinPath && *watchDir == "" {
+			fmt.Println("-watch needs an input file or -watch-dir, not stdin")
+			os.Exit(1)
+		}
+		watchPath := *inputFile
+		if *watchDir != "" {
+			watchPath = *watchDir
+		}
+		watchAndGenerate(job, watchPath)
+		return
 	}
 
-	// Write the proto file
-	if err := os.WriteFile(*outputFile, []byte(protoContent), 0644); err != nil {
-		fmt.Printf("Error writing proto file: %v\n", err)
+	if err := job.run(); err != nil {
+		fmt.Printf("Error %v\n", err)
 		os.Exit(1)
 	}
+}
 
-	// If go_package is specified, add it to the proto file
-	if *goPackage != "" {
-		content, err := os.ReadFile(*outputFile)
+// generateJob holds everything needed to regenerate the output file
+type generateJob struct {
+	inputFile   string
+	inputFormat string
+	outputFile  string
+	openAPI     bool
+	validate    bool
+	split       bool
+	// buf writes a buf.yaml beside the output
+	buf bool
+	// headerComment is the banner for the generated files, and
+	// headerTimestamp adds the generation time to it
+	headerComment   string
+	headerTimestamp bool
+	opts            *converter.Options
+	// outputs holds the paths of the files the last run generated, and
+	// buf.yaml with buf, so watching a directory can ignore them
+	outputs []string
+}
+
+// run reads the input schema, converts it and writes the proto file. The
+// output file is left untouched when conversion or validation fails.
+func (j *generateJob) run() error {
+	outputs, err := j.generate()
+	if err != nil {
+		return err
+	}
+	j.outputs = sortedPaths(outputs)
+	for _, path := range sortedPaths(outputs) {
+		// Create output directory if it doesn't exist
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("creating output directory: %v", err)
+		}
+		if err := writeProtoFile(path, outputs[path]); err != nil {
+			return err
+		}
+		j.opts.Logger.Info("wrote " + path)
+	}
+	if j.buf {
+		dir := j.outputFile
+		if !j.split {
+			dir = filepath.Dir(sortedPaths(outputs)[0])
+		}
+		j.outputs = append(j.outputs, filepath.Join(dir, bufConfigFile))
+		path, err := writeBufConfig(dir, outputs)
 		if err != nil {
-			fmt.Printf("Error reading proto file: %v\n", err)
-			os.Exit(1)
+			return err
+		}
+		if path != "" {
+			j.opts.Logger.Info("wrote " + path)
 		}
+	}
+	return nil
+}
+
+// generate reads and converts the input schema, returning the proto files to
+// write keyed by output path
+func (j *generateJob) generate() (map[string]*converter.ProtoFile, error) {
+	// Read and parse the JSON Schema
+	schemaData, err := j.readInput()
+	if err != nil {
+		return nil, err
+	}
+
+	j.opts.FileHeader = j.header(time.Now())
+
+	// Convert schema to proto
+	var protoFile *converter.ProtoFile
+	if j.openAPI {
+		protoFile, err = converter.BuildOpenAPIProtoFile(string(schemaData), j.opts)
+	} else {
+		protoFile, err = converter.BuildProtoFile(string(schemaData), j.opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("converting schema: %v", err)
+	}
 
-		lines := strings.Split(string(content), "\n")
-		var newContent strings.Builder
-		for i, line := range lines {
-			if i == 2 { // After package declaration
-				newContent.WriteString(fmt.Sprintf("option go_package = \"%s\";\n\n", *goPackage))
+	// Validate the generated proto before touching the output file
+	if j.validate {
+		if errs := converter.Validate(converter.RenderProto(protoFile)); len(errs) > 0 {
+			var msg strings.Builder
+			msg.WriteString("validating generated proto:")
+			for _, err := range errs {
+				msg.WriteString("\n  ")
+				msg.WriteString(err.Error())
 			}
-			newContent.WriteString(line)
-			newContent.WriteString("\n")
+			return nil, errors.New(msg.String())
 		}
+	}
 
-		if err := os.WriteFile(*outputFile, []byte(newContent.String()), 0644); err != nil {
-			fmt.Printf("Error writing updated proto file: %v\n", err)
-			os.Exit(1)
+	if !j.split {
+		outputFile := j.outputFile
+		if outputFile == "" {
+			// -derive-naming without -output names the file after the
+			// package derived from the schema's $id
+			outputFile = strings.ReplaceAll(protoFile.Package, ".", "_") + ".proto"
 		}
+		return map[string]*converter.ProtoFile{outputFile: protoFile}, nil
+	}
+
+	// Split mode writes one file per top-level definition into the output
+	// directory
+	outputs := make(map[string]*converter.ProtoFile)
+	for name, file := range converter.SplitProtoFile(protoFile, j.opts) {
+		outputs[filepath.Join(j.outputFile, name)] = file
+	}
+	return outputs, nil
+}
+
+// sortedPaths returns the output paths in a stable order
+func sortedPaths(outputs map[string]*converter.ProtoFile) []string {
+	paths := make([]string, 0, len(outputs))
+	for path := range outputs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// writeProtoFile renders protoFile directly into the file at path
+func writeProtoFile(path string, protoFile *converter.ProtoFile) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating proto file: %v", err)
+	}
+	if err := converter.WriteProtoFile(out, protoFile); err != nil {
+		out.Close()
+		return fmt.Errorf("writing proto file: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("writing proto file: %v", err)
 	}
+	return nil
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// pollInterval is how often the watched path is checked for changes
+	pollInterval = 250 * time.Millisecond
+	// settleDelay is how long the watched path must stay unchanged after a
+	// change before regenerating, so one save that touches the file several
+	// times triggers a single conversion
+	settleDelay = 300 * time.Millisecond
+)
+
+// watchAndGenerate regenerates the output once and then again whenever the
+// watched file or directory changes. Files the job writes are ignored, so
+// output inside a watched directory doesn't trigger another run. Conversion
+// errors are reported and watching continues; it only returns if the process
+// is interrupted.
+func watchAndGenerate(job *generateJob, path string) {
+	fmt.Printf("Watching %s for changes (Ctrl-C to stop)\n", path)
+	regenerate(job)
+
+	last := fingerprint(path, job.outputs)
+	for {
+		time.Sleep(pollInterval)
+		current := fingerprint(path, job.outputs)
+		if current == last {
+			continue
+		}
+
+		// Debounce: wait until the path stops changing
+		for {
+			time.Sleep(settleDelay)
+			settled := fingerprint(path, job.outputs)
+			if settled == current {
+				break
+			}
+			current = settled
+		}
+		last = current
+		regenerate(job)
+	}
+}
+
+// regenerate runs job and prints a timestamped result line
+func regenerate(job *generateJob) {
+	stamp := time.Now().Format("15:04:05")
+	if err := job.run(); err != nil {
+		fmt.Printf("[%s] Error %v\n", stamp, err)
+		return
+	}
+	fmt.Printf("[%s] Wrote %s\n", stamp, job.outputFile)
+}
+
+// fingerprint summarizes the modification state of a file, or of every file
+// directly inside a directory other than those in ignore, so changes can be
+// detected by comparison
+func fingerprint(path string, ignore []string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "missing"
+	}
+	if !info.IsDir() {
+		return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "unreadable"
+	}
+	ignored := make(map[string]bool, len(ignore))
+	for _, p := range ignore {
+		if abs, err := filepath.Abs(p); err == nil {
+			ignored[abs] = true
+		}
+	}
+	var sum string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		file := filepath.Join(path, entry.Name())
+		if abs, err := filepath.Abs(file); err == nil && ignored[abs] {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		sum += fmt.Sprintf("%s:%d:%d;", entry.Name(), info.ModTime().UnixNano(), info.Size())
+	}
+	return sum
+}
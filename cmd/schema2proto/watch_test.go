@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/adimarco/bifrost/pkg/converter"
+)
+
+func TestFingerprintIgnoresOutputs(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "schema.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"type": "object", "properties": {"a": {"type": "string"}}}`), 0644))
+
+	opts := converter.DefaultOptions()
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	job := &generateJob{
+		inputFile:   input,
+		inputFormat: "auto",
+		outputFile:  filepath.Join(dir, "schema.proto"),
+		buf:         true,
+		opts:        opts,
+	}
+	require.NoError(t, job.run())
+	assert.FileExists(t, filepath.Join(dir, bufConfigFile))
+	before := fingerprint(dir, job.outputs)
+
+	// Regenerating rewrites the output inside the watched directory, which
+	// mustn't count as a change
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, bufConfigFile), later, later))
+	require.NoError(t, job.run())
+	require.NoError(t, os.Chtimes(job.outputFile, later, later))
+	assert.Equal(t, before, fingerprint(dir, job.outputs))
+
+	require.NoError(t, os.WriteFile(input, []byte(`{"type": "object", "properties": {"b": {"type": "string"}}}`), 0644))
+	require.NoError(t, os.Chtimes(input, later, later))
+	assert.NotEqual(t, before, fingerprint(dir, job.outputs))
+}
@@ -0,0 +1,248 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// flattenAllOf merges the members of an allOf into a single schema, so that
+// {"allOf": [{"$ref": "#/definitions/Base"}, {"properties": {...}}]} becomes
+// one message with the properties of both. Members that are local $refs are
+// merged by their target. Keywords of the schema itself take precedence over
+// those of its members, and earlier members over later ones, except that
+// properties are combined and required lists are unioned. A merged schema
+// with properties but no type is an object. Schemas without allOf are
+// returned unchanged.
+//
+// With AllOfCompose, $ref members pointing at object schemas are left out of
+// the merge and recorded as bases of the schema at path instead, which
+// collectFields embeds as fields; a schema with bases is an object even
+// without properties of its own.
+func (c *conversion) flattenAllOf(name string, propMap map[string]interface{}, path string) (map[string]interface{}, error) {
+	if _, ok := propMap["allOf"]; !ok {
+		return propMap, nil
+	}
+	if c.opts.AllOfStyle == AllOfCompose {
+		var err error
+		if propMap, err = c.composeAllOf(propMap, path); err != nil {
+			return nil, err
+		}
+	}
+	merged, err := c.mergeAllOf(name, propMap, path, map[string]bool{path: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(c.allOfBases[path]) > 0 {
+		if _, ok := merged["properties"]; !ok {
+			merged["properties"] = map[string]interface{}{}
+		}
+	}
+	if _, ok := merged["type"]; !ok {
+		if _, ok := merged["properties"]; ok {
+			merged["type"] = "object"
+		}
+	}
+	return merged, nil
+}
+
+// composeAllOf records the allOf members of the schema at path that $ref an
+// object schema as its bases, converting the referenced types, and returns
+// the schema with the remaining members
+func (c *conversion) composeAllOf(propMap map[string]interface{}, path string) (map[string]interface{}, error) {
+	members, ok := propMap["allOf"].([]interface{})
+	if !ok {
+		return propMap, nil
+	}
+	var rest []interface{}
+	var bases []fieldType
+	for i, member := range members {
+		memberPath := pointerJoin(path, "allOf", strconv.Itoa(i))
+		memberMap, _ := member.(map[string]interface{})
+		ref, ok := memberMap["$ref"].(string)
+		if !ok {
+			rest = append(rest, member)
+			continue
+		}
+		target, _, err := c.lookupRef(ref, memberPath)
+		if err != nil {
+			return nil, err
+		}
+		if targetMap, ok := target.(map[string]interface{}); !ok || !isObjectSchema(targetMap) {
+			rest = append(rest, member)
+			continue
+		}
+		ft, err := c.resolveRef(ref, memberPath)
+		if err != nil {
+			return nil, err
+		}
+		if ft.name == "" || ft.repeated || len(ft.oneof) > 0 || strings.HasPrefix(ft.name, "map<") {
+			rest = append(rest, member)
+			continue
+		}
+		bases = append(bases, ft)
+	}
+	c.allOfBases[path] = bases
+	composed := make(map[string]interface{}, len(propMap))
+	for k, v := range propMap {
+		composed[k] = v
+	}
+	composed["allOf"] = rest
+	return composed, nil
+}
+
+// isObjectSchema reports whether a schema describes an object with
+// properties, directly or through an allOf, which converts to a message
+func isObjectSchema(schema map[string]interface{}) bool {
+	if _, ok := schema["properties"]; ok {
+		return true
+	}
+	if _, ok := schema["allOf"]; ok {
+		return true
+	}
+	return schema["type"] == "object" && !isMapObject(schema)
+}
+
+// baseFields returns the fields embedding the allOf bases recorded for the
+// schema at path, numbered from numbers ahead of its properties. A single
+// base is named base and several are named base_<type>, with a number
+// appended should a property of the message take the name.
+func (c *conversion) baseFields(path string, props []string, numbers *fieldNumberAllocator) []*Field {
+	bases := c.allOfBases[path]
+	if len(bases) == 0 {
+		return nil
+	}
+	taken := make(map[string]bool, len(props))
+	for _, prop := range props {
+		taken[c.fieldName(prop)] = true
+	}
+	fields := make([]*Field, 0, len(bases))
+	for _, base := range bases {
+		name := "base"
+		if len(bases) > 1 {
+			name += "_" + toSnakeCaseFieldName(base.name)
+		}
+		for n, stem := 2, name; taken[name]; n++ {
+			name = stem + strconv.Itoa(n)
+		}
+		taken[name] = true
+		fields = append(fields, &Field{Name: name, Type: base.name, Number: numbers.allocate()})
+	}
+	return fields
+}
+
+// mergeAllOf does the work of flattenAllOf. visiting holds the $ref targets
+// being merged, to detect cycles.
+func (c *conversion) mergeAllOf(name string, propMap map[string]interface{}, path string, visiting map[string]bool) (map[string]interface{}, error) {
+	merged := make(map[string]interface{}, len(propMap))
+	for k, v := range propMap {
+		if k != "allOf" {
+			merged[k] = v
+		}
+	}
+	members, ok := propMap["allOf"].([]interface{})
+	if !ok {
+		if _, present := propMap["allOf"]; present {
+			return nil, &PathError{Path: pointerJoin(path, "allOf"), Err: fmt.Errorf("allOf for %s must be an array", name)}
+		}
+		return merged, nil
+	}
+
+	for i, member := range members {
+		memberPath := pointerJoin(path, "allOf", strconv.Itoa(i))
+		if accept, ok := member.(bool); ok {
+			if !accept {
+				c.warnf(memberPath, WarnFalseSchema, "allOf for %s has a false member, which no value matches; ignoring it", name)
+			}
+			continue
+		}
+		memberMap, ok := member.(map[string]interface{})
+		if !ok {
+			return nil, &PathError{Path: memberPath, Err: fmt.Errorf("invalid allOf member for %s", name)}
+		}
+
+		if ref, ok := memberMap["$ref"].(string); ok {
+			target, pointer, err := c.lookupRef(ref, memberPath)
+			if err != nil {
+				return nil, err
+			}
+			if visiting[pointer] {
+				return nil, &PathError{Path: pointerJoin(memberPath, "$ref"), Err: fmt.Errorf("circular $ref %q", ref)}
+			}
+			targetMap, ok := target.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			visiting[pointer] = true
+			resolved, err := c.mergeAllOf(name, targetMap, pointer, visiting)
+			delete(visiting, pointer)
+			if err != nil {
+				return nil, err
+			}
+			// Keywords beside the $ref refine its target
+			for k, v := range memberMap {
+				if k != "$ref" {
+					resolved[k] = v
+				}
+			}
+			memberMap = resolved
+		} else {
+			var err error
+			memberMap, err = c.mergeAllOf(name, memberMap, memberPath, visiting)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for k, v := range memberMap {
+			switch k {
+			case "properties":
+				props, _ := merged[k].(map[string]interface{})
+				combined := make(map[string]interface{}, len(props))
+				if memberProps, ok := v.(map[string]interface{}); ok {
+					for pk, pv := range memberProps {
+						combined[pk] = pv
+					}
+				}
+				for pk, pv := range props {
+					combined[pk] = pv
+				}
+				merged[k] = combined
+			case "required":
+				existing, _ := merged[k].([]interface{})
+				merged[k] = unionValues(existing, v)
+			case "type":
+				if t, ok := merged[k]; ok && fmt.Sprint(t) != fmt.Sprint(v) {
+					c.warnf(memberPath, WarnConflictingTypes, "allOf for %s combines conflicting types %v and %v; using %v", name, t, v, t)
+					continue
+				}
+				merged[k] = v
+			default:
+				if _, ok := merged[k]; !ok {
+					merged[k] = v
+				}
+			}
+		}
+	}
+	return merged, nil
+}
+
+// unionValues appends the entries of more, if it is an array, that aren't
+// already in values
+func unionValues(values []interface{}, more interface{}) []interface{} {
+	out := append([]interface{}(nil), values...)
+	extra, _ := more.([]interface{})
+	for _, v := range extra {
+		seen := false
+		for _, existing := range out {
+			if existing == v {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,173 @@
+package converter
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllOf(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"pet": {"$ref": "#/definitions/Dog"},
+			"tags": {"allOf": [{"type": "array", "items": {"type": "string"}}, {"maxItems": 3}]},
+			"size": {"allOf": [{"type": "integer"}, {"minimum": 1}], "description": "Shoe size"}
+		},
+		"definitions": {
+			"Animal": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {"name": {"type": "string"}, "legs": {"type": "integer"}}
+			},
+			"Dog": {
+				"description": "A good dog",
+				"allOf": [
+					{"$ref": "#/definitions/Animal"},
+					{"properties": {"breed": {"type": "string"}, "toys": {"type": "array", "items": {"type": "string"}}}, "required": ["breed"]}
+				]
+			}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	for _, want := range []string{
+		"  Dog pet = 1;\n  // Shoe size\n  int32 size = 2;\n  repeated string tags = 3;",
+		"// A good dog\nmessage Dog {\n  string breed = 1;\n  int32 legs = 2;\n  string name = 3;\n  repeated string toys = 4;\n}",
+		"message Animal {\n  int32 legs = 1;\n  string name = 2;\n}",
+	} {
+		assert.Contains(t, got, want)
+	}
+	assert.Empty(t, Validate(got))
+}
+
+func TestAllOfStyle(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {"pet": {"$ref": "#/definitions/Dog"}},
+		"definitions": {
+			"Animal": {"type": "object", "properties": {"name": {"type": "string"}}},
+			"Pet": {"type": "object", "properties": {"owner": {"type": "string"}}},
+			"Dog": {
+				"allOf": [
+					{"$ref": "#/definitions/Animal"},
+					{"properties": {"breed": {"type": "string"}}, "required": ["breed", "name"]}
+				]
+			},
+			"Cat": {
+				"allOf": [
+					{"$ref": "#/definitions/Animal"},
+					{"$ref": "#/definitions/Pet"},
+					{"properties": {"base": {"type": "boolean"}}}
+				]
+			}
+		}
+	}`
+
+	tests := []struct {
+		style AllOfStyle
+		want  []string
+	}{
+		{
+			style: AllOfFlatten,
+			want: []string{
+				"message Dog {\n  string breed = 1;\n  string name = 2;\n}",
+				"message Cat {\n  bool base = 1;\n  string name = 2;\n  string owner = 3;\n}",
+			},
+		},
+		{
+			style: AllOfCompose,
+			want: []string{
+				"message Dog {\n  Animal base = 1;\n  string breed = 2;\n}",
+				"message Cat {\n  Animal base_animal = 1;\n  Pet base_pet = 2;\n  bool base = 3;\n}",
+				"message Animal {\n  string name = 1;\n}",
+				"message Pet {\n  string owner = 1;\n}",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.style), func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.AllOfStyle = tt.style
+			got, warnings, err := ConvertWithReport(schema, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.Empty(t, warnings)
+			assert.Empty(t, Validate(got))
+		})
+	}
+
+	opts := DefaultOptions()
+	opts.AllOfStyle = "inherit"
+	_, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.EqualError(t, err, `unsupported allOf style "inherit"`)
+}
+
+func TestAllOfPrecedence(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	schema := `{"type": "object", "properties": {
+		"value": {
+			"properties": {"id": {"type": "integer"}},
+			"allOf": [
+				{"type": "object", "properties": {"id": {"type": "string"}, "a": {"type": "string"}}},
+				{"type": "string"},
+				{"properties": {"a": {"type": "boolean"}, "b": {"type": "number"}}},
+				true
+			]
+		}
+	}}`
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	// The schema's own properties win, then earlier members
+	assert.Contains(t, got, "message Value {\n  string a = 1;\n  double b = 2;\n  int32 id = 3;\n}")
+	assert.Contains(t, logs.String(), "warning: #/properties/value/allOf/1: allOf for value combines conflicting types object and string; using object")
+	assert.Empty(t, Validate(got))
+}
+
+func TestAllOfErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		path   string
+		want   string
+	}{
+		{
+			name:   "not an array",
+			schema: `{"type": "object", "properties": {"a": {"allOf": {"type": "string"}}}}`,
+			path:   "#/properties/a/allOf",
+			want:   "allOf for a must be an array",
+		},
+		{
+			name:   "unresolved member",
+			schema: `{"type": "object", "properties": {"a": {"allOf": [{"$ref": "#/definitions/Missing"}]}}}`,
+			path:   "#/properties/a/allOf/0/$ref",
+			want:   `unresolved $ref "#/definitions/Missing"`,
+		},
+		{
+			name:   "cycle",
+			schema: `{"definitions": {"A": {"allOf": [{"$ref": "#/definitions/B"}]}, "B": {"allOf": [{"$ref": "#/definitions/A"}]}}}`,
+			path:   "#/definitions/B/allOf/0/$ref",
+			want:   `circular $ref "#/definitions/A"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ConvertJSONSchemaToProto(tt.schema, DefaultOptions())
+			var convErr *ConversionError
+			require.ErrorAs(t, err, &convErr)
+			assert.Equal(t, tt.path, convErr.Errors[0].Path)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package converter
+
+// ProtoFile is the intermediate representation of a generated .proto file
+type ProtoFile struct {
+	Syntax   string
+	Package  string
+	Imports  []string
+	Options  []FileOption
+	Messages []*Message
+	Enums    []*Enum
+	Services []*Service
+	// Header is a comment rendered before the syntax statement
+	Header string
+	// CommentStyle controls how descriptions are rendered
+	CommentStyle CommentStyle
+	// Indent is one level of indentation; it defaults to two spaces
+	Indent string
+	// EnumsFirst renders the enums before the messages rather than after
+	EnumsFirst bool
+}
+
+// FileOption is a file-level "option name = value;" statement. Value is
+// rendered verbatim, so string values must already be quoted.
+type FileOption struct {
+	Name  string
+	Value string
+}
+
+// Message represents a single proto message definition
+type Message struct {
+	Name    string
+	Comment string
+	// Options are message-level "option name = value;" statements, such as
+	// deprecated = true
+	Options []FileOption
+	Fields  []*Field
+	// Messages and Enums are types nested within the message, rendered
+	// after its fields
+	Messages []*Message
+	Enums    []*Enum
+	// Source is the JSON pointer of the schema the message was generated
+	// from, e.g. #/definitions/Order; it is empty for synthesized wrappers
+	Source string
+}
+
+// Field represents a single field within a message
+type Field struct {
+	Name     string
+	Type     string
+	Number   int
+	Repeated bool
+	// Optional marks a proto3 optional field, used for nullable properties
+	Optional bool
+	// Oneof names the oneof group the field belongs to, if any. Fields of
+	// the same group are kept adjacent in Message.Fields.
+	Oneof   string
+	Comment string
+	Options []FieldOption
+}
+
+// FieldOption is a single option in a field's [name = value] option list. Value
+// is rendered verbatim, so string values must already be quoted.
+type FieldOption struct {
+	Name  string
+	Value string
+}
+
+// Enum represents a proto enum definition
+type Enum struct {
+	Name    string
+	Comment string
+	// Options are enum-level "option name = value;" statements, such as
+	// allow_alias = true
+	Options []FileOption
+	Values  []*EnumValue
+	// Source is the JSON pointer of the schema the enum was generated from
+	Source string
+}
+
+// EnumValue represents a single named constant within an enum
+type EnumValue struct {
+	Name   string
+	Number int
+	// Comment is written after the value on the same line
+	Comment string
+}
+
+// Service represents a gRPC service definition
+type Service struct {
+	Name    string
+	Methods []*Method
+}
+
+// Method represents a unary RPC within a service
+type Method struct {
+	Name   string
+	Input  string
+	Output string
+}
@@ -0,0 +1,277 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// avroPrimitives maps proto scalar and well-known types to Avro types. Avro
+// has no unsigned integers, so unsigned and fixed-width types widen to long,
+// and free-form values are carried as JSON text.
+var avroPrimitives = map[string]interface{}{
+	"string":   "string",
+	"bytes":    "bytes",
+	"bool":     "boolean",
+	"float":    "float",
+	"double":   "double",
+	"int32":    "int",
+	"sint32":   "int",
+	"sfixed32": "int",
+	"uint32":   "long",
+	"fixed32":  "long",
+	"int64":    "long",
+	"sint64":   "long",
+	"sfixed64": "long",
+	"uint64":   "long",
+	"fixed64":  "long",
+
+	"google.protobuf.Timestamp": avroLogical{Type: "long", LogicalType: "timestamp-millis"},
+	"google.protobuf.Duration":  "string",
+	"google.protobuf.Any":       "string",
+	"google.protobuf.Struct":    "string",
+	"google.protobuf.Value":     "string",
+	"google.protobuf.ListValue": "string",
+	"google.type.Date":          avroLogical{Type: "int", LogicalType: "date"},
+	"google.type.TimeOfDay":     avroLogical{Type: "int", LogicalType: "time-millis"},
+
+	"google.protobuf.DoubleValue": []interface{}{"null", "double"},
+	"google.protobuf.FloatValue":  []interface{}{"null", "float"},
+	"google.protobuf.Int64Value":  []interface{}{"null", "long"},
+	"google.protobuf.UInt64Value": []interface{}{"null", "long"},
+	"google.protobuf.Int32Value":  []interface{}{"null", "int"},
+	"google.protobuf.UInt32Value": []interface{}{"null", "long"},
+	"google.protobuf.BoolValue":   []interface{}{"null", "boolean"},
+	"google.protobuf.StringValue": []interface{}{"null", "string"},
+	"google.protobuf.BytesValue":  []interface{}{"null", "bytes"},
+}
+
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Doc       string      `json:"doc,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+type avroField struct {
+	Name    string          `json:"name"`
+	Type    interface{}     `json:"type"`
+	Doc     string          `json:"doc,omitempty"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+type avroEnum struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"`
+	Doc       string   `json:"doc,omitempty"`
+	Symbols   []string `json:"symbols"`
+}
+
+type avroArray struct {
+	Type  string      `json:"type"`
+	Items interface{} `json:"items"`
+}
+
+type avroMap struct {
+	Type   string      `json:"type"`
+	Values interface{} `json:"values"`
+}
+
+type avroLogical struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType"`
+}
+
+// ConvertJSONSchemaToAvro converts a JSON Schema to an Avro schema (.avsc).
+// The schema is parsed exactly as for ConvertJSONSchemaToProto; only the
+// emitter differs. Messages become records, enums become Avro enums, and
+// nullable properties and oneOf alternatives become unions with null.
+func ConvertJSONSchemaToAvro(schemaStr string, opts *Options) (string, error) {
+	file, err := BuildProtoFile(schemaStr, opts)
+	if err != nil {
+		return "", err
+	}
+	return RenderAvro(file)
+}
+
+// RenderAvro renders a ProtoFile as Avro schema JSON. Each named type is
+// defined at its first use and referenced by name afterwards. When every type
+// is reachable from the first message the result is that single record;
+// otherwise it is a JSON array of the top-level types.
+func RenderAvro(file *ProtoFile) (string, error) {
+	e := &avroEmitter{
+		messages: make(map[string]*Message, len(file.Messages)),
+		enums:    make(map[string]*Enum, len(file.Enums)),
+		defined:  make(map[string]bool),
+	}
+	for _, msg := range allMessages(file.Messages) {
+		e.messages[msg.Name] = msg
+	}
+	for _, enum := range allEnums(file) {
+		e.enums[enum.Name] = enum
+	}
+
+	var schemas []interface{}
+	for _, msg := range file.Messages {
+		if !e.defined[msg.Name] {
+			schemas = append(schemas, e.withNamespace(e.namedType(msg.Name), file.Package))
+		}
+	}
+	for _, enum := range file.Enums {
+		if !e.defined[enum.Name] {
+			schemas = append(schemas, e.withNamespace(e.namedType(enum.Name), file.Package))
+		}
+	}
+
+	var out interface{} = schemas
+	if len(schemas) == 1 {
+		out = schemas[0]
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// avroEmitter tracks which named types have been written so later uses can
+// refer to them by name
+type avroEmitter struct {
+	messages map[string]*Message
+	enums    map[string]*Enum
+	defined  map[string]bool
+}
+
+// withNamespace sets the namespace of a top-level named type; nested
+// definitions inherit it
+func (e *avroEmitter) withNamespace(schema interface{}, namespace string) interface{} {
+	switch s := schema.(type) {
+	case *avroRecord:
+		s.Namespace = namespace
+	case *avroEnum:
+		s.Namespace = namespace
+	}
+	return schema
+}
+
+// namedType returns the full definition of a message or enum the first time it
+// is used and its name thereafter. Types not defined in the file, such as
+// imports, are referenced by name.
+func (e *avroEmitter) namedType(name string) interface{} {
+	if e.defined[name] {
+		return name
+	}
+	if msg, ok := e.messages[name]; ok {
+		// Mark the record defined before its fields so recursive references
+		// use the name
+		e.defined[name] = true
+		return e.record(msg)
+	}
+	if enum, ok := e.enums[name]; ok {
+		e.defined[name] = true
+		return avroEnumFor(enum)
+	}
+	return name
+}
+
+func (e *avroEmitter) record(msg *Message) *avroRecord {
+	rec := &avroRecord{Type: "record", Name: msg.Name, Doc: msg.Comment, Fields: []avroField{}}
+	for i := 0; i < len(msg.Fields); i++ {
+		field := msg.Fields[i]
+		if field.Oneof == "" {
+			af := avroField{Name: field.Name, Type: e.fieldType(field), Doc: field.Comment}
+			if field.Optional {
+				af.Default = json.RawMessage("null")
+			}
+			rec.Fields = append(rec.Fields, af)
+			continue
+		}
+
+		// A oneof group becomes a single field holding a union of its members
+		union := []interface{}{"null"}
+		doc := field.Comment
+		for ; i < len(msg.Fields) && msg.Fields[i].Oneof == field.Oneof; i++ {
+			union = appendUnion(union, e.fieldType(msg.Fields[i]))
+		}
+		i--
+		rec.Fields = append(rec.Fields, avroField{Name: field.Oneof, Type: union, Doc: doc, Default: json.RawMessage("null")})
+	}
+	return rec
+}
+
+// fieldType returns the Avro type of a field, including its repeated and
+// optional labels
+func (e *avroEmitter) fieldType(field *Field) interface{} {
+	t := e.valueType(field.Type)
+	if field.Repeated {
+		t = avroArray{Type: "array", Items: t}
+	}
+	if field.Optional {
+		t = appendUnion([]interface{}{"null"}, t)
+	}
+	return t
+}
+
+// valueType returns the Avro type for a proto type name, which may be a
+// map<K, V> type
+func (e *avroEmitter) valueType(typ string) interface{} {
+	if strings.HasPrefix(typ, "map<") && strings.HasSuffix(typ, ">") {
+		parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(typ, "map<"), ">"), ",", 2)
+		if len(parts) == 2 {
+			// Avro map keys are always strings
+			return avroMap{Type: "map", Values: e.valueType(strings.TrimSpace(parts[1]))}
+		}
+	}
+	if elem := strings.TrimPrefix(typ, "repeated "); elem != typ {
+		return avroArray{Type: "array", Items: e.valueType(elem)}
+	}
+	if t, ok := avroPrimitives[typ]; ok {
+		return t
+	}
+	return e.namedType(typ)
+}
+
+// appendUnion adds t to union, flattening t if it is itself a union since
+// Avro unions can't directly contain other unions
+func appendUnion(union []interface{}, t interface{}) []interface{} {
+	members, ok := t.([]interface{})
+	if !ok {
+		members = []interface{}{t}
+	}
+	for _, m := range members {
+		if s, ok := m.(string); ok && containsString(union, s) {
+			continue
+		}
+		union = append(union, m)
+	}
+	return union
+}
+
+func containsString(values []interface{}, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// avroEnumFor converts an enum, dropping the type-name prefix from its values
+// since Avro symbols are already scoped to their enum
+func avroEnumFor(enum *Enum) *avroEnum {
+	prefix := toEnumValueName(enum.Name) + "_"
+	symbols := make([]string, 0, len(enum.Values))
+	for _, v := range enum.Values {
+		symbol := v.Name
+		if trimmed := strings.TrimPrefix(symbol, prefix); trimmed != symbol && protoIdentifier.MatchString(trimmed) {
+			symbol = trimmed
+		}
+		symbols = append(symbols, symbol)
+	}
+	return &avroEnum{Type: "enum", Name: enum.Name, Doc: enum.Comment, Symbols: symbols}
+}
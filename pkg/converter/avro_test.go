@@ -0,0 +1,131 @@
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertJSONSchemaToAvro(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"description": "An order",
+		"properties": {
+			"billing": {"$ref": "#/definitions/Address"},
+			"shipping": {"$ref": "#/definitions/Address"},
+			"status": {"type": "string", "enum": ["open", "closed"]},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"counts": {"type": "object", "additionalProperties": {"type": "integer"}},
+			"note": {"type": ["string", "null"], "description": "Free text"},
+			"payment": {"oneOf": [{"$ref": "#/definitions/Card"}, {"type": "string"}]}
+		},
+		"definitions": {
+			"Address": {"type": "object", "properties": {"city": {"type": "string"}}},
+			"Card": {"type": "object", "properties": {"number": {"type": "string"}}}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToAvro(schema, DefaultOptions())
+	require.NoError(t, err)
+
+	var root map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(got), &root))
+	assert.Equal(t, "record", root["type"])
+	assert.Equal(t, "Root", root["name"])
+	assert.Equal(t, "schema", root["namespace"])
+	assert.Equal(t, "An order", root["doc"])
+
+	fields := make(map[string]map[string]interface{})
+	var order []string
+	for _, f := range root["fields"].([]interface{}) {
+		field := f.(map[string]interface{})
+		fields[field["name"].(string)] = field
+		order = append(order, field["name"].(string))
+	}
+	assert.Equal(t, []string{"billing", "counts", "note", "payment", "shipping", "status", "tags"}, order)
+
+	// The first use of a named type defines it; later uses refer to it by name
+	assert.Equal(t, map[string]interface{}{
+		"type": "record", "name": "Address",
+		"fields": []interface{}{map[string]interface{}{"name": "city", "type": "string"}},
+	}, fields["billing"]["type"])
+	assert.Equal(t, "Address", fields["shipping"]["type"])
+
+	assert.Equal(t, map[string]interface{}{"type": "map", "values": "int"}, fields["counts"]["type"])
+	assert.Equal(t, map[string]interface{}{"type": "array", "items": "string"}, fields["tags"]["type"])
+	assert.Equal(t, map[string]interface{}{
+		"type": "enum", "name": "StatusEnum", "symbols": []interface{}{"UNSPECIFIED", "OPEN", "CLOSED"},
+	}, fields["status"]["type"])
+
+	assert.Equal(t, []interface{}{"null", "string"}, fields["note"]["type"])
+	assert.Equal(t, "Free text", fields["note"]["doc"])
+	assert.Contains(t, fields["note"], "default")
+	assert.Nil(t, fields["note"]["default"])
+
+	payment := fields["payment"]["type"].([]interface{})
+	require.Len(t, payment, 3)
+	assert.Equal(t, "null", payment[0])
+	assert.Equal(t, "Card", payment[1].(map[string]interface{})["name"])
+	assert.Equal(t, "string", payment[2])
+	assert.Contains(t, fields["payment"], "default")
+}
+
+func TestRenderAvro(t *testing.T) {
+	t.Run("recursive record refers to itself by name", func(t *testing.T) {
+		file := &ProtoFile{Package: "tree", Messages: []*Message{{
+			Name:   "Node",
+			Fields: []*Field{{Name: "children", Type: "Node", Number: 1, Repeated: true}},
+		}}}
+		got, err := RenderAvro(file)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type": "record", "name": "Node", "namespace": "tree",
+			"fields": [{"name": "children", "type": {"type": "array", "items": "Node"}}]}`, got)
+	})
+
+	t.Run("unreferenced types are listed separately", func(t *testing.T) {
+		file := &ProtoFile{
+			Package:  "pkg",
+			Messages: []*Message{{Name: "A", Fields: []*Field{}}},
+			Enums:    []*Enum{{Name: "Color", Values: []*EnumValue{{Name: "COLOR_UNSPECIFIED"}, {Name: "COLOR_RED", Number: 1}}}},
+		}
+		got, err := RenderAvro(file)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"type": "record", "name": "A", "namespace": "pkg", "fields": []},
+			{"type": "enum", "name": "Color", "namespace": "pkg", "symbols": ["UNSPECIFIED", "RED"]}
+		]`, got)
+	})
+
+	t.Run("well-known types", func(t *testing.T) {
+		file := &ProtoFile{Messages: []*Message{{Name: "Event", Fields: []*Field{
+			{Name: "at", Type: "google.protobuf.Timestamp", Number: 1},
+			{Name: "count", Type: "google.protobuf.Int64Value", Number: 2, Optional: true},
+		}}}}
+		got, err := RenderAvro(file)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type": "record", "name": "Event", "fields": [
+			{"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+			{"name": "count", "type": ["null", "long"], "default": null}
+		]}`, got)
+	})
+	t.Run("oneof groups become unions", func(t *testing.T) {
+		file := &ProtoFile{Messages: []*Message{
+			{Name: "Payment", Fields: []*Field{
+				{Name: "method_card", Type: "Card", Number: 1, Oneof: "method", Comment: "How it was paid"},
+				{Name: "method_string", Type: "string", Number: 2, Oneof: "method"},
+				{Name: "note", Type: "string", Number: 3, Optional: true},
+			}},
+			{Name: "Card", Fields: []*Field{{Name: "number", Type: "string", Number: 1}}},
+		}}
+		got, err := RenderAvro(file)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type": "record", "name": "Payment", "fields": [
+			{"name": "method", "doc": "How it was paid", "default": null, "type": ["null",
+				{"type": "record", "name": "Card", "fields": [{"name": "number", "type": "string"}]},
+				"string"]},
+			{"name": "note", "type": ["null", "string"], "default": null}
+		]}`, got)
+	})
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// largeSchema builds a synthetic schema with n definitions, each mixing
+// scalar, enum, array, map, inline object and reference properties
+func largeSchema(n int) string {
+	var b strings.Builder
+	b.WriteString(`{"type": "object", "properties": {"first": {"$ref": "#/definitions/Def0"}}, "definitions": {`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `"Def%d": {"type": "object", "description": "Definition %d", "properties": {
+			"Display Name": {"type": "string", "description": "Human readable name"},
+			"2fa-enabled": {"type": "boolean"},
+			"count": {"type": "integer"},
+			"ratio": {"type": "number"},
+			"status": {"type": "string", "enum": ["active", "inactive", "pending"]},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"detail_%d": {"type": "object", "properties": {"note": {"type": "string"}, "created-at": {"type": "string", "format": "date-time"}}},
+			"next": {"$ref": "#/definitions/Def%d"}
+		}}`, i, i, i, (i+1)%n)
+	}
+	b.WriteString("}}")
+	return b.String()
+}
+
+func BenchmarkConvertLargeSchema(b *testing.B) {
+	schema := largeSchema(2000)
+	b.SetBytes(int64(len(schema)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ConvertJSONSchemaToProto(schema, DefaultOptions()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
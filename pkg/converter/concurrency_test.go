@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentConversions runs many conversions sharing one *Options and
+// checks each produces the same output as a sequential run. Run it with
+// -race to detect shared mutable state.
+func TestConcurrentConversions(t *testing.T) {
+	schemas := []string{
+		largeSchema(20),
+		`{
+			"type": "object",
+			"properties": {
+				"id": {"type": "string", "format": "uuid", "minLength": 36},
+				"when": {"type": "string", "format": "date-time"},
+				"kind": {"type": "string", "enum": ["a", "b"]},
+				"value": {"oneOf": [{"type": "string"}, {"type": "integer"}, {"type": "null"}]},
+				"tags": {"type": "object", "additionalProperties": {"type": "integer"}},
+				"address": {"$ref": "#/definitions/Customer/properties/address"},
+				"customer": {"$ref": "#/definitions/Customer"}
+			},
+			"definitions": {
+				"Customer": {
+					"type": "object",
+					"properties": {
+						"address": {"type": "object", "properties": {"city": {"type": "string"}}}
+					}
+				}
+			}
+		}`,
+	}
+
+	opts := DefaultOptions()
+	opts.UseWellKnownTypes = true
+	opts.EmitValidateOptions = true
+	opts.FormatTypeMappings = map[string]string{"uuid": "string"}
+	opts.Imports = make([]string, 1, 4)
+	opts.Imports[0] = "extra.proto"
+
+	want := make([]string, len(schemas))
+	for i, schema := range schemas {
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		require.NoError(t, err)
+		want[i] = got
+	}
+
+	const workers = 16
+	const iterations = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*iterations*len(schemas))
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < iterations; n++ {
+				for i, schema := range schemas {
+					got, err := ConvertJSONSchemaToProto(schema, opts)
+					if err != nil {
+						errs <- err
+						continue
+					}
+					if got != want[i] {
+						errs <- fmt.Errorf("schema %d: output differs from sequential run", i)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,137 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// structuralKeywords are the keywords through which a then or else branch
+// changes the shape of a value rather than only constraining it
+var structuralKeywords = []string{
+	"type", "properties", "items", "$ref", "enum", "const",
+	"oneOf", "anyOf", "allOf", "additionalProperties", "patternProperties",
+}
+
+// isConditional reports whether a schema has if/then/else subschemas. An if
+// without then or else has no effect and is ignored.
+func isConditional(propMap map[string]interface{}) bool {
+	if _, ok := propMap["if"]; !ok {
+		return false
+	}
+	_, hasThen := propMap["then"]
+	_, hasElse := propMap["else"]
+	return hasThen || hasElse
+}
+
+// changesShape reports whether a then or else branch of a conditional schema
+// declares a type or properties of its own
+func changesShape(propMap map[string]interface{}) bool {
+	for _, key := range []string{"then", "else"} {
+		branch, ok := propMap[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, k := range structuralKeywords {
+			if _, ok := branch[k]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conditionalType converts a property with if/then/else subschemas, which
+// proto can't express. When a branch changes the property's shape the
+// property accepts any value, as an untyped one does; otherwise the branches
+// only add constraints, and the property keeps its own type without them.
+// Either way the property is documented and a warning reported.
+func (c *conversion) conditionalType(name string, propMap map[string]interface{}, path string) (fieldType, error) {
+	if changesShape(propMap) {
+		ft := fieldType{name: c.freeFormType()}
+		c.warnf(path, WarnConditional, "%s has if/then/else subschemas that change its type; using %s", name, ft.name)
+		ft.notes = append(ft.notes, fmt.Sprintf("Conditional schema (if/then/else) flattened to %s.", ft.name))
+		return ft, nil
+	}
+	base := make(map[string]interface{}, len(propMap))
+	for k, v := range propMap {
+		switch k {
+		case "if", "then", "else":
+		default:
+			base[k] = v
+		}
+	}
+	ft, err := c.propertyType(name, base, path)
+	if err != nil {
+		return fieldType{}, err
+	}
+	c.warnf(path, WarnConditional, "%s has if/then/else subschemas, which aren't enforced", name)
+	ft.notes = append(ft.notes, conditionalNote)
+	return ft, nil
+}
+
+// conditionalNote documents the if/then/else constraints of a field or
+// message that the generated proto doesn't enforce
+const conditionalNote = "Conditional constraints (if/then/else) not enforced."
+
+// dependencyKeywords are the keywords making parts of an object depend on
+// the presence of a property: dependentRequired and dependentSchemas from
+// 2019-09, and dependencies, which combined both before it
+var dependencyKeywords = []string{"dependentRequired", "dependentSchemas", "dependencies"}
+
+// dependencyNotes documents the property dependencies of an object schema,
+// which proto can't express, as comment lines for its message, e.g. "if
+// credit_card present then billing_address required". The dependent schemas
+// are only described, never converted, so they add no fields; a warning is
+// reported when there are any.
+func (c *conversion) dependencyNotes(schema map[string]interface{}, path, msgName string) []string {
+	var notes []string
+	for _, keyword := range dependencyKeywords {
+		deps, ok := schema[keyword].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		names := make([]string, 0, len(deps))
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if note := c.dependencyNote(name, deps[name]); note != "" {
+				notes = append(notes, note)
+			}
+		}
+	}
+	if len(notes) > 0 {
+		c.warnf(path, WarnDependencies, "%s has property dependencies, which aren't enforced", msgName)
+	}
+	return notes
+}
+
+// dependencyNote describes what the presence of property requires: a list
+// of other properties, or a schema, whose required properties are named
+func (c *conversion) dependencyNote(property string, dep interface{}) string {
+	prefix := "if " + c.fieldName(property) + " present then "
+	var required []interface{}
+	switch v := dep.(type) {
+	case []interface{}:
+		required = v
+	case map[string]interface{}:
+		required, _ = v["required"].([]interface{})
+		if len(required) == 0 {
+			return prefix + "its dependent schema applies"
+		}
+	default:
+		return ""
+	}
+	names := make([]string, 0, len(required))
+	for _, r := range required {
+		if s, ok := r.(string); ok {
+			names = append(names, c.fieldName(s))
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return prefix + strings.Join(names, ", ") + " required"
+}
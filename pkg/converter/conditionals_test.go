@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionals(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   []string
+		path   string
+	}{
+		{
+			name: "branches change the type",
+			schema: `{"type": "object", "properties": {"payment": {
+				"if": {"properties": {"kind": {"const": "card"}}},
+				"then": {"type": "object", "properties": {"number": {"type": "string"}}},
+				"else": {"type": "string"}}}}`,
+			want: []string{"  // Conditional schema (if/then/else) flattened to google.protobuf.Any.\n  google.protobuf.Any payment = 1;"},
+			path: "#/properties/payment",
+		},
+		{
+			name: "branches only constrain",
+			schema: `{"type": "object", "properties": {"address": {
+				"type": "object",
+				"properties": {"country": {"type": "string"}, "state": {"type": "string"}},
+				"if": {"properties": {"country": {"const": "US"}}},
+				"then": {"required": ["state"]}}}}`,
+			want: []string{
+				"  // Conditional constraints (if/then/else) not enforced.\n  Address address = 1;",
+				"message Address {\n  string country = 1;\n  string state = 2;\n}",
+			},
+			path: "#/properties/address",
+		},
+		{
+			name: "definition",
+			schema: `{"definitions": {"Shipment": {
+				"type": "object",
+				"properties": {"method": {"type": "string"}},
+				"if": {"properties": {"method": {"const": "air"}}},
+				"then": {"properties": {"flight": {"type": "string"}}}}}}`,
+			want: []string{"// Conditional constraints (if/then/else) not enforced.\nmessage Shipment {\n  string method = 1;\n}"},
+			path: "#/definitions/Shipment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings, err := ConvertWithReport(tt.schema, DefaultOptions())
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.Empty(t, Validate(got))
+			require.Len(t, warnings, 1)
+			assert.Equal(t, WarnConditional, warnings[0].Code)
+			assert.Equal(t, tt.path, warnings[0].Path)
+		})
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"creditCard": {"type": "string"},
+			"payment": {
+				"type": "object",
+				"properties": {"iban": {"type": "string"}, "bic": {"type": "string"}},
+				"dependentSchemas": {
+					"iban": {"required": ["bic"], "properties": {"mandate": {"type": "string"}}},
+					"bic": {"properties": {"bank": {"type": "string"}}}
+				}
+			}
+		},
+		"dependentRequired": {"creditCard": ["billing_address", "name"]},
+		"definitions": {
+			"Legacy": {
+				"type": "object",
+				"properties": {"a": {"type": "string"}, "b": {"type": "string"}},
+				"dependencies": {"a": ["b"]}
+			}
+		}
+	}`
+	got, warnings, err := ConvertWithReport(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, `// if creditcard present then billing_address, name required
+message Root {
+  string creditcard = 1;
+  string name = 2;
+  Payment payment = 3;
+}`)
+	assert.Contains(t, got, `// if bic present then its dependent schema applies
+// if iban present then bic required
+message Payment {
+  string bic = 1;
+  string iban = 2;
+}`)
+	assert.Contains(t, got, "// if a present then b required\nmessage Legacy {")
+	// Properties of dependent schemas don't become fields
+	assert.NotContains(t, got, "mandate")
+	assert.NotContains(t, got, "bank")
+	assert.NotContains(t, got, "string billing_address")
+
+	codes := make([]WarningCode, len(warnings))
+	for i, w := range warnings {
+		codes[i] = w.Code
+	}
+	assert.Equal(t, []WarningCode{WarnDependencies, WarnDependencies, WarnDependencies}, codes)
+	assert.Empty(t, Validate(got))
+}
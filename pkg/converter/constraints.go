@@ -0,0 +1,237 @@
+package converter
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// validateImport declares the protoc-gen-validate rule options
+const validateImport = "validate/validate.proto"
+
+// bound is a single schema constraint rendered both as a comment fragment,
+// e.g. min=1, and as a protoc-gen-validate rule, e.g. min_items: 1. label
+// groups fragments onto one comment line; a bound without a comment only
+// produces a rule.
+type bound struct {
+	label   string
+	comment string
+	rule    string
+}
+
+// applyConstraints documents bounds on ft as "label: ..." comment lines when
+// EmitConstraintComments is set, and adds them as a single
+// (validate.rules).ruleType option when EmitValidateOptions is set. Nothing
+// is added without bounds.
+func (c *conversion) applyConstraints(ft *fieldType, ruleType string, bounds []bound) {
+	if len(bounds) == 0 {
+		return
+	}
+	if c.opts.EmitConstraintComments {
+		var labels []string
+		comments := make(map[string][]string)
+		for _, b := range bounds {
+			if b.comment == "" {
+				continue
+			}
+			if _, seen := comments[b.label]; !seen {
+				labels = append(labels, b.label)
+			}
+			comments[b.label] = append(comments[b.label], b.comment)
+		}
+		for _, label := range labels {
+			ft.notes = append(ft.notes, label+": "+strings.Join(comments[label], " "))
+		}
+	}
+	if c.opts.EmitValidateOptions {
+		rules := make([]string, len(bounds))
+		for i, b := range bounds {
+			rules[i] = b.rule
+		}
+		ft.options = append(ft.options, FieldOption{
+			Name:  "(validate.rules)." + ruleType,
+			Value: "{" + strings.Join(rules, ", ") + "}",
+		})
+	}
+}
+
+// arrayBounds returns an array schema's minItems and maxItems bounds
+func arrayBounds(propMap map[string]interface{}) []bound {
+	var bounds []bound
+	if n, ok := schemaNumber(propMap, "minItems"); ok {
+		bounds = append(bounds, bound{label: "items", comment: "min=" + n, rule: "min_items: " + n})
+	}
+	if n, ok := schemaNumber(propMap, "maxItems"); ok {
+		bounds = append(bounds, bound{label: "items", comment: "max=" + n, rule: "max_items: " + n})
+	}
+	return bounds
+}
+
+// mapBounds returns a map-like object schema's minProperties and
+// maxProperties bounds
+func mapBounds(propMap map[string]interface{}) []bound {
+	var bounds []bound
+	if n, ok := schemaNumber(propMap, "minProperties"); ok {
+		bounds = append(bounds, bound{label: "size", comment: "min=" + n, rule: "min_pairs: " + n})
+	}
+	if n, ok := schemaNumber(propMap, "maxProperties"); ok {
+		bounds = append(bounds, bound{label: "size", comment: "max=" + n, rule: "max_pairs: " + n})
+	}
+	return bounds
+}
+
+// numericRuleTypes are the proto scalar types protoc-gen-validate has
+// numeric rules for, mapped to whether they hold integers
+var numericRuleTypes = map[string]bool{
+	"double": false, "float": false,
+	"int32": true, "int64": true, "uint32": true, "uint64": true, "sint32": true,
+	"sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+}
+
+// validateStringFormats maps JSON Schema string formats onto the matching
+// protoc-gen-validate string rule
+var validateStringFormats = map[string]string{
+	"email":         "email",
+	"hostname":      "hostname",
+	"ipv4":          "ipv4",
+	"ipv6":          "ipv6",
+	"uri":           "uri",
+	"uri-reference": "uri_ref",
+	"uuid":          "uuid",
+}
+
+// applyScalarConstraints documents and validates the length, pattern, format
+// and range constraints of a scalar property, choosing the rule type from the
+// field's resolved proto type
+func (c *conversion) applyScalarConstraints(ft *fieldType, propMap map[string]interface{}) {
+	if ft.name == "string" {
+		c.applyConstraints(ft, "string", stringBounds(propMap))
+		return
+	}
+	if integer, ok := numericRuleTypes[ft.name]; ok {
+		c.applyConstraints(ft, ft.name, rangeBounds(propMap, integer, c.draft))
+		c.applyMultipleOf(ft, propMap)
+	}
+}
+
+// applyMultipleOf documents a numeric schema's multipleOf as a
+// "multipleOf: N" comment when EmitConstraintComments is set.
+// protoc-gen-validate has no equivalent rule, so with EmitValidateOptions
+// the field is flagged as having a constraint it can't enforce instead.
+func (c *conversion) applyMultipleOf(ft *fieldType, propMap map[string]interface{}) {
+	n, ok := schemaNumber(propMap, "multipleOf")
+	if !ok {
+		return
+	}
+	if c.opts.EmitConstraintComments {
+		ft.notes = append(ft.notes, "multipleOf: "+n)
+	}
+	if c.opts.EmitValidateOptions {
+		ft.notes = append(ft.notes, "note: multipleOf not enforceable")
+	}
+}
+
+// stringBounds returns a string schema's minLength, maxLength, pattern and
+// format bounds. Equal minLength and maxLength, as for fixed-length codes,
+// become a single "exactly N" bound.
+func stringBounds(propMap map[string]interface{}) []bound {
+	var bounds []bound
+	min, hasMin := schemaNumber(propMap, "minLength")
+	max, hasMax := schemaNumber(propMap, "maxLength")
+	if hasMin && hasMax && min == max {
+		bounds = append(bounds, bound{label: "length", comment: "exactly " + min, rule: "len: " + min})
+	} else {
+		if hasMin {
+			bounds = append(bounds, bound{label: "length", comment: "min=" + min, rule: "min_len: " + min})
+		}
+		if hasMax {
+			bounds = append(bounds, bound{label: "length", comment: "max=" + max, rule: "max_len: " + max})
+		}
+	}
+	if pattern, ok := propMap["pattern"].(string); ok {
+		bounds = append(bounds, bound{label: "pattern", comment: pattern, rule: "pattern: " + quoteProtoString(pattern)})
+	}
+	if format, ok := propMap["format"].(string); ok {
+		if rule, ok := validateStringFormats[format]; ok {
+			bounds = append(bounds, bound{rule: rule + ": true"})
+		}
+	}
+	return bounds
+}
+
+// rangeBounds returns a numeric schema's lower and upper bounds. For integer
+// fields exclusive and fractional bounds become the equivalent inclusive
+// bound, since x > 1 and x >= 1.5 both hold for exactly the integers x >= 2.
+func rangeBounds(propMap map[string]interface{}, integer bool, draft Draft) []bound {
+	var bounds []bound
+	if n, exclusive, ok := schemaLimit(propMap, "minimum", "exclusiveMinimum", 1, draft); ok {
+		if integer {
+			if exclusive {
+				n = math.Floor(n) + 1
+			} else {
+				n = math.Ceil(n)
+			}
+			exclusive = false
+		}
+		v := formatNumber(n)
+		if exclusive {
+			bounds = append(bounds, bound{label: "range", comment: "exclusiveMin=" + v, rule: "gt: " + v})
+		} else {
+			bounds = append(bounds, bound{label: "range", comment: "min=" + v, rule: "gte: " + v})
+		}
+	}
+	if n, exclusive, ok := schemaLimit(propMap, "maximum", "exclusiveMaximum", -1, draft); ok {
+		if integer {
+			if exclusive {
+				n = math.Ceil(n) - 1
+			} else {
+				n = math.Floor(n)
+			}
+			exclusive = false
+		}
+		v := formatNumber(n)
+		if exclusive {
+			bounds = append(bounds, bound{label: "range", comment: "exclusiveMax=" + v, rule: "lt: " + v})
+		} else {
+			bounds = append(bounds, bound{label: "range", comment: "max=" + v, rule: "lte: " + v})
+		}
+	}
+	return bounds
+}
+
+// schemaLimit returns the effective bound from an inclusive keyword such as
+// minimum and its exclusive counterpart. In draft-04 the exclusive keyword is
+// a boolean that makes the inclusive bound exclusive; from draft-06 it is a
+// number of its own. When both numbers are given the tighter one wins; dir is
+// 1 for lower bounds and -1 for upper bounds. A known draft only accepts its
+// own form of the exclusive keyword and ignores the other.
+func schemaLimit(propMap map[string]interface{}, inclusiveKey, exclusiveKey string, dir float64, draft Draft) (n float64, exclusive, ok bool) {
+	n, ok = propMap[inclusiveKey].(float64)
+	switch ex := propMap[exclusiveKey].(type) {
+	case bool:
+		exclusive = ok && ex && (draft == "" || draft == Draft4)
+	case float64:
+		if draft == Draft4 {
+			break
+		}
+		if !ok || ex*dir >= n*dir {
+			return ex, true, true
+		}
+	}
+	return n, exclusive, ok
+}
+
+// schemaNumber returns a numeric schema keyword formatted for output, without
+// a trailing ".0" for whole numbers
+func schemaNumber(propMap map[string]interface{}, key string) (string, bool) {
+	n, ok := propMap[key].(float64)
+	if !ok {
+		return "", false
+	}
+	return formatNumber(n), true
+}
+
+// formatNumber formats n without a trailing ".0" for whole numbers
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
@@ -0,0 +1,334 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArrayCardinality(t *testing.T) {
+	tests := []struct {
+		name     string
+		prop     string
+		comments bool
+		validate bool
+		want     []string
+		absent   []string
+	}{
+		{
+			name:     "comments",
+			prop:     `{"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 5}`,
+			comments: true,
+			want:     []string{"  // items: min=1 max=5\n  repeated string tags = 1;"},
+			absent:   []string{"validate"},
+		},
+		{
+			name:     "validate options",
+			prop:     `{"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 5}`,
+			validate: true,
+			want: []string{
+				`import "validate/validate.proto";`,
+				"  repeated string tags = 1 [(validate.rules).repeated = {min_items: 1, max_items: 5}];",
+			},
+			absent: []string{"// items"},
+		},
+		{
+			name:     "only present rules are emitted",
+			prop:     `{"type": "array", "items": {"type": "string"}, "maxItems": 3}`,
+			comments: true,
+			validate: true,
+			want: []string{
+				"// items: max=3\n",
+				"  repeated string tags = 1 [(validate.rules).repeated = {max_items: 3}];",
+			},
+		},
+		{
+			name:     "no bounds",
+			prop:     `{"type": "array", "items": {"type": "string"}}`,
+			comments: true,
+			validate: true,
+			want:     []string{"  repeated string tags = 1;"},
+			absent:   []string{"validate", "// items"},
+		},
+		{
+			name:   "disabled by default",
+			prop:   `{"type": "array", "items": {"type": "string"}, "minItems": 1}`,
+			want:   []string{"  repeated string tags = 1;"},
+			absent: []string{"validate", "// items"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.EmitConstraintComments = tt.comments
+			opts.EmitValidateOptions = tt.validate
+			got, err := ConvertJSONSchemaToProto(`{"type": "object", "properties": {"tags": `+tt.prop+`}}`, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			for _, absent := range tt.absent {
+				assert.NotContains(t, got, absent)
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestMapSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		prop     string
+		comments bool
+		validate bool
+		want     []string
+		absent   []string
+	}{
+		{
+			name:     "comments",
+			prop:     `{"type": "object", "additionalProperties": {"type": "string"}, "minProperties": 1, "maxProperties": 10}`,
+			comments: true,
+			want:     []string{"  // size: min=1 max=10\n  map<string, string> labels = 1;"},
+			absent:   []string{"validate"},
+		},
+		{
+			name:     "validate options",
+			prop:     `{"type": "object", "additionalProperties": {"type": "string"}, "minProperties": 1, "maxProperties": 10}`,
+			validate: true,
+			want: []string{
+				`import "validate/validate.proto";`,
+				"  map<string, string> labels = 1 [(validate.rules).map = {min_pairs: 1, max_pairs: 10}];",
+			},
+			absent: []string{"// size"},
+		},
+		{
+			name:     "only present rules are emitted",
+			prop:     `{"type": "object", "patternProperties": {"^x-": {"type": "integer"}}, "maxProperties": 4}`,
+			comments: true,
+			validate: true,
+			want: []string{
+				"  // keys match: ^x-\n  // size: max=4\n",
+				"  map<string, int32> labels = 1 [(validate.rules).map = {max_pairs: 4}];",
+			},
+		},
+		{
+			name:     "no bounds",
+			prop:     `{"type": "object", "additionalProperties": {"type": "string"}}`,
+			comments: true,
+			validate: true,
+			want:     []string{"  map<string, string> labels = 1;"},
+			absent:   []string{"validate", "// size"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.EmitConstraintComments = tt.comments
+			opts.EmitValidateOptions = tt.validate
+			got, err := ConvertJSONSchemaToProto(`{"type": "object", "properties": {"labels": `+tt.prop+`}}`, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			for _, absent := range tt.absent {
+				assert.NotContains(t, got, absent)
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestScalarValidateRules(t *testing.T) {
+	tests := []struct {
+		name string
+		prop string
+		want string
+	}{
+		{
+			name: "string length and email",
+			prop: `{"type": "string", "minLength": 1, "format": "email"}`,
+			want: "  string value = 1 [(validate.rules).string = {min_len: 1, email: true}];",
+		},
+		{
+			name: "string pattern is quoted",
+			prop: `{"type": "string", "maxLength": 8, "pattern": "^\\d+\"$"}`,
+			want: `  string value = 1 [(validate.rules).string = {max_len: 8, pattern: "^\\d+\"$"}];`,
+		},
+		{
+			name: "uuid format",
+			prop: `{"type": "string", "format": "uuid"}`,
+			want: "  string value = 1 [(validate.rules).string = {uuid: true}];",
+		},
+		{
+			name: "integer range",
+			prop: `{"type": "integer", "minimum": 0, "maximum": 100}`,
+			want: "  int32 value = 1 [(validate.rules).int32 = {gte: 0, lte: 100}];",
+		},
+		{
+			name: "fractional integer bounds round inwards",
+			prop: `{"type": "integer", "minimum": 0.5, "maximum": 9.5}`,
+			want: "  int32 value = 1 [(validate.rules).int32 = {gte: 1, lte: 9}];",
+		},
+		{
+			name: "number range",
+			prop: `{"type": "number", "minimum": -1.5}`,
+			want: "  double value = 1 [(validate.rules).double = {gte: -1.5}];",
+		},
+		{
+			name: "draft-04 boolean exclusive bounds",
+			prop: `{"type": "number", "minimum": 0, "exclusiveMinimum": true, "maximum": 1, "exclusiveMaximum": false}`,
+			want: "  double value = 1 [(validate.rules).double = {gt: 0, lte: 1}];",
+		},
+		{
+			name: "draft-04 boolean without a bound is ignored",
+			prop: `{"type": "number", "exclusiveMaximum": true}`,
+			want: "  double value = 1;",
+		},
+		{
+			name: "draft-06 numeric exclusive bounds",
+			prop: `{"type": "number", "exclusiveMinimum": 0, "exclusiveMaximum": 1.5}`,
+			want: "  double value = 1 [(validate.rules).double = {gt: 0, lt: 1.5}];",
+		},
+		{
+			name: "tighter of inclusive and exclusive bounds wins",
+			prop: `{"type": "number", "minimum": 5, "exclusiveMinimum": 2, "maximum": 10, "exclusiveMaximum": 10}`,
+			want: "  double value = 1 [(validate.rules).double = {gte: 5, lt: 10}];",
+		},
+		{
+			name: "exclusive integer bounds become inclusive",
+			prop: `{"type": "integer", "exclusiveMinimum": 0, "exclusiveMaximum": 9.5}`,
+			want: "  int32 value = 1 [(validate.rules).int32 = {gte: 1, lte: 9}];",
+		},
+		{
+			name: "draft-04 exclusive integer bounds",
+			prop: `{"type": "integer", "minimum": 0, "exclusiveMinimum": true, "maximum": 10, "exclusiveMaximum": true}`,
+			want: "  int32 value = 1 [(validate.rules).int32 = {gte: 1, lte: 9}];",
+		},
+		{
+			name: "unknown format has no rule",
+			prop: `{"type": "string", "format": "color"}`,
+			want: "  string value = 1;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.EmitValidateOptions = true
+			got, err := ConvertJSONSchemaToProto(`{"type": "object", "properties": {"value": `+tt.prop+`}}`, opts)
+			require.NoError(t, err)
+			assert.Contains(t, got, tt.want)
+			if strings.Contains(tt.want, "validate.rules") {
+				assert.Contains(t, got, `import "validate/validate.proto";`)
+			} else {
+				assert.NotContains(t, got, "validate")
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+
+	t.Run("rule type follows type mappings", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.EmitValidateOptions = true
+		opts.TypeMappings["integer"] = "int64"
+		got, err := ConvertJSONSchemaToProto(`{"type": "object", "properties": {"value": {"type": "integer", "maximum": 10}}}`, opts)
+		require.NoError(t, err)
+		assert.Contains(t, got, "  int64 value = 1 [(validate.rules).int64 = {lte: 10}];")
+	})
+}
+
+func TestScalarConstraintComments(t *testing.T) {
+	opts := DefaultOptions()
+	opts.EmitConstraintComments = true
+	schema := `{"type": "object", "properties": {
+		"code": {"type": "string", "description": "Country code", "minLength": 2, "maxLength": 3, "pattern": "^[A-Z]+$"},
+		"score": {"type": "number", "minimum": 0, "maximum": 1},
+		"ratio": {"type": "number", "minimum": 0, "exclusiveMinimum": true, "exclusiveMaximum": 1}
+	}}`
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  // Country code\n  // length: min=2 max=3\n  // pattern: ^[A-Z]+$\n  string code = 1;")
+	assert.Contains(t, got, "  // range: exclusiveMin=0 exclusiveMax=1\n  double ratio = 2;")
+	assert.Contains(t, got, "  // range: min=0 max=1\n  double score = 3;")
+	assert.NotContains(t, got, "validate")
+}
+
+func TestFixedLengthStrings(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"currency": {"type": "string", "description": "ISO 4217 code", "minLength": 3, "maxLength": 3},
+		"pin": {"type": "string", "minLength": 4, "maxLength": 6}
+	}}`
+
+	opts := DefaultOptions()
+	opts.EmitConstraintComments = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  // ISO 4217 code\n  // length: exactly 3\n  string currency = 1;")
+	assert.Contains(t, got, "  // length: min=4 max=6\n  string pin = 2;")
+
+	opts = DefaultOptions()
+	opts.EmitValidateOptions = true
+	got, err = ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  string currency = 1 [(validate.rules).string = {len: 3}];")
+	assert.Contains(t, got, "  string pin = 2 [(validate.rules).string = {min_len: 4, max_len: 6}];")
+	assert.Empty(t, Validate(got))
+}
+
+func TestMultipleOf(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"price": {"type": "number", "minimum": 0, "multipleOf": 0.01},
+		"quantity": {"type": "integer", "multipleOf": 5}
+	}}`
+
+	tests := []struct {
+		name     string
+		comments bool
+		validate bool
+		want     []string
+		absent   []string
+	}{
+		{
+			name:     "comments",
+			comments: true,
+			want: []string{
+				"  // range: min=0\n  // multipleOf: 0.01\n  double price = 1;",
+				"  // multipleOf: 5\n  int32 quantity = 2;",
+			},
+			absent: []string{"not enforceable"},
+		},
+		{
+			name:     "validate options",
+			validate: true,
+			want: []string{
+				"  // note: multipleOf not enforceable\n  double price = 1 [(validate.rules).double = {gte: 0}];",
+				"  // note: multipleOf not enforceable\n  int32 quantity = 2;",
+			},
+			absent: []string{"multipleOf: "},
+		},
+		{
+			name:   "disabled by default",
+			want:   []string{"  double price = 1;\n", "  int32 quantity = 2;\n"},
+			absent: []string{"multipleOf"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.EmitConstraintComments = tt.comments
+			opts.EmitValidateOptions = tt.validate
+			got, err := ConvertJSONSchemaToProto(schema, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			for _, absent := range tt.absent {
+				assert.NotContains(t, got, absent)
+			}
+		})
+	}
+}
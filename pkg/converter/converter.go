@@ -1,184 +1,1603 @@
 package converter
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
-// Options contains configuration options for the converter
+// Options contains configuration options for the converter. Conversions never
+// modify their Options, so a single *Options may be shared by concurrent
+// calls.
 type Options struct {
-	PackageName  string
+	PackageName string
+
+	// TypeMappings maps JSON Schema types onto proto types. Entries are
+	// merged over the defaults of DefaultOptions, so a map such as
+	// {"boolean": "BOOL"} changes booleans and leaves every other type alone.
 	TypeMappings map[string]string
+
+	// IntegerType and NumberType, when non-empty, override the TypeMappings
+	// entry for integer and number respectively, e.g. IntegerType "int64"
+	// without rebuilding the whole map. FormatTypeMappings and well-known
+	// types still take precedence over them.
+	IntegerType string
+	NumberType  string
+
+	// DeriveNamingFromId derives the package from the schema's $id (or
+	// draft-04 id) URI, e.g. https://example.com/schemas/order.json gives
+	// package order. PackageName is used when the schema has no usable id.
+	DeriveNamingFromId bool
+
+	// FormatTypeMappings maps a JSON Schema "format" such as uuid or email to
+	// the proto type used for it, e.g. {"uuid": "UUID"}. It is consulted
+	// before UseWellKnownTypes and TypeMappings; unmapped formats keep the
+	// type of their JSON type. This is how to keep the precision of
+	// {"type": "string", "format": "decimal"} and "money" values, which are
+	// plain strings by default: map them to google.type.Decimal or
+	// google.type.Money, whose imports are added automatically, or to a
+	// message of your own listed in TypeImports.
+	FormatTypeMappings map[string]string
+
+	// UseContentMediaTypes types string properties by their
+	// contentMediaType: application/json, and media types with a +json
+	// suffix such as application/geo+json, become google.protobuf.Struct and
+	// application/octet-stream becomes bytes. MediaTypeMappings entries are
+	// merged over these, e.g. {"application/json": "google.protobuf.Value"}.
+	// A format in FormatTypeMappings still takes precedence.
+	UseContentMediaTypes bool
+	MediaTypeMappings    map[string]string
+
+	// TypeImports maps custom proto types used through the type mappings to
+	// the file declaring them, e.g. {"acme.money.Money": "acme/money.proto"}.
+	// The file is imported whenever the type appears in the output.
+	TypeImports map[string]string
+
+	// GoPackage, when set, is emitted as the file's go_package option
+	GoPackage string
+
+	// FileOptions are emitted in order as "option name = value;" lines after
+	// the go_package option. Values are quoted as strings unless they are
+	// already quoted, true/false, numeric, or an UPPER_CASE enum constant such
+	// as SPEED. A later option with the same name replaces an earlier one.
+	FileOptions []FileOption
+
+	// Imports lists additional proto files to import. Imports required by
+	// well-known types used in the output are added automatically.
+	Imports []string
+
+	// TypeResolver, when set, is consulted for every property and array item
+	// schema before the built-in mapping. Returning handled=true uses
+	// protoType as the field type verbatim; returning false falls through to
+	// $ref, enum and TypeMappings handling. Array items are resolved
+	// separately, so handling an array schema itself replaces the whole
+	// repeated field type.
+	TypeResolver func(jsonType, format string, prop map[string]interface{}) (protoType string, handled bool)
+
+	// UseWellKnownTypes maps string formats onto google.protobuf well-known
+	// types instead of plain scalars: date-time becomes Timestamp and duration
+	// becomes Duration. The date and time formats become google.type.Date
+	// and google.type.TimeOfDay, from Google's common protos.
+	UseWellKnownTypes bool
+
+	// DatesAsStrings keeps the date and time formats as plain strings under
+	// UseWellKnownTypes, for setups where the google/type protos can't be
+	// imported. date-time and duration still map to well-known types.
+	DatesAsStrings bool
+
+	// FreeFormObjectsAsStruct maps inline objects that declare no properties
+	// (or only "additionalProperties": {}) to google.protobuf.Struct rather
+	// than generating an empty message
+	FreeFormObjectsAsStruct bool
+
+	// FreeFormType selects the type of properties that accept any JSON
+	// value: a true schema, {} or any other schema without a type. The zero
+	// value behaves like FreeFormAny. With FreeFormValue or FreeFormStruct,
+	// arrays whose items accept any value become google.protobuf.ListValue.
+	FreeFormType FreeFormType
+
+	// WrapNestedOneOf handles oneOf array items and map values, which a proto
+	// oneof can't express directly, by generating a message holding the
+	// oneof, e.g. repeated TagsItem tags with message TagsItem { oneof value
+	// {...} }. By default they become google.protobuf.Any with a warning.
+	WrapNestedOneOf bool
+
+	// Transform, when set, is called with the finished ProtoFile before it is
+	// rendered and may modify it freely, e.g. to add or rename fields. It runs
+	// last, after every other option has been applied; imports for
+	// well-known types it introduces are added afterwards. An error aborts
+	// the conversion.
+	Transform func(*ProtoFile) error
+
+	// Logger, when set, receives conversion warnings at slog.LevelWarn and
+	// progress at slog.LevelDebug, each with a "path" attribute holding the
+	// JSON pointer of the schema location. Its handler decides what is kept,
+	// so warnings can be silenced or captured. Without a Logger, warnings are
+	// printed with the standard log package as "warning: <path>: <message>".
+	Logger *slog.Logger
+
+	// FailFast stops the conversion at the first error instead of collecting
+	// every error into a single ConversionError
+	FailFast bool
+
+	// MaxDepth limits how deeply subschemas may nest, counting every inline
+	// object, array items, map value and referenced subschema converted
+	// along the way; a schema nested deeper is an error at the path where
+	// the limit is exceeded, so untrusted schemas can't exhaust the stack.
+	// Zero means DefaultMaxDepth and a negative value removes the limit.
+	MaxDepth int
+
+	// FieldNameFunc, when set, replaces SanitizeFieldName for turning JSON
+	// property names into proto field names. MessageNameFunc likewise replaces
+	// the built-in PascalCase conversion used to name messages generated for
+	// inline objects. Both must return valid proto identifiers
+	// ([A-Za-z_][A-Za-z0-9_]*); the converter does not re-sanitize them.
+	FieldNameFunc   func(string) string
+	MessageNameFunc func(string) string
+
+	// PreserveFieldNames keeps property names that are already valid proto
+	// identifiers, such as createdAt, as field names verbatim; other names
+	// are still converted by SanitizeFieldName. FieldNameFunc takes
+	// precedence over it.
+	PreserveFieldNames bool
+
+	// FieldNumberStep spaces the field numbers assigned to a message's
+	// fields, leaving room to add fields in between later: with 10 they are
+	// numbered 1, 11, 21 and so on. Zero or 1 numbers them sequentially.
+	// Numbers in the range 19000-19999 reserved by protobuf are skipped
+	// either way. A property's x-proto-field-number sets its number
+	// explicitly, and the other fields are numbered around it.
+	FieldNumberStep int
+
+	// SnakeCaseFieldNames converts property names to lower_snake_case field
+	// names, splitting camelCase words, e.g. createdAt becomes created_at,
+	// and collapsing runs of separators. It takes precedence over
+	// PreserveFieldNames. PascalCaseDefinitions likewise names the types
+	// generated for definitions in PascalCase, e.g. order_item becomes
+	// OrderItem, instead of keeping the definition names verbatim.
+	// Together with UnspecifiedEnumZero they make the output pass buf lint.
+	SnakeCaseFieldNames   bool
+	PascalCaseDefinitions bool
+
+	// UnspecifiedEnumZero names the zero value of every generated enum
+	// <ENUM>_UNSPECIFIED. String enums always get such a value; integer
+	// enums that include 0 otherwise name it <ENUM>_VALUE_0.
+	UnspecifiedEnumZero bool
+
+	// EmitJsonNameOption adds [json_name = "..."] to fields whose proto name
+	// differs from the original JSON property name, keeping JSON mapping faithful
+	EmitJsonNameOption bool
+
+	// SortEnumValues numbers the values of generated enums in sorted order
+	// rather than schema order. Either way a synthesized UNSPECIFIED value
+	// is 0, repeated values are dropped, and the numbering only depends on
+	// the schema.
+	SortEnumValues bool
+
+	// InlineEnumsAsStrings keeps properties with an inline enum as plain
+	// string fields documented with their allowed values, instead of
+	// generating an enum type for them
+	InlineEnumsAsStrings bool
+
+	// GenerateService emits a gRPC service with a unary rpc for every
+	// <Name>Request message that has a matching <Name>Response message.
+	// ServiceName names the service; it defaults to the PascalCased package
+	// name followed by "Service".
+	GenerateService bool
+	ServiceName     string
+
+	// EmitConstraintComments documents schema constraints proto can't
+	// enforce, such as minItems/maxItems, string length and pattern,
+	// numeric ranges and multipleOf, as comments on the field. A string
+	// whose minLength equals its maxLength is noted as "length: exactly N".
+	EmitConstraintComments bool
+
+	// EmitValidateOptions turns the constraints present in the schema into
+	// protoc-gen-validate [(validate.rules)...] field options and imports
+	// validate/validate.proto when any rule is emitted. multipleOf has no
+	// rule, so fields with it get a "note: multipleOf not enforceable"
+	// comment instead.
+	EmitValidateOptions bool
+
+	// EmitDefaultComments adds a "default: <value>" comment to fields whose
+	// schema declares a default, with the value written as compact JSON
+	EmitDefaultComments bool
+
+	// EmitEnumValueComments writes the original string after each value of
+	// a string enum whose name doesn't spell it verbatim, e.g.
+	// STATUS_IN_PROGRESS = 2; // "in-progress"
+	EmitEnumValueComments bool
+
+	// EmitExamples adds an "Example: <value>" comment for each entry of a
+	// property's examples array, with the value written as compact JSON.
+	// $comment annotations are never emitted.
+	EmitExamples bool
+
+	// Properties marked readOnly or writeOnly are annotated with a
+	// "// readOnly" or "// writeOnly" comment. Setting ReadOnlyOption or
+	// WriteOnlyOption to a custom option name such as "(my.readonly)" emits
+	// [(my.readonly) = true] instead; the file declaring the option must be
+	// added to Imports.
+	ReadOnlyOption  string
+	WriteOnlyOption string
+
+	// EmitVendorExtensions handles the x- extensions of definitions and of
+	// the root schema: the entries of x-proto-message-options become message
+	// options, e.g. {"x-proto-message-options": {"(my.table)": "orders"}}
+	// gives option (my.table) = "orders";, formatted like x-proto-options
+	// field options. Other x- keys are dropped unless VendorExtensionComments
+	// is set, which documents them as "x-key: <JSON value>" comment lines.
+	EmitVendorExtensions    bool
+	VendorExtensionComments bool
+
+	// Draft is the JSON Schema draft used to interpret the schema, overriding
+	// the one its $schema declares. It decides whether exclusiveMinimum and
+	// exclusiveMaximum are draft-04 booleans or numbers, whether boolean
+	// schemas are allowed and whether $defs, from 2019-09, hold definitions
+	// (reported under #/definitions). DefaultDraft is used for schemas
+	// without a recognized $schema; when both are empty every draft's forms
+	// are accepted.
+	Draft        Draft
+	DefaultDraft Draft
+
+	// AliasEnumCollisions controls string enum values that are distinct in
+	// the schema but give the same value name, such as "in-progress" and
+	// "in_progress". They always get distinct names, the later ones with a
+	// _2, _3... suffix; by default they also get numbers of their own, and
+	// with AliasEnumCollisions they share the number of the first, as
+	// aliases under option allow_alias = true.
+	AliasEnumCollisions bool
+
+	// WrapRootRef controls schemas whose root is just a $ref. By default the
+	// root is an alias for the referenced type, which is generated without a
+	// root message; with WrapRootRef the root message wraps it in a single
+	// "value" field, as is done for scalar roots.
+	WrapRootRef bool
+
+	// AllOfStyle selects how allOf members that $ref an object schema are
+	// converted. The zero value behaves like AllOfFlatten, merging their
+	// properties into the message; AllOfCompose instead embeds the
+	// referenced message as a field, so {"allOf": [{"$ref":
+	// "#/definitions/Animal"}, {"properties": {"breed": ...}}]} gives
+	// message Dog { Animal base = 1; string breed = 2; }.
+	AllOfStyle AllOfStyle
+
+	// Flatten generates a single root message, with the types it uses nested
+	// within it, instead of a top-level message per definition and inline
+	// object. $refs are resolved and the referenced subschemas converted in
+	// place, so definitions no property refers to are left out, and a root
+	// $ref is wrapped as with WrapRootRef. A $ref back into a subschema that
+	// is still being converted can't be inlined; the property becomes the
+	// free-form type, documented with a comment, and a warning is reported.
+	// Schemas without a root message are converted as usual. Flatten can't
+	// be combined with GenerateService.
+	Flatten bool
+
+	// NestEnums declares each enum used by exactly one message inside that
+	// message rather than at the top level of the file. Enums used by
+	// several messages, or by none, stay top-level.
+	NestEnums bool
+
+	// RootArrayFieldName names the repeated field of the root message
+	// generated for a top-level array schema. It defaults to "items".
+	RootArrayFieldName string
+
+	// RootMessageName names the message generated for the schema's
+	// top-level properties; it defaults to "Root". With UseTitleAsRootName
+	// the schema's title, converted to PascalCase, is used instead when
+	// present, so {"title": "Purchase Order", ...} generates message
+	// PurchaseOrder. A title that doesn't give a valid message name, or that
+	// names a definition too, is reported and RootMessageName used.
+	RootMessageName    string
+	UseTitleAsRootName bool
+
+	// SplitReadWrite generates separate request and response messages for
+	// schemas shared by both directions of an API. Every message with a
+	// readOnly or writeOnly field, directly or through the messages it
+	// uses, becomes <Name>Request without the readOnly fields and
+	// <Name>Response without the writeOnly fields, each referring to the
+	// Request or Response form of the other messages. Fields keep their
+	// numbers in both. Messages without such fields are left as they are.
+	SplitReadWrite bool
+
+	// PackagePerDefinition, for output split into one file per definition
+	// (ConvertToFiles, SplitProtoFile), puts each file in its own package
+	// nested under PackageName, e.g. schema.order for order.proto, and
+	// qualifies references between files. With GoPackage set, each file's
+	// go_package becomes a subdirectory of it named after the file, e.g.
+	// example.com/gen/order, so each proto package is its own Go package.
+	// Single-file output is unaffected.
+	PackagePerDefinition bool
+
+	// DedupeMessages merges inline messages that are structurally identical,
+	// i.e. have the same fields with the same names, types and numbers, into
+	// a single message named after whichever sorts first, and updates the
+	// fields referring to them. Messages for the root and for definitions
+	// are never merged.
+	DedupeMessages bool
+
+	// OmitEmptyMessages drops messages that have no fields, including the
+	// root message of a schema with empty properties, unless another message
+	// or the generated service refers to them
+	OmitEmptyMessages bool
+
+	// EmitSourceComments ends the comment of every message and enum with a
+	// "source: <location>" line naming the schema it was generated from,
+	// such as source: common.json#/definitions/Money. Schemas within a
+	// bundled resource with an $id are located by its URI; others by the
+	// document's $id or, without one, by SourceName, e.g. the input file
+	// name. OpenAPI component schemas are located under
+	// #/components/schemas.
+	EmitSourceComments bool
+	SourceName         string
+
+	// CommentStyle controls how descriptions are rendered as comments. The
+	// zero value behaves like CommentStyleLeading.
+	CommentStyle CommentStyle
+
+	// Syntax selects the generated syntax: "proto3" (the default) or
+	// "proto2". In proto2 output singular fields are labelled optional and
+	// repeated numeric, bool and enum fields get [packed = true], which proto3
+	// applies by default.
+	Syntax string
+
+	// Indent is the string used for one level of indentation, such as four
+	// spaces or "\t". It defaults to two spaces.
+	Indent string
+
+	// FileHeader is a comment placed at the top of the file, before the
+	// syntax statement, such as a "Code generated ... DO NOT EDIT." banner.
+	// Each line is rendered as a // comment.
+	FileHeader string
+
+	// EmitOrder controls the order of messages and enums in the output. The
+	// zero value behaves like EmitOrderAlphabetical.
+	EmitOrder EmitOrder
+}
+
+// CommentStyle selects how descriptions are rendered in the generated proto
+type CommentStyle string
+
+const (
+	// CommentStyleLeading puts "// " comment lines above the element
+	CommentStyleLeading CommentStyle = "leading"
+	// CommentStyleTrailing puts short single-line field descriptions after
+	// the field ("field = 1; // desc"); longer descriptions, and those of
+	// messages and enums, fall back to leading comments
+	CommentStyleTrailing CommentStyle = "trailing"
+	// CommentStyleBlock uses "/* ... */" comments above the element
+	CommentStyleBlock CommentStyle = "block"
+)
+
+// EmitOrder selects the order in which messages and enums are written
+type EmitOrder string
+
+const (
+	// EmitOrderAlphabetical writes the root message first, then the other
+	// messages and finally the enums, each sorted by name
+	EmitOrderAlphabetical EmitOrder = "alphabetical"
+	// EmitOrderTopological writes enums first and then each message after
+	// the messages its fields refer to, so the file reads top-down. Messages
+	// that refer to each other in a cycle are written together in
+	// alphabetical order.
+	EmitOrderTopological EmitOrder = "topological"
+)
+
+// FreeFormType selects the well-known type used for arbitrary JSON values
+type FreeFormType string
+
+const (
+	// FreeFormAny uses google.protobuf.Any, warning about untyped schemas
+	// since Any holds a packed proto message rather than a JSON value
+	FreeFormAny FreeFormType = "any"
+	// FreeFormValue uses google.protobuf.Value, which holds any JSON value
+	// and round-trips through the proto JSON mapping
+	FreeFormValue FreeFormType = "value"
+	// FreeFormStruct uses google.protobuf.Struct, which holds a JSON object
+	FreeFormStruct FreeFormType = "struct"
+)
+
+// AllOfStyle selects how allOf compositions with $ref members are converted
+type AllOfStyle string
+
+const (
+	// AllOfFlatten merges the properties of every allOf member, including
+	// $ref bases, into a single message
+	AllOfFlatten AllOfStyle = "flatten"
+	// AllOfCompose embeds each $ref base as a field of the composed
+	// message, named base, or base_<type> when there are several, and
+	// merges only the other members. The base fields are numbered first.
+	AllOfCompose AllOfStyle = "compose"
+)
+
+// schemaID returns a schema's $id, falling back to the draft-04 id keyword
+func schemaID(schema map[string]interface{}) string {
+	if id, ok := schema["$id"].(string); ok {
+		return id
+	}
+	id, _ := schema["id"].(string)
+	return id
+}
+
+// packageFromID derives a proto package from the last path segment of a
+// schema id URI, dropping a .json extension. Dots separate package
+// components; other characters that aren't legal in a package are replaced
+// with underscores. It returns "" when the id has no usable name.
+func packageFromID(id string) string {
+	if u, err := url.Parse(id); err == nil {
+		id = u.Path
+		if id == "" {
+			id = u.Opaque
+		}
+	}
+	segments := strings.FieldsFunc(id, func(r rune) bool { return r == '/' || r == ':' })
+	if len(segments) == 0 {
+		return ""
+	}
+	name := strings.TrimSuffix(segments[len(segments)-1], ".json")
+
+	var parts []string
+	for _, part := range strings.Split(name, ".") {
+		part = nonFieldNameChars.ReplaceAllString(strings.ToLower(part), "_")
+		if part == "" {
+			continue
+		}
+		if leadingDigits.MatchString(part) {
+			part = "_" + part
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ".")
 }
 
+// DefaultMaxDepth is the nesting limit used when Options.MaxDepth is zero
+const DefaultMaxDepth = 64
+
 // DefaultOptions returns the default options for the converter
 func DefaultOptions() *Options {
+	mappings := make(map[string]string, len(defaultTypeMappings))
+	for jsonType, protoType := range defaultTypeMappings {
+		mappings[jsonType] = protoType
+	}
 	return &Options{
-		PackageName: "schema",
-		TypeMappings: map[string]string{
-			"string":  "string",
-			"integer": "int32",
-			"number":  "double",
-			"boolean": "bool",
-			"array":   "repeated",
-			"object":  "message",
-		},
+		PackageName:  "schema",
+		TypeMappings: mappings,
 	}
 }
 
 // ConvertJSONSchemaToProto converts a JSON Schema to Protocol Buffers format
 func ConvertJSONSchemaToProto(schemaStr string, opts *Options) (string, error) {
+	return ConvertJSONSchemaToProtoContext(context.Background(), schemaStr, opts)
+}
+
+// ConvertJSONSchemaToProtoContext is like ConvertJSONSchemaToProto but stops
+// and returns ctx.Err() as soon as ctx is canceled or its deadline passes.
+// The context is checked before each definition and property is converted,
+// and once more before the result is returned.
+func ConvertJSONSchemaToProtoContext(ctx context.Context, schemaStr string, opts *Options) (string, error) {
+	return convertBytes(ctx, []byte(schemaStr), opts)
+}
+
+// ConvertBytes is like ConvertJSONSchemaToProto but decodes the schema
+// straight from data, such as a request body, without copying it into a
+// string first
+func ConvertBytes(data []byte, opts *Options) (string, error) {
+	return convertBytes(context.Background(), data, opts)
+}
+
+// convertBytes converts the JSON Schema in data to .proto source
+func convertBytes(ctx context.Context, data []byte, opts *Options) (string, error) {
+	file, err := parseProtoFile(ctx, data, opts, nil)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	// bytes.Buffer never returns a write error
+	_ = WriteProtoFile(&buf, file)
+	return buf.String(), nil
+}
+
+// ConvertWithReport is like ConvertJSONSchemaToProto but also returns the
+// warnings raised during the conversion, instead of printing them with the
+// standard logger. They are still sent to Options.Logger when it is set.
+// Warnings raised before a conversion error are returned with the error.
+func ConvertWithReport(schemaStr string, opts *Options) (string, []Warning, error) {
+	var warnings []Warning
+	file, err := parseProtoFile(context.Background(), []byte(schemaStr), opts, &warnings)
+	if err != nil {
+		return "", warnings, err
+	}
+	var buf bytes.Buffer
+	// bytes.Buffer never returns a write error
+	_ = WriteProtoFile(&buf, file)
+	return buf.String(), warnings, nil
+}
+
+// ConvertFile converts the JSON Schema stored in the named file. Conversion
+// errors are prefixed with the path and wrap the underlying error.
+func ConvertFile(path string, opts *Options) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading schema file: %w", err)
+	}
+	proto, err := ConvertBytes(data, opts)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return proto, nil
+}
+
+// ConvertReader converts a JSON Schema read in full from r
+func ConvertReader(r io.Reader, opts *Options) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("reading schema: %w", err)
+	}
+	return ConvertBytes(data, opts)
+}
+
+// WriteProto converts a JSON Schema to Protocol Buffers format, streaming the
+// generated .proto source to w
+func WriteProto(w io.Writer, schemaStr string, opts *Options) error {
+	file, err := BuildProtoFile(schemaStr, opts)
+	if err != nil {
+		return err
+	}
+	return WriteProtoFile(w, file)
+}
+
+// WriteProtoBytes is like WriteProto but decodes the schema straight from
+// data
+func WriteProtoBytes(w io.Writer, data []byte, opts *Options) error {
+	file, err := parseProtoFile(context.Background(), data, opts, nil)
+	if err != nil {
+		return err
+	}
+	return WriteProtoFile(w, file)
+}
+
+// BuildProtoFile converts a JSON Schema into the intermediate ProtoFile representation
+func BuildProtoFile(schemaStr string, opts *Options) (*ProtoFile, error) {
+	return parseProtoFile(context.Background(), []byte(schemaStr), opts, nil)
+}
+
+// parseProtoFile decodes and converts a JSON Schema, stopping if ctx is done
+func parseProtoFile(ctx context.Context, data []byte, opts *Options, report *[]Warning) (*ProtoFile, error) {
 	if opts == nil {
 		opts = DefaultOptions()
 	}
 
 	var schema map[string]interface{}
-	if err := json.Unmarshal([]byte(schemaStr), &schema); err != nil {
-		return "", fmt.Errorf("failed to parse JSON schema: %v", err)
+	if err := unmarshalJSON(data, &schema); err != nil {
+		return nil, err
 	}
+	return buildProtoFile(ctx, schema, opts, report, "")
+}
 
-	var proto strings.Builder
-	proto.WriteString("syntax = \"proto3\";\n\n")
-	proto.WriteString(fmt.Sprintf("package %s;\n\n", opts.PackageName))
+// buildProtoFile converts an already-decoded JSON Schema document. Warnings
+// are appended to report when it is non-nil. sourcePrefix, when set, is the
+// pointer prefix source comments use for definitions lifted from another
+// location of the original document, such as #/components/schemas/.
+func buildProtoFile(ctx context.Context, schema map[string]interface{}, opts *Options, report *[]Warning, sourcePrefix string) (*ProtoFile, error) {
+	switch opts.FreeFormType {
+	case "", FreeFormAny, FreeFormValue, FreeFormStruct:
+	default:
+		return nil, fmt.Errorf("unsupported free-form type %q", opts.FreeFormType)
+	}
+	switch opts.AllOfStyle {
+	case "", AllOfFlatten, AllOfCompose:
+	default:
+		return nil, fmt.Errorf("unsupported allOf style %q", opts.AllOfStyle)
+	}
+	if opts.Flatten && opts.GenerateService {
+		return nil, errors.New("Flatten can't be combined with GenerateService")
+	}
+	c := &conversion{
+		ctx:           ctx,
+		opts:          opts,
+		schema:        schema,
+		messages:      make(map[string]*Message),
+		enums:         make(map[string]*Enum),
+		inlineEnums:   make(map[string]string),
+		wrappers:      make(map[string]string),
+		resolving:     make(map[string]bool),
+		resolved:      make(map[string]fieldType),
+		aliasCycles:   make(map[string]bool),
+		defNames:      make(map[string]string),
+		allOfBases:    make(map[string][]fieldType),
+		renamed:       make(map[string]bool),
+		inlineSources: make(map[string]string),
+		report:        report,
+		sourcePrefix:  sourcePrefix,
+		access:        make(map[*Field]string),
+	}
+	draft, err := c.schemaDraft(schema)
+	if err != nil {
+		return nil, err
+	}
+	c.draft = draft
+	schema, c.defsHoisted = hoistDefs(schema, draft)
+	c.schema = schema
+	c.indexResources()
+	c.rootName = c.rootMessageName(schema)
+	if err := c.convertSchema(schema); err != nil {
+		return nil, err
+	}
+	if len(c.errs) > 0 {
+		return nil, &ConversionError{Errors: c.errs}
+	}
+	if opts.DedupeMessages {
+		c.dedupeMessages()
+	}
+	if opts.SplitReadWrite {
+		if err := c.splitReadWrite(); err != nil {
+			return nil, err
+		}
+	}
 
-	// Collect message definitions
-	messages := make(map[string]string)
+	// Emit messages in sorted order, the root message first if present. The comparison
+	// is a strict total order so output never depends on map iteration.
+	msgNames := make([]string, 0, len(c.messages))
+	for k := range c.messages {
+		msgNames = append(msgNames, k)
+	}
+	sort.Slice(msgNames, func(i, j int) bool {
+		if (msgNames[i] == c.rootName) != (msgNames[j] == c.rootName) {
+			return msgNames[i] == c.rootName
+		}
+		return msgNames[i] < msgNames[j]
+	})
 
-	// Generate root message fields (if any)
-	rootFields := &strings.Builder{}
-	fieldNumber := 1
-	rootMsgComment := ""
-	if desc, ok := schema["description"].(string); ok && desc != "" {
-		rootMsgComment = formatDescription(desc)
-	}
-	if props, ok := schema["properties"].(map[string]interface{}); ok {
-		keys := make([]string, 0, len(props))
-		for k := range props {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-		for _, name := range keys {
-			prop := props[name]
-			// Add field description if present
-			if propMap, ok := prop.(map[string]interface{}); ok {
-				if desc, ok := propMap["description"].(string); ok && desc != "" {
-					rootFields.WriteString(formatDescription(desc))
+	syntax := opts.Syntax
+	switch syntax {
+	case "":
+		syntax = "proto3"
+	case "proto2", "proto3":
+	default:
+		return nil, fmt.Errorf("unsupported syntax %q", syntax)
+	}
+	if syntax == "proto2" {
+		c.packRepeatedScalars()
+	}
+
+	pkg := opts.PackageName
+	if opts.DeriveNamingFromId {
+		if derived := packageFromID(schemaID(schema)); derived != "" {
+			pkg = derived
+		}
+	}
+
+	file := &ProtoFile{Syntax: syntax, Package: pkg, Header: opts.FileHeader, CommentStyle: opts.CommentStyle, Indent: opts.Indent}
+	file.Options = buildFileOptions(opts)
+	for _, name := range msgNames {
+		file.Messages = append(file.Messages, c.messages[name])
+	}
+	enumNames := make([]string, 0, len(c.enums))
+	for k := range c.enums {
+		enumNames = append(enumNames, k)
+	}
+	sort.Strings(enumNames)
+	for _, name := range enumNames {
+		file.Enums = append(file.Enums, c.enums[name])
+	}
+	if opts.GenerateService {
+		file.Services = append(file.Services, c.buildService(file.Messages))
+	}
+	if opts.OmitEmptyMessages {
+		file.Messages = omitEmptyMessages(file.Messages, file.Services)
+	}
+	switch opts.EmitOrder {
+	case "", EmitOrderAlphabetical:
+	case EmitOrderTopological:
+		file.Messages = topologicalOrder(file.Messages)
+		file.EnumsFirst = true
+	default:
+		return nil, fmt.Errorf("unsupported emit order %q", opts.EmitOrder)
+	}
+	if opts.EmitSourceComments {
+		c.addSourceComments(file)
+	}
+	if opts.NestEnums {
+		nestEnums(file)
+	}
+	if c.flatten() {
+		nestTypes(file, c.rootName)
+	}
+	file.Imports = collectImports(file, opts.Imports, opts.TypeImports)
+	if opts.Transform != nil {
+		if err := opts.Transform(file); err != nil {
+			return nil, fmt.Errorf("transform: %w", err)
+		}
+		// Pick up well-known types the transform introduced
+		file.Imports = collectImports(file, file.Imports, opts.TypeImports)
+	}
+	// Don't report success when ctx was canceled after the last check
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// nestTypes moves every message and enum of file other than the root message
+// into the root message, keeping their order
+func nestTypes(file *ProtoFile, rootName string) {
+	var root *Message
+	nested := make([]*Message, 0, len(file.Messages))
+	for _, msg := range file.Messages {
+		if msg.Name == rootName {
+			root = msg
+		} else {
+			nested = append(nested, msg)
+		}
+	}
+	if root == nil {
+		return
+	}
+	root.Messages = append(root.Messages, nested...)
+	root.Enums = append(root.Enums, file.Enums...)
+	file.Messages = []*Message{root}
+	file.Enums = nil
+}
+
+// nestEnums moves every top-level enum of file that is the type of fields
+// in exactly one message into that message, keeping their order
+func nestEnums(file *ProtoFile) {
+	users := make(map[string][]*Message)
+	for _, msg := range allMessages(file.Messages) {
+		seen := make(map[string]bool)
+		for _, field := range msg.Fields {
+			for _, typ := range referencedTypes(field.Type) {
+				if !seen[typ] {
+					seen[typ] = true
+					users[typ] = append(users[typ], msg)
 				}
 			}
-			fieldType, err := processPropertyCollect(name, prop, messages, opts)
-			if err != nil {
-				return "", err
+		}
+	}
+
+	kept := file.Enums[:0]
+	for _, enum := range file.Enums {
+		if msgs := users[enum.Name]; len(msgs) == 1 {
+			msgs[0].Enums = append(msgs[0].Enums, enum)
+		} else {
+			kept = append(kept, enum)
+		}
+	}
+	file.Enums = kept
+}
+
+// packRepeatedScalars adds [packed = true] to repeated numeric, bool and enum
+// fields, which proto2 doesn't pack unless asked to
+func (c *conversion) packRepeatedScalars() {
+	for _, msg := range c.messages {
+		for _, field := range msg.Fields {
+			if !field.Repeated || hasFieldOption(field, "packed") {
+				continue
 			}
-			if fieldType != "" {
-				rootFields.WriteString(fmt.Sprintf("  %s %s = %d;\n", fieldType, SanitizeFieldName(name), fieldNumber))
-				fieldNumber++
+			_, isEnum := c.enums[field.Type]
+			if isEnum || scalarTypes[field.Type] && field.Type != "string" && field.Type != "bytes" {
+				field.Options = append(field.Options, FieldOption{Name: "packed", Value: "true"})
+			}
+		}
+	}
+}
+
+// hasFieldOption reports whether field already sets the named option
+func hasFieldOption(field *Field, name string) bool {
+	for _, opt := range field.Options {
+		if opt.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// omitEmptyMessages drops messages without fields, keeping those still used
+// as a field type or as an rpc input or output
+func omitEmptyMessages(messages []*Message, services []*Service) []*Message {
+	used := make(map[string]bool)
+	for _, msg := range messages {
+		for _, field := range msg.Fields {
+			for _, typ := range referencedTypes(field.Type) {
+				used[typ] = true
 			}
 		}
-		messages["Root"] = fmt.Sprintf("%smessage Root {\n%s}\n", rootMsgComment, rootFields.String())
+	}
+	for _, svc := range services {
+		for _, m := range svc.Methods {
+			used[m.Input] = true
+			used[m.Output] = true
+		}
+	}
+
+	kept := messages[:0]
+	for _, msg := range messages {
+		if len(msg.Fields) > 0 || used[msg.Name] {
+			kept = append(kept, msg)
+		}
+	}
+	return kept
+}
+
+// buildFileOptions collects the go_package option and any custom file options,
+// formatting their values for output
+func buildFileOptions(opts *Options) []FileOption {
+	var fileOpts []FileOption
+	index := make(map[string]int)
+	add := func(name, value string) {
+		if i, ok := index[name]; ok {
+			fileOpts[i].Value = value
+			return
+		}
+		index[name] = len(fileOpts)
+		fileOpts = append(fileOpts, FileOption{Name: name, Value: value})
+	}
+
+	if opts.GoPackage != "" {
+		add("go_package", quoteProtoString(opts.GoPackage))
+	}
+	for _, opt := range opts.FileOptions {
+		add(opt.Name, formatOptionValue(opt.Value))
+	}
+	return fileOpts
+}
+
+// enumConstant matches option values that name an enum constant
+var enumConstant = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// formatOptionValue quotes an option value unless it is already a literal
+// that proto accepts unquoted
+func formatOptionValue(value string) string {
+	if value == "true" || value == "false" || enumConstant.MatchString(value) {
+		return value
+	}
+	if strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) && len(value) >= 2 {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return quoteProtoString(value)
+}
+
+// conversion holds the state of a single schema conversion. A new conversion
+// is created for every call so concurrent conversions never share state.
+type conversion struct {
+	// ctx is checked during the traversal so long conversions can be canceled
+	ctx      context.Context
+	opts     *Options
+	rootName string
+	schema   map[string]interface{}
+	messages map[string]*Message
+	enums    map[string]*Enum
+	// inlineEnums maps the value set of each generated inline enum to its
+	// name so identical inline enums share one type
+	inlineEnums map[string]string
+	// wrappers maps repeated and map types to the message generated to wrap
+	// them
+	wrappers map[string]string
+	// resolving holds the JSON pointers of $ref targets being converted, to
+	// detect references that lead back to themselves
+	resolving map[string]bool
+	// resolved caches the type of each $ref target already converted, keyed
+	// by its canonical JSON pointer
+	resolved map[string]fieldType
+	// aliasCycles holds the JSON pointers of definitions that alias each
+	// other in a cycle, already reported by checkAliasCycles
+	aliasCycles map[string]bool
+	// reservedTypes holds the names of the definitions' types and of the
+	// root message, which inline schemas' types can't take, and renamed
+	// the paths of the inline schemas reported as renamed for that reason
+	reservedTypes map[string]bool
+	renamed       map[string]bool
+	// inlineSources maps the name of each type generated for an inline
+	// schema to the JSON pointer of that schema, so a different schema
+	// wanting the same name gets another one
+	inlineSources map[string]string
+	// sourcePrefix locates lifted definitions in source comments, as passed
+	// to buildProtoFile
+	sourcePrefix string
+	// allOfBases holds, by the JSON pointer of the composing schema, the
+	// types of the allOf bases embedded as fields with AllOfCompose
+	allOfBases map[string][]fieldType
+	// defNames maps definitions whose names collide with an earlier
+	// definition to the distinct type name generated for them
+	defNames map[string]string
+	errs     []*PathError
+	// report, when set, collects the warnings raised by the conversion
+	report *[]Warning
+	// draft is the JSON Schema draft the schema is interpreted as
+	draft Draft
+	// resources maps the URI of every subschema with an $id to its
+	// reference tokens, and resourceURIs the JSON pointer of each such
+	// subschema to its URI
+	resources    map[string][]string
+	resourceURIs map[string]string
+	// defsHoisted records that the root $defs were merged into definitions
+	defsHoisted bool
+	// depth is the number of processPropertyCollect calls in progress
+	depth int
+	// access records the fields of readOnly and writeOnly properties for
+	// SplitReadWrite
+	access map[*Field]string
+}
+
+// errFailFast is returned internally to unwind the traversal after the first
+// error when Options.FailFast is set
+var errFailFast = errors.New("conversion stopped at first error")
+
+// addError records a conversion error at path. It returns errFailFast when the
+// conversion should stop immediately.
+func (c *conversion) addError(path, message string, err error) error {
+	c.errs = append(c.errs, &PathError{Path: path, Message: message, Err: err})
+	if c.opts.FailFast {
+		return errFailFast
+	}
+	return nil
+}
+
+// warnf reports a non-fatal conversion problem at path. It is added to the
+// report when one is being collected and logged to Options.Logger; without a
+// report or a Logger it goes to the standard logger.
+func (c *conversion) warnf(path string, code WarningCode, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if c.report != nil {
+		*c.report = append(*c.report, Warning{Path: path, Code: code, Message: msg})
+	}
+	switch {
+	case c.opts.Logger != nil:
+		c.opts.Logger.LogAttrs(c.ctx, slog.LevelWarn, msg, slog.String("path", path))
+	case c.report == nil:
+		log.Printf("warning: %s: %s", path, msg)
+	}
+}
+
+// debugf reports conversion progress at path. It is only logged through
+// Options.Logger.
+func (c *conversion) debugf(path, format string, args ...interface{}) {
+	if c.opts.Logger != nil {
+		c.opts.Logger.LogAttrs(c.ctx, slog.LevelDebug, fmt.Sprintf(format, args...), slog.String("path", path))
+	}
+}
+
+// recordError records err, keeping the more precise location when err is
+// already a PathError raised deeper in the property
+func (c *conversion) recordError(path, message string, err error) error {
+	var pathErr *PathError
+	if errors.As(err, &pathErr) {
+		path, err = pathErr.Path, pathErr.Err
+	}
+	return c.addError(path, message, err)
+}
+
+// convertSchema generates messages for the root properties and definitions
+func (c *conversion) convertSchema(schema map[string]interface{}) error {
+	defs, _ := schema["definitions"].(map[string]interface{})
+	defNames := make([]string, 0, len(defs))
+	for defName := range defs {
+		defNames = append(defNames, defName)
+	}
+	sort.Strings(defNames)
+	// Only definitions of messages and enums are named; the rest are
+	// converted in place
+	var typeNames []string
+	for _, defName := range defNames {
+		if definesType(defs[defName]) {
+			typeNames = append(typeNames, defName)
+		}
+	}
+	// Name definitions up front so references from the root resolve to them
+	c.disambiguateDefinitions(typeNames)
+	// Types generated for inline schemas can't take the names of definitions
+	// or of the root message
+	c.reservedTypes = map[string]bool{c.rootName: c.hasRootMessage()}
+	if !c.flatten() {
+		for _, defName := range typeNames {
+			c.reservedTypes[c.definitionName(defName)] = true
+		}
+	}
+
+	if !c.flatten() {
+		if err := c.checkAliasCycles(defs, defNames); err != nil {
+			return c.stop(err)
+		}
+	}
+
+	// Generate root message fields (if any)
+	root, err := c.flattenAllOf(c.rootName, schema, "#")
+	if err != nil {
+		if err := c.recordError("#", c.rootName, err); err != nil {
+			return c.stop(err)
+		}
+		root = schema
+	}
+	if err := checkProperties(root, "#"); err != nil {
+		if err := c.recordError("#", c.rootName, err); err != nil {
+			return c.stop(err)
+		}
+	}
+	if props, ok := root["properties"].(map[string]interface{}); ok {
+		c.checkRequired(root, "#", c.rootName)
+		fields, err := c.collectFields(props, "#", c.rootName)
+		if err != nil {
+			return c.stop(err)
+		}
+		desc, _ := root["description"].(string)
+		if isConditional(root) {
+			c.warnf("#", WarnConditional, "%s has if/then/else subschemas, which aren't represented; only its own properties are generated", c.rootName)
+			desc = appendComment(desc, conditionalNote)
+		}
+		desc = appendComment(desc, c.dependencyNotes(root, "#", c.rootName)...)
+		msg := &Message{Name: c.rootName, Comment: desc, Options: messageOptions(root), Fields: fields, Source: "#"}
+		if err := c.applyVendorExtensions(msg, root, "#"); err != nil {
+			if err := c.recordError("#", c.rootName, err); err != nil {
+				return c.stop(err)
+			}
+		}
+		c.messages[c.rootName] = msg
+	} else if ref, ok := root["$ref"].(string); ok && !c.opts.WrapRootRef && !c.opts.Flatten {
+		// The root is an alias for the referenced type, which only needs
+		// to be generated
+		if _, err := c.resolveRef(ref, "#"); err != nil {
+			if c.aborted(err) {
+				return err
+			}
+			if err := c.recordError("#", c.rootName, err); err != nil {
+				return c.stop(err)
+			}
+		}
+	} else if c.hasRootMessage() {
+		fieldName := "value"
+		if rootType, _ := schemaType(root); rootType == "array" {
+			fieldName = c.opts.RootArrayFieldName
+			if fieldName == "" {
+				fieldName = "items"
+			}
+		}
+		if err := c.wrapRoot(fieldName, root); err != nil {
+			return c.stop(err)
+		}
+	}
+
+	// Flattened output only has the definitions reached through $refs,
+	// which were converted in place
+	if c.flatten() {
+		return nil
 	}
 
 	// Process definitions
-	if defs, ok := schema["definitions"].(map[string]interface{}); ok {
-		defNames := make([]string, 0, len(defs))
-		for defName := range defs {
-			defNames = append(defNames, defName)
-		}
-		sort.Strings(defNames)
-		for _, defName := range defNames {
-			def := defs[defName]
-			if defMap, ok := def.(map[string]interface{}); ok {
-				fields := &strings.Builder{}
-				fieldNumber := 1
-				if props, ok := defMap["properties"].(map[string]interface{}); ok {
-					keys := make([]string, 0, len(props))
-					for k := range props {
-						keys = append(keys, k)
-					}
-					sort.Strings(keys)
-					for _, propName := range keys {
-						prop := props[propName]
-						// Add field description if present
-						if propMap, ok := prop.(map[string]interface{}); ok {
-							if desc, ok := propMap["description"].(string); ok && desc != "" {
-								fields.WriteString(formatDescription(desc))
-							}
-						}
-						fieldType, err := processPropertyCollect(propName, prop, messages, opts)
-						if err != nil {
-							return "", err
-						}
-						if fieldType != "" {
-							fields.WriteString(fmt.Sprintf("  %s %s = %d;\n", fieldType, SanitizeFieldName(propName), fieldNumber))
-							fieldNumber++
-						}
-					}
+	for _, defName := range defNames {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		def := defs[defName]
+		if defMap, ok := def.(map[string]interface{}); ok && isResourceContainer(defMap) {
+			continue
+		}
+		if !definesType(def) {
+			// Convert it as its references would, so that errors such as
+			// aliases referring to each other in a cycle are reported
+			defPath := pointerJoin("#/definitions", defName)
+			if _, err := c.convertTarget([]string{"definitions", defName}, def, defPath, defPath); err != nil {
+				if c.aborted(err) {
+					return err
 				}
-				msgComment := ""
-				// Add message description if present
-				if desc, ok := defMap["description"].(string); ok && desc != "" {
-					msgComment = formatDescription(desc)
+				if err := c.recordError(defPath, defName, err); err != nil {
+					return c.stop(err)
 				}
-				messages[defName] = fmt.Sprintf("%smessage %s {\n%s}\n", msgComment, defName, fields.String())
 			}
+			continue
+		}
+		if defMap, ok := def.(map[string]interface{}); ok {
+			defPath := pointerJoin("#/definitions", defName)
+			typeName := c.definitionName(defName)
+			c.debugf(defPath, "converting definition %s as %s", defName, typeName)
+			defMap, err := c.flattenAllOf(typeName, defMap, defPath)
+			if err != nil {
+				if err := c.recordError(defPath, typeName, err); err != nil {
+					return c.stop(err)
+				}
+				continue
+			}
+			// Add message description if present
+			desc, _ := defMap["description"].(string)
+			if values, ok := stringEnumValues(defMap); ok {
+				c.enums[typeName] = c.buildEnum(typeName, desc, values)
+				c.enums[typeName].Source = defPath
+				continue
+			}
+			if values, ok := integerEnumValues(defMap); ok {
+				c.enums[typeName] = c.buildIntegerEnum(typeName, desc, values)
+				c.enums[typeName].Source = defPath
+				continue
+			}
+			if isConditional(defMap) {
+				c.warnf(defPath, WarnConditional, "%s has if/then/else subschemas, which aren't represented; only its own properties are generated", typeName)
+				desc = appendComment(desc, conditionalNote)
+			}
+			desc = appendComment(desc, c.dependencyNotes(defMap, defPath, typeName)...)
+			c.checkRequired(defMap, defPath, typeName)
+			if err := checkProperties(defMap, defPath); err != nil {
+				if err := c.recordError(defPath, typeName, err); err != nil {
+					return c.stop(err)
+				}
+			}
+			var fields []*Field
+			if props, ok := defMap["properties"].(map[string]interface{}); ok {
+				var err error
+				fields, err = c.collectFields(props, defPath, typeName)
+				if err != nil {
+					return c.stop(err)
+				}
+			}
+			msg := &Message{Name: typeName, Comment: desc, Options: messageOptions(defMap), Fields: fields, Source: defPath}
+			if err := c.applyVendorExtensions(msg, defMap, defPath); err != nil {
+				if err := c.recordError(defPath, typeName, err); err != nil {
+					return c.stop(err)
+				}
+			}
+			c.messages[typeName] = msg
 		}
 	}
+	return nil
+}
 
-	// Emit messages in sorted order, Root first if present
-	msgNames := make([]string, 0, len(messages))
-	for k := range messages {
-		msgNames = append(msgNames, k)
+// flatten reports whether the output is a single root message with every
+// other type nested within it, which Flatten asks for when there is a root
+// message
+func (c *conversion) flatten() bool {
+	return c.opts.Flatten && c.hasRootMessage()
+}
+
+// hasRootMessage reports whether the schema produces a root message: it has
+// properties, or it is an array, a scalar or, with WrapRootRef or Flatten, a
+// $ref that is wrapped in one
+func (c *conversion) hasRootMessage() bool {
+	if _, ok := c.schema["properties"].(map[string]interface{}); ok {
+		return true
+	}
+	if _, ok := c.schema["$ref"].(string); ok {
+		return c.opts.WrapRootRef || c.opts.Flatten
+	}
+	switch rootType, _ := schemaType(c.schema); rootType {
+	case "array", "string", "integer", "number", "boolean":
+		return true
+	}
+	return false
+}
+
+// wrapRoot generates the root message for a schema without properties, such
+// as a top-level array or scalar, as a message with a single field named
+// fieldName holding the whole document
+func (c *conversion) wrapRoot(fieldName string, root map[string]interface{}) error {
+	desc, _ := root["description"].(string)
+	msg := &Message{Name: c.rootName, Comment: desc, Options: messageOptions(root), Source: "#"}
+	// Reserve the name so a nested type can't take it
+	c.messages[c.rootName] = msg
+	ft, err := c.processPropertyCollect(fieldName, root, "#")
+	if err != nil {
+		if c.aborted(err) {
+			return err
+		}
+		return c.recordError("#", c.rootName, err)
+	}
+	if ft.name != "" {
+		msg.Fields = []*Field{{
+			Name:     c.fieldName(fieldName),
+			Type:     ft.name,
+			Number:   1,
+			Repeated: ft.repeated,
+			Optional: ft.optional,
+			Comment:  appendComment("", ft.notes...),
+			Options:  ft.options,
+		}}
 	}
-	sort.Strings(msgNames)
-	// Move 'Root' to the front if present
-	if len(msgNames) > 0 {
-		for i, n := range msgNames {
-			if n == "Root" && i != 0 {
-				msgNames[0], msgNames[i] = msgNames[i], msgNames[0]
+	return nil
+}
+
+// definitionKey folds a definition name so that names differing only in case
+// or underscores, such as order and Order or fooBar and foo_bar, compare equal
+func definitionKey(name string) string {
+	return strings.ToLower(toProtoMessageName(name))
+}
+
+// disambiguateDefinitions gives each definition in sorted names whose name
+// collides with an earlier one, per definitionKey, a distinct type name by
+// appending the smallest free number, so the first definition keeps its name
+// and the output stays deterministic
+func (c *conversion) disambiguateDefinitions(names []string) {
+	taken := make(map[string]string, len(names))
+	for _, name := range names {
+		if _, ok := taken[definitionKey(name)]; !ok {
+			taken[definitionKey(name)] = name
+		}
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		key := definitionKey(name)
+		if !seen[key] {
+			seen[key] = true
+			continue
+		}
+		typeName := name
+		for n := 2; ; n++ {
+			typeName = name + strconv.Itoa(n)
+			if _, ok := taken[definitionKey(typeName)]; !ok {
 				break
 			}
 		}
+		taken[definitionKey(typeName)] = typeName
+		c.defNames[name] = typeName
+		c.warnf(pointerJoin("#/definitions", name), WarnNameCollision, "definition %q collides with %q; generating it as %s", name, taken[key], typeName)
 	}
-	for _, name := range msgNames {
-		proto.WriteString(messages[name])
-		if !strings.HasSuffix(messages[name], "\n") {
-			proto.WriteString("\n")
+}
+
+// inlineTypeName returns the name of the type generated for an inline schema
+// at path, which would be name. When a definition, the root message or a type
+// generated for a different schema has that name, the smallest number that
+// makes it free is appended. Definitions and the root message always keep
+// their names, so the choice doesn't depend on the order in which they are
+// converted; among inline schemas the first converted keeps the name. A
+// schema converted again gets the same name, and so reuses its type.
+func (c *conversion) inlineTypeName(name, path string) string {
+	typeName := name
+	for n := 2; c.typeTaken(typeName, path); n++ {
+		typeName = name + strconv.Itoa(n)
+	}
+	if _, ok := c.inlineSources[typeName]; !ok {
+		c.inlineSources[typeName] = path
+	}
+	if typeName != name && path != "" && !c.renamed[path] {
+		c.renamed[path] = true
+		switch source, ok := c.inlineSources[name]; {
+		case c.reservedTypes[name]:
+			c.warnf(path, WarnNameCollision, "%s would take the name of a definition or the root message; generating it as %s", name, typeName)
+		case ok:
+			c.warnf(path, WarnNameCollision, "%s is already generated for %s; generating it as %s", name, source, typeName)
+		default:
+			c.warnf(path, WarnNameCollision, "%s is already the name of a generated wrapper message; generating it as %s", name, typeName)
+		}
+	}
+	return typeName
+}
+
+// typeTaken reports whether name belongs to a type other than the one
+// generated for the inline schema at path
+func (c *conversion) typeTaken(name, path string) bool {
+	if c.reservedTypes[name] {
+		return true
+	}
+	if source, ok := c.inlineSources[name]; ok {
+		return source != path
+	}
+	return c.messages[name] != nil || c.enums[name] != nil
+}
+
+// definitionName returns the type name generated for a definition
+func (c *conversion) definitionName(name string) string {
+	if typeName, ok := c.defNames[name]; ok {
+		name = typeName
+	}
+	if c.opts.PascalCaseDefinitions {
+		return toProtoMessageName(name)
+	}
+	return name
+}
+
+// aborted reports whether err unwinds the whole traversal rather than
+// belonging to a single property: a fail-fast stop or a canceled context
+func (c *conversion) aborted(err error) bool {
+	return err == errFailFast || err == c.ctx.Err()
+}
+
+// stop converts the internal fail-fast sentinel into the recorded errors
+func (c *conversion) stop(err error) error {
+	if err == errFailFast {
+		return &ConversionError{Errors: c.errs}
+	}
+	return err
+}
+
+// checkProperties returns an error locating the properties keyword of an
+// object schema when it isn't an object, such as a list of property
+// schemas, which would otherwise leave the message without fields
+func checkProperties(schema map[string]interface{}, path string) error {
+	props, ok := schema["properties"]
+	if !ok {
+		return nil
+	}
+	if _, ok := props.(map[string]interface{}); ok {
+		return nil
+	}
+	return &PathError{Path: pointerJoin(path, "properties"), Err: fmt.Errorf("properties must be an object, got %s", jsonKind(props))}
+}
+
+// jsonKind names the JSON type of a decoded value for error messages
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// checkRequired reports the entries of an object schema's required array
+// that name no property in its properties, such as a misspelled name. They
+// produce no field; the schema's properties are converted as usual.
+func (c *conversion) checkRequired(schema map[string]interface{}, path, msgName string) {
+	required, ok := schema["required"].([]interface{})
+	if !ok {
+		return
+	}
+	props, _ := schema["properties"].(map[string]interface{})
+	// Entries may name properties of the allOf bases embedded with
+	// AllOfCompose, which the schema no longer has
+	composed := len(c.allOfBases[path]) > 0
+	for i, entry := range required {
+		name, ok := entry.(string)
+		if !ok {
+			c.warnf(pointerJoin(path, "required", strconv.Itoa(i)), WarnUnknownRequired, "required entry %v of %s is not a property name", compactJSON(entry), msgName)
+			continue
+		}
+		if _, ok := props[name]; !ok && !composed {
+			c.warnf(pointerJoin(path, "required", strconv.Itoa(i)), WarnUnknownRequired, "required property %q of %s is not declared in its properties", name, msgName)
 		}
 	}
-	return proto.String(), nil
 }
 
-// GetProtoType returns the Protocol Buffers type for a given JSON Schema type
+// collectFields converts a properties map into message fields, numbering them
+// in sorted property order from a single fieldNumberAllocator, around the
+// numbers set with x-proto-field-number. path is the JSON pointer of the schema
+// owning the properties and msgName the message the fields belong to.
+func (c *conversion) collectFields(props map[string]interface{}, path, msgName string) ([]*Field, error) {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	numbers := newFieldNumberAllocator(c.opts.FieldNumberStep)
+	explicit, err := c.explicitFieldNumbers(props, keys, path, msgName, numbers)
+	if err != nil {
+		return nil, err
+	}
+	fields := c.baseFields(path, keys, numbers)
+	for _, name := range keys {
+		prop := props[name]
+		propPath := pointerJoin(path, "properties", name)
+		ft, err := c.processPropertyCollect(name, prop, propPath)
+		if err != nil {
+			if c.aborted(err) {
+				return nil, err
+			}
+			if err := c.recordError(propPath, msgName, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// Add field description if present
+		var comment string
+		propMap, _ := prop.(map[string]interface{})
+		if desc, ok := propMap["description"].(string); ok {
+			comment = desc
+		}
+		comment = appendComment(comment, ft.notes...)
+		if def, ok := propMap["default"]; ok && c.opts.EmitDefaultComments {
+			comment = appendComment(comment, "default: "+compactJSON(def))
+		}
+		if examples, ok := propMap["examples"].([]interface{}); ok && c.opts.EmitExamples {
+			for _, example := range examples {
+				comment = appendComment(comment, "Example: "+compactJSON(example))
+			}
+		}
+		if len(ft.oneof) > 0 {
+			if _, ok := explicit[name]; ok {
+				if err := c.recordError(pointerJoin(propPath, fieldNumberKeyword), msgName, fmt.Errorf("%s can't number the fields of a oneOf", fieldNumberKeyword)); err != nil {
+					return nil, err
+				}
+			}
+			members := oneofFields(c.fieldName(name), ft.oneof, comment, numbers)
+			for _, member := range members {
+				c.recordAccess(member, propMap)
+			}
+			fields = append(fields, members...)
+			continue
+		}
+		if ft.name == "" {
+			continue
+		}
+		number, ok := explicit[name]
+		if !ok {
+			number = numbers.allocate()
+		}
+		field := &Field{
+			Name:     c.fieldName(name),
+			Type:     ft.name,
+			Number:   number,
+			Repeated: ft.repeated,
+			Optional: ft.optional,
+			Comment:  comment,
+		}
+		field.Options = append(field.Options, ft.options...)
+		if c.opts.EmitJsonNameOption && field.Name != name {
+			field.Options = append(field.Options, FieldOption{Name: "json_name", Value: quoteProtoString(name)})
+		}
+		c.recordAccess(field, propMap)
+		fields = append(fields, field)
+	}
+	if numbers.err != nil {
+		if err := c.recordError(path, msgName, numbers.err); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// explicitFieldNumbers returns the numbers properties set with
+// x-proto-field-number, by property name, and reserves them in numbers so
+// the other fields are numbered around them. Invalid and duplicate numbers
+// are recorded as errors.
+func (c *conversion) explicitFieldNumbers(props map[string]interface{}, keys []string, path, msgName string, numbers *fieldNumberAllocator) (map[string]int, error) {
+	explicit := make(map[string]int)
+	owners := make(map[int]string)
+	for _, name := range keys {
+		propMap, _ := props[name].(map[string]interface{})
+		n, ok, err := explicitFieldNumber(propMap)
+		if err == nil && ok {
+			if owner, taken := owners[n]; taken {
+				err = fmt.Errorf("%s %d is already used by %s", fieldNumberKeyword, n, owner)
+			}
+		}
+		if err != nil {
+			if err := c.recordError(pointerJoin(path, "properties", name, fieldNumberKeyword), msgName, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if ok {
+			owners[n] = name
+			explicit[name] = n
+			numbers.reserve(n)
+		}
+	}
+	return explicit, nil
+}
+
+// GetProtoType returns the Protocol Buffers type for a given JSON Schema type.
+// FormatTypeMappings takes precedence, then well-known types when enabled,
+// then IntegerType/NumberType, then TypeMappings, which only needs to list
+// the types it changes: unlisted JSON types keep their default mapping.
 func GetProtoType(jsonType string, format string, opts *Options) string {
 	if opts == nil {
 		opts = DefaultOptions()
 	}
 
+	if protoType, ok := opts.FormatTypeMappings[format]; ok && format != "" {
+		return protoType
+	}
+
+	if opts.UseWellKnownTypes {
+		switch format {
+		case "date-time":
+			return "google.protobuf.Timestamp"
+		case "duration":
+			return "google.protobuf.Duration"
+		case "date":
+			if !opts.DatesAsStrings {
+				return "google.type.Date"
+			}
+		case "time":
+			if !opts.DatesAsStrings {
+				return "google.type.TimeOfDay"
+			}
+		}
+	}
 	if format == "date-time" {
-		return "string" // Could be google.protobuf.Timestamp if needed
+		return "string"
 	}
 
+	switch {
+	case jsonType == "integer" && opts.IntegerType != "":
+		return opts.IntegerType
+	case jsonType == "number" && opts.NumberType != "":
+		return opts.NumberType
+	}
 	if protoType, ok := opts.TypeMappings[jsonType]; ok {
 		return protoType
 	}
+	if protoType, ok := defaultTypeMappings[jsonType]; ok {
+		return protoType
+	}
 	return "string" // Default to string for unknown types
 }
 
+// defaultMediaTypeMappings are the proto types of string properties with a
+// contentMediaType when UseContentMediaTypes is set
+var defaultMediaTypeMappings = map[string]string{
+	"application/json":         "google.protobuf.Struct",
+	"application/octet-stream": "bytes",
+}
+
+// GetSchemaProtoType is like GetProtoType but takes the whole schema of a
+// scalar property, so keywords beyond its type and format can refine the
+// proto type: with UseContentMediaTypes, a string's contentMediaType selects
+// it from MediaTypeMappings and the defaults.
+func GetSchemaProtoType(schema map[string]interface{}, opts *Options) string {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	jsonType, _ := schemaType(schema)
+	format, _ := schema["format"].(string)
+	if _, formatMapped := opts.FormatTypeMappings[format]; jsonType == "string" && opts.UseContentMediaTypes && !formatMapped {
+		if mediaType, ok := schema["contentMediaType"].(string); ok {
+			if protoType, ok := mediaTypeProtoType(mediaType, opts); ok {
+				return protoType
+			}
+		}
+	}
+	return GetProtoType(jsonType, format, opts)
+}
+
+// mediaTypeProtoType returns the proto type for a media type, ignoring its
+// parameters and case. A structured syntax suffix such as +json falls back to
+// the type of application/json.
+func mediaTypeProtoType(mediaType string, opts *Options) (string, bool) {
+	mediaType = strings.ToLower(strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0]))
+	candidates := []string{mediaType}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		candidates = append(candidates, "application/"+mediaType[i+1:])
+	}
+	for _, candidate := range candidates {
+		if protoType, ok := opts.MediaTypeMappings[candidate]; ok {
+			return protoType, true
+		}
+		if protoType, ok := defaultMediaTypeMappings[candidate]; ok {
+			return protoType, true
+		}
+	}
+	return "", false
+}
+
+// Field name sanitization patterns, compiled once since SanitizeFieldName
+// runs for every property
+var (
+	nonFieldNameChars  = regexp.MustCompile(`[^a-z0-9]`)
+	leadingDigits      = regexp.MustCompile(`^[0-9]+`)
+	fieldNameWordChars = regexp.MustCompile(`[A-Za-z0-9]`)
+)
+
 // SanitizeFieldName converts a JSON field name to a valid Protocol Buffers field name
 func SanitizeFieldName(name string) string {
 	// Convert to lowercase
 	name = strings.ToLower(name)
 
 	// Replace spaces and special characters with underscores
-	reg := regexp.MustCompile(`[^a-z0-9]`)
-	name = reg.ReplaceAllString(name, "_")
+	name = nonFieldNameChars.ReplaceAllString(name, "_")
 
 	// If the name starts with a number, move the number to the end
-	if len(name) > 0 && regexp.MustCompile(`^[0-9]`).MatchString(name) {
-		numbers := regexp.MustCompile(`^[0-9]+`).FindString(name)
-		rest := regexp.MustCompile(`^[0-9]+`).ReplaceAllString(name, "")
+	if numbers := leadingDigits.FindString(name); numbers != "" {
+		rest := name[len(numbers):]
 		if rest == "" {
 			rest = "field"
 		}
@@ -188,79 +1607,488 @@ func SanitizeFieldName(name string) string {
 	return name
 }
 
-// processPropertyCollect returns the proto type for a property, and collects message definitions in messages map
-func processPropertyCollect(name string, prop interface{}, messages map[string]string, opts *Options) (string, error) {
+// toSnakeCaseFieldName converts a property name to a lower_snake_case field
+// name, splitting camelCase words and replacing other characters, e.g.
+// createdAt and created-at both become created_at. As in SanitizeFieldName,
+// leading digits move to the end.
+func toSnakeCaseFieldName(name string) string {
+	if !fieldNameWordChars.MatchString(name) {
+		return "field"
+	}
+	name = strings.ToLower(toEnumValueName(name))
+	if numbers := leadingDigits.FindString(name); numbers != "" {
+		rest := strings.TrimLeft(name[len(numbers):], "_")
+		if rest == "" {
+			rest = "field"
+		}
+		name = rest + numbers
+	}
+	return name
+}
+
+// fieldType is the proto type generated for a property
+type fieldType struct {
+	// name is the proto type name; empty when the property produces no field
+	name     string
+	repeated bool
+	// optional is set for nullable properties, which become proto3 optional
+	// fields
+	optional bool
+	// notes are comment lines documenting constraints proto cannot express
+	notes []string
+	// options are field options such as validation rules
+	options []FieldOption
+	// oneof holds the alternatives of a oneOf property; when set the
+	// property becomes a proto oneof rather than a single field
+	oneof []oneofMember
+}
+
+// canBeOptional reports whether the type may carry a proto3 optional label,
+// which can't be combined with repeated, map or oneof fields
+func (ft fieldType) canBeOptional() bool {
+	return !ft.repeated && len(ft.oneof) == 0 && !strings.HasPrefix(ft.name, "map<")
+}
+
+// processPropertyCollect returns the proto type for a property, and collects
+// message definitions in c.messages. path is the JSON pointer of prop within the
+// schema document and is used to locate errors.
+func (c *conversion) processPropertyCollect(name string, prop interface{}, path string) (fieldType, error) {
+	if err := c.ctx.Err(); err != nil {
+		return fieldType{}, err
+	}
+	if limit := c.maxDepth(); limit > 0 && c.depth >= limit {
+		return fieldType{}, &PathError{Path: path, Err: fmt.Errorf("schema nesting exceeds the maximum depth of %d", limit)}
+	}
+	c.depth++
+	defer func() { c.depth-- }()
+
+	// Boolean schemas: true accepts any value and false accepts none
+	if accept, ok := prop.(bool); ok {
+		if c.draft.before(Draft6) {
+			return fieldType{}, &PathError{Path: path, Err: fmt.Errorf("boolean schemas require %s or later", Draft6)}
+		}
+		if accept {
+			return fieldType{name: c.freeFormType()}, nil
+		}
+		c.warnf(path, WarnFalseSchema, "%s has a false schema, which no value matches; skipping it", name)
+		return fieldType{}, nil
+	}
+
 	propMap, ok := prop.(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("invalid property format for %s", name)
+		return fieldType{}, &PathError{Path: path, Err: fmt.Errorf("invalid property format for %s", name)}
+	}
+
+	propMap, err := c.flattenAllOf(name, propMap, path)
+	if err != nil {
+		return fieldType{}, err
+	}
+	ft, err := c.propertyType(name, propMap, path)
+	if err != nil {
+		return fieldType{}, err
+	}
+	if _, nullable := schemaType(propMap); nullable && ft.canBeOptional() {
+		ft.optional = true
+	}
+	c.applyAccessMode(&ft, propMap)
+	if isDeprecated(propMap) {
+		ft.notes = append(ft.notes, "Deprecated.")
+		ft.options = append(ft.options, FieldOption{Name: "deprecated", Value: "true"})
+	}
+	if err := applyProtoOptions(&ft, propMap, path); err != nil {
+		return fieldType{}, err
+	}
+	return ft, nil
+}
+
+// maxDepth returns the nesting limit, or 0 when there is none
+func (c *conversion) maxDepth() int {
+	switch {
+	case c.opts.MaxDepth < 0:
+		return 0
+	case c.opts.MaxDepth == 0:
+		return DefaultMaxDepth
+	}
+	return c.opts.MaxDepth
+}
+
+// isDeprecated reports whether a schema is marked "deprecated": true
+func isDeprecated(propMap map[string]interface{}) bool {
+	deprecated, _ := propMap["deprecated"].(bool)
+	return deprecated
+}
+
+// messageOptions returns the message-level options implied by a schema
+func messageOptions(schema map[string]interface{}) []FileOption {
+	if isDeprecated(schema) {
+		return []FileOption{{Name: "deprecated", Value: "true"}}
+	}
+	return nil
+}
+
+// applyAccessMode annotates readOnly and writeOnly properties with a comment,
+// or with a custom boolean field option when one is configured
+func (c *conversion) applyAccessMode(ft *fieldType, propMap map[string]interface{}) {
+	modes := []struct{ keyword, option string }{
+		{"readOnly", c.opts.ReadOnlyOption},
+		{"writeOnly", c.opts.WriteOnlyOption},
+	}
+	for _, mode := range modes {
+		if set, _ := propMap[mode.keyword].(bool); !set {
+			continue
+		}
+		if mode.option != "" {
+			ft.options = append(ft.options, FieldOption{Name: mode.option, Value: "true"})
+		} else {
+			ft.notes = append(ft.notes, mode.keyword)
+		}
+	}
+}
+
+// propertyType does the work of processPropertyCollect for a property that is
+// known to be a schema object
+func (c *conversion) propertyType(name string, propMap map[string]interface{}, path string) (fieldType, error) {
+	if c.opts.TypeResolver != nil {
+		jsonType, _ := schemaType(propMap)
+		format, _ := propMap["format"].(string)
+		if protoType, handled := c.opts.TypeResolver(jsonType, format, propMap); handled {
+			return fieldType{name: protoType}, nil
+		}
+	}
+
+	if isConditional(propMap) {
+		return c.conditionalType(name, propMap, path)
 	}
 
-	propType, _ := propMap["type"].(string)
-	format, _ := propMap["format"].(string)
+	if ref, ok := propMap["$ref"].(string); ok {
+		return c.refType(ref, propMap, path)
+	}
+
+	if ft, ok := c.processEnumProperty(name, propMap, path); ok {
+		return ft, nil
+	}
+
+	propType, _ := schemaType(propMap)
+
+	if members, ok := propMap["oneOf"].([]interface{}); ok && propType == "" {
+		return c.processOneOf(name, members, pointerJoin(path, "oneOf"))
+	}
 
 	switch propType {
 	case "array":
-		items, ok := propMap["items"].(map[string]interface{})
-		if !ok {
-			return "", fmt.Errorf("invalid array items format for %s", name)
+		itemsPath := pointerJoin(path, "items")
+		items := propMap["items"]
+		switch items.(type) {
+		case map[string]interface{}, bool:
+		default:
+			return fieldType{}, &PathError{Path: itemsPath, Err: fmt.Errorf("invalid array items format for %s", name)}
 		}
-		itemType, err := processPropertyCollect(name+"Item", items, messages, opts)
+		// The synthesized <name>Item name is only used when the items schema
+		// is an inline object or enum; references keep their definition name
+		item, err := c.processPropertyCollect(name+"Item", items, itemsPath)
 		if err != nil {
-			return "", err
+			return fieldType{}, err
+		}
+		item = c.singleValue(name, name+"Item", item, itemsPath)
+		if item.name == "" {
+			// The items schema matches nothing, so the array is always empty
+			return fieldType{}, nil
 		}
-		return fmt.Sprintf("repeated %s", itemType), nil
+		if isFreeFormValue(items) && c.opts.FreeFormType != "" && c.opts.FreeFormType != FreeFormAny {
+			ft := fieldType{name: "google.protobuf.ListValue", notes: item.notes}
+			c.applyConstraints(&ft, "repeated", arrayBounds(propMap))
+			return ft, nil
+		}
+		// proto has no repeated repeated or repeated map; wrap nested arrays
+		// and maps in a message
+		ft := fieldType{name: c.wrapNested(item), repeated: true, notes: item.notes}
+		c.applyConstraints(&ft, "repeated", arrayBounds(propMap))
+		return ft, nil
 
 	case "object":
-		messageName := toProtoMessageName(name)
-		if _, exists := messages[messageName]; !exists {
-			fields := &strings.Builder{}
+		if err := checkProperties(propMap, path); err != nil {
+			return fieldType{}, err
+		}
+		if c.opts.FreeFormObjectsAsStruct && isFreeFormObject(propMap) && len(c.allOfBases[path]) == 0 {
+			return fieldType{name: "google.protobuf.Struct"}, nil
+		}
+		if isMapObject(propMap) {
+			return c.processMap(name, propMap, path)
+		}
+		messageName := c.inlineTypeName(c.messageName(name), path)
+		if _, exists := c.messages[messageName]; !exists {
+			msg := &Message{Name: messageName, Source: path}
+			msg.Comment = appendComment("", c.dependencyNotes(propMap, path, messageName)...)
+			// Reserve the name before recursing so self-references terminate
+			c.messages[messageName] = msg
+			c.checkRequired(propMap, path, messageName)
 			if props, ok := propMap["properties"].(map[string]interface{}); ok {
-				keys := make([]string, 0, len(props))
-				for k := range props {
-					keys = append(keys, k)
-				}
-				sort.Strings(keys)
-				fieldNumber := 1
-				for _, nestedName := range keys {
-					nestedProp := props[nestedName]
-					fieldType, err := processPropertyCollect(nestedName, nestedProp, messages, opts)
-					if err != nil {
-						return "", err
-					}
-					if fieldType != "" {
-						fields.WriteString(fmt.Sprintf("  %s %s = %d;\n", fieldType, SanitizeFieldName(nestedName), fieldNumber))
-						fieldNumber++
-					}
+				fields, err := c.collectFields(props, path, messageName)
+				if err != nil {
+					return fieldType{}, err
 				}
+				msg.Fields = fields
 			}
-			messages[messageName] = fmt.Sprintf("message %s {\n%s}\n", messageName, fields.String())
 		}
-		return messageName, nil
+		return fieldType{name: messageName}, nil
+
+	case "":
+		// Untyped schemas (including anyOf unions and multi-type lists) accept
+		// any value
+		ft := fieldType{name: c.freeFormType()}
+		if ft.name == "google.protobuf.Any" {
+			c.warnf(path, WarnUntyped, "%s has no type; using google.protobuf.Any", name)
+		}
+		return ft, nil
 
 	default:
-		return GetProtoType(propType, format, opts), nil
+		ft := fieldType{name: GetSchemaProtoType(propMap, c.opts)}
+		c.applyScalarConstraints(&ft, propMap)
+		return ft, nil
 	}
 }
 
-// formatDescription formats a description string as a proto comment
-func formatDescription(desc string) string {
-	lines := strings.Split(desc, "\n")
-	var out strings.Builder
+// refType resolves a $ref property. As in draft 2019-09, keywords beside the
+// $ref still apply: description, deprecated and the like are handled by the
+// callers, and constraints such as maxLength or maxItems on a reference to a
+// scalar or array schema are merged over the target's own, so
+// {"$ref": "#/properties/code", "maxLength": 8} gets the target's minLength
+// as well as the new maxLength.
+func (c *conversion) refType(ref string, propMap map[string]interface{}, path string) (fieldType, error) {
+	ft, err := c.resolveRef(ref, path)
+	if err != nil {
+		return fieldType{}, err
+	}
+	// The resolved type may be shared with other references, so don't let
+	// the callers append into its slices
+	ft.notes = ft.notes[:len(ft.notes):len(ft.notes)]
+	ft.options = ft.options[:len(ft.options):len(ft.options)]
+
+	var constraints []string
+	for _, k := range constraintKeywords {
+		if _, ok := propMap[k]; ok {
+			constraints = append(constraints, k)
+		}
+	}
+	if len(constraints) == 0 {
+		return ft, nil
+	}
+	target, pointer, err := c.lookupRef(ref, path)
+	if err != nil {
+		return fieldType{}, err
+	}
+	targetMap, ok := target.(map[string]interface{})
+	if !ok || targetMap["enum"] != nil {
+		return ft, nil
+	}
+	switch targetType, _ := schemaType(targetMap); targetType {
+	case "string", "integer", "number", "array":
+	default:
+		return ft, nil
+	}
+	merged := make(map[string]interface{}, len(targetMap)+len(constraints))
+	for k, v := range targetMap {
+		merged[k] = v
+	}
+	for _, k := range constraints {
+		merged[k] = propMap[k]
+	}
+	segments, _ := c.refPointer(ref, path)
+	return c.processPropertyCollect(refName(segments), merged, pointer)
+}
+
+// constraintKeywords are the keywords beside a $ref that refine the
+// referenced type
+var constraintKeywords = []string{
+	"minLength", "maxLength", "pattern",
+	"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+	"minItems", "maxItems",
+}
+
+// freeFormType returns the type of a property that accepts any JSON value
+func (c *conversion) freeFormType() string {
+	switch c.opts.FreeFormType {
+	case FreeFormValue:
+		return "google.protobuf.Value"
+	case FreeFormStruct:
+		return "google.protobuf.Struct"
+	}
+	return "google.protobuf.Any"
+}
+
+// isFreeFormValue reports whether a schema accepts any JSON value, i.e. it
+// is true or has no type, properties, $ref, composition or enum
+func isFreeFormValue(schema interface{}) bool {
+	switch s := schema.(type) {
+	case bool:
+		return s
+	case map[string]interface{}:
+		for _, k := range []string{"type", "properties", "$ref", "oneOf", "anyOf", "allOf", "enum", "const"} {
+			if _, ok := s[k]; ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// definitionsRefPrefix is the $ref prefix of references to schema definitions
+const definitionsRefPrefix = "#/definitions/"
+
+// schemaType returns a schema's JSON type and whether it also admits null,
+// either through a ["T", "null"] type list or OpenAPI's nullable keyword. A
+// type list naming more than one non-null type has no single type and yields "".
+func schemaType(propMap map[string]interface{}) (string, bool) {
+	nullable, _ := propMap["nullable"].(bool)
+	switch t := propMap["type"].(type) {
+	case string:
+		return t, nullable
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			if v == "null" {
+				nullable = true
+				continue
+			}
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		if len(types) == 1 {
+			return types[0], nullable
+		}
+	}
+	return "", nullable
+}
+
+// fieldName returns the proto field name for a JSON property name
+func (c *conversion) fieldName(name string) string {
+	if c.opts.FieldNameFunc != nil {
+		return c.opts.FieldNameFunc(name)
+	}
+	if c.opts.SnakeCaseFieldNames {
+		return toSnakeCaseFieldName(name)
+	}
+	if c.opts.PreserveFieldNames && protoIdentifier.MatchString(name) {
+		return name
+	}
+	return SanitizeFieldName(name)
+}
+
+// messageName returns the proto message name for an inline object property
+func (c *conversion) messageName(name string) string {
+	if c.opts.MessageNameFunc != nil {
+		return c.opts.MessageNameFunc(name)
+	}
+	return toProtoMessageName(name)
+}
+
+// isFreeFormObject reports whether an object schema places no constraints on
+// its keys or values, i.e. it describes an arbitrary JSON object
+func isFreeFormObject(propMap map[string]interface{}) bool {
+	if props, ok := propMap["properties"].(map[string]interface{}); ok && len(props) > 0 {
+		return false
+	}
+	if _, ok := propMap["patternProperties"]; ok {
+		return false
+	}
+	additional, ok := propMap["additionalProperties"]
+	if !ok {
+		return true
+	}
+	if accept, ok := additional.(bool); ok {
+		return accept
+	}
+	additionalMap, ok := additional.(map[string]interface{})
+	return ok && len(additionalMap) == 0
+}
+
+// compactJSON renders a decoded JSON value as compact JSON text. Quotes and
+// newlines in strings stay escaped, so the result always fits on one line.
+func compactJSON(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// appendComment appends lines to an existing comment, separated by newlines
+func appendComment(comment string, lines ...string) string {
 	for _, line := range lines {
-		out.WriteString("// ")
-		out.WriteString(line)
-		out.WriteString("\n")
+		if comment != "" {
+			comment += "\n"
+		}
+		comment += line
+	}
+	return comment
+}
+
+// rootMessageName chooses the name of the message generated for the schema's
+// top-level properties: the schema title when UseTitleAsRootName is set,
+// otherwise RootMessageName, defaulting to Root
+func (c *conversion) rootMessageName(schema map[string]interface{}) string {
+	name := c.opts.RootMessageName
+	if name == "" {
+		name = "Root"
 	}
-	return out.String()
+	if title, ok := schema["title"].(string); ok && c.opts.UseTitleAsRootName {
+		// Words of the title may be separated by spaces or punctuation as
+		// well as underscores, but it must start with a letter
+		words := strings.Trim(titleSeparators.ReplaceAllString(title, "_"), "_")
+		if !protoIdentifier.MatchString(words) {
+			c.warnf("#/title", WarnInvalidTitle, "title %q is not a valid message name; using %s", title, name)
+			return name
+		}
+		fromTitle := toProtoMessageName(words)
+		defs, _ := schema["definitions"].(map[string]interface{})
+		for defName := range defs {
+			if definitionKey(defName) == definitionKey(fromTitle) {
+				c.warnf("#/title", WarnNameCollision, "title %q gives the name of definition %q; using %s", title, defName, name)
+				return name
+			}
+		}
+		return fromTitle
+	}
+	return name
 }
 
-// toProtoMessageName converts a JSON field name to a valid Protocol Buffers message name
+// titleSeparators matches the characters between the words of a title
+var titleSeparators = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Message name patterns: the separators between the words of a name, and the
+// characters dropped from each word
+var (
+	messageNameSeparators = regexp.MustCompile(`[-_\s./]+`)
+	nonMessageNameChars   = regexp.MustCompile(`[^A-Za-z0-9]`)
+)
+
+// toProtoMessageName converts a JSON field name to a valid Protocol Buffers
+// message name: words separated by underscores, hyphens, spaces, dots or
+// slashes are PascalCased and joined, so foo.bar and a/b become FooBar and AB.
+// Other characters that can't appear in an identifier are dropped, and leading
+// digits are moved to the end as for field names.
 func toProtoMessageName(name string) string {
-	parts := strings.Split(name, "_")
-	for i, part := range parts {
-		if len(part) > 0 {
-			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	var out strings.Builder
+	for _, part := range messageNameSeparators.Split(name, -1) {
+		part = nonMessageNameChars.ReplaceAllString(part, "")
+		if part != "" {
+			out.WriteString(strings.ToUpper(part[:1]) + part[1:])
 		}
 	}
-	return strings.Join(parts, "")
+	name = out.String()
+	if digits := leadingDigits.FindString(name); digits != "" {
+		rest := name[len(digits):]
+		if rest == "" {
+			rest = "Message"
+		}
+		name = strings.ToUpper(rest[:1]) + rest[1:] + digits
+	}
+	if name == "" {
+		name = "Message"
+	}
+	return name
 }
@@ -1,10 +1,20 @@
 package converter
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConvertJSONSchemaToProto(t *testing.T) {
@@ -134,9 +144,9 @@ package schema;
 
 // A test object with descriptions
 message Root {
-// The age of the object
+  // The age of the object
   int32 age = 1;
-// The name of the object
+  // The name of the object
   string name = 2;
 }
 `,
@@ -186,9 +196,9 @@ package schema;
 
 // A pet object
 message Pet {
-// The age of the pet
+  // The age of the pet
   int32 age = 1;
-// The species of the pet
+  // The species of the pet
   string species = 2;
 }
 `,
@@ -230,13 +240,15 @@ message Root {
 		},
 		{
 			name:   "custom type mapping",
-			schema: `{"type": "object", "properties": {"flag": {"type": "boolean"}}}`,
+			schema: `{"type": "object", "properties": {"flag": {"type": "boolean"}, "count": {"type": "integer"}, "tags": {"type": "array", "items": {"type": "string"}}}}`,
 			expected: `syntax = "proto3";
 
 package schema;
 
 message Root {
-  BOOL flag = 1;
+  int32 count = 1;
+  BOOL flag = 2;
+  repeated string tags = 3;
 }
 `,
 			wantErr: false,
@@ -302,6 +314,7 @@ func TestGetProtoType(t *testing.T) {
 		{"number type", "number", "", "double"},
 		{"boolean type", "boolean", "", "bool"},
 		{"date-time format", "string", "date-time", "string"},
+		{"duration format", "string", "duration", "string"},
 		{"unknown type", "unknown", "", "string"},
 	}
 
@@ -313,6 +326,205 @@ func TestGetProtoType(t *testing.T) {
 	}
 }
 
+func TestExamplesAndComments(t *testing.T) {
+	schema := `{"type": "object", "$comment": "root note", "properties": {
+		"code": {"type": "string", "description": "Country code", "$comment": "ISO 3166", "examples": ["US", "DE"]},
+		"limits": {"type": "object", "additionalProperties": {"type": "integer"}, "examples": [{"max": 10, "min": 1}]},
+		"plain": {"type": "integer", "$comment": "internal only"}
+	},
+	"definitions": {"Thing": {"type": "object", "$comment": "definition note", "properties": {"id": {"type": "string"}}}}}`
+
+	opts := DefaultOptions()
+	opts.EmitExamples = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  // Country code\n  // Example: \"US\"\n  // Example: \"DE\"\n  string code = 1;")
+	assert.Contains(t, got, "  // Example: {\"max\":10,\"min\":1}\n  map<string, int32> limits = 2;")
+	assert.Contains(t, got, ";\n  int32 plain = 3;")
+	assert.Empty(t, Validate(got))
+
+	for _, opts := range []*Options{opts, DefaultOptions()} {
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		require.NoError(t, err)
+		for _, comment := range []string{"root note", "ISO 3166", "internal only", "definition note"} {
+			assert.NotContains(t, got, comment)
+		}
+	}
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.NotContains(t, got, "Example")
+}
+
+func TestFormatTypeMappings(t *testing.T) {
+	opts := DefaultOptions()
+	opts.UseWellKnownTypes = true
+	opts.FormatTypeMappings = map[string]string{
+		"uuid":      "UUID",
+		"int64":     "int64",
+		"date-time": "int64",
+	}
+
+	tests := []struct {
+		jsonType string
+		format   string
+		want     string
+	}{
+		{"string", "uuid", "UUID"},
+		{"integer", "int64", "int64"},
+		{"string", "date-time", "int64"},
+		{"string", "duration", "google.protobuf.Duration"},
+		{"string", "email", "string"},
+		{"integer", "", "int32"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, GetProtoType(tt.jsonType, tt.format, opts), "%s/%s", tt.jsonType, tt.format)
+	}
+
+	for _, format := range []string{"uuid", "email", "uri", "hostname", "ipv4", "ipv6"} {
+		assert.Equal(t, "string", GetProtoType("string", format, DefaultOptions()), format)
+	}
+
+	schema := `{"type": "object", "properties": {"id": {"type": "string", "format": "uuid"}},
+		"definitions": {"UUID": {"type": "object", "properties": {"value": {"type": "string"}}}}}`
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  UUID id = 1;")
+	assert.Empty(t, Validate(got))
+}
+
+func TestDecimalFormats(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"price": {"type": "string", "format": "money"},
+		"rate": {"type": "string", "format": "decimal"}}}`
+
+	tests := []struct {
+		name        string
+		formats     map[string]string
+		typeImports map[string]string
+		want        []string
+		wantImport  []string
+	}{
+		{
+			name: "strings by default",
+			want: []string{"  string price = 1;", "  string rate = 2;"},
+		},
+		{
+			name:       "google.type messages",
+			formats:    map[string]string{"money": "google.type.Money", "decimal": "google.type.Decimal"},
+			want:       []string{"  google.type.Money price = 1;", "  google.type.Decimal rate = 2;"},
+			wantImport: []string{"google/type/decimal.proto", "google/type/money.proto"},
+		},
+		{
+			name:        "custom message",
+			formats:     map[string]string{"money": "acme.Money"},
+			typeImports: map[string]string{"acme.Money": "acme/money.proto"},
+			want:        []string{"  acme.Money price = 1;", "  string rate = 2;"},
+			wantImport:  []string{"acme/money.proto"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.FormatTypeMappings = tt.formats
+			opts.TypeImports = tt.typeImports
+			file, err := BuildProtoFile(schema, opts)
+			require.NoError(t, err)
+			got := RenderProto(file)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.ElementsMatch(t, tt.wantImport, file.Imports)
+		})
+	}
+}
+
+func TestContentMediaTypes(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"config": {"type": "string", "contentMediaType": "application/json"},
+		"shape": {"type": "string", "contentMediaType": "application/geo+json; charset=utf-8"},
+		"blob": {"type": "string", "contentMediaType": "application/octet-stream"},
+		"page": {"type": "string", "contentMediaType": "text/html"}}}`
+
+	tests := []struct {
+		name       string
+		use        bool
+		mediaTypes map[string]string
+		want       []string
+	}{
+		{
+			name: "disabled",
+			want: []string{"  string blob = 1;", "  string config = 2;", "  string page = 3;", "  string shape = 4;"},
+		},
+		{
+			name: "defaults",
+			use:  true,
+			want: []string{"  bytes blob = 1;", "  google.protobuf.Struct config = 2;", "  string page = 3;", "  google.protobuf.Struct shape = 4;"},
+		},
+		{
+			name:       "mappings merged over the defaults",
+			use:        true,
+			mediaTypes: map[string]string{"application/json": "google.protobuf.Value", "text/html": "bytes"},
+			want:       []string{"  bytes blob = 1;", "  google.protobuf.Value config = 2;", "  bytes page = 3;", "  google.protobuf.Value shape = 4;"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.UseContentMediaTypes = tt.use
+			opts.MediaTypeMappings = tt.mediaTypes
+			got, err := ConvertJSONSchemaToProto(schema, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+
+	opts := DefaultOptions()
+	opts.UseContentMediaTypes = true
+	assert.Equal(t, "bytes", GetSchemaProtoType(map[string]interface{}{"type": "string", "contentMediaType": "Application/Octet-Stream"}, opts))
+	assert.Equal(t, "int32", GetSchemaProtoType(map[string]interface{}{"type": "integer", "contentMediaType": "application/json"}, opts))
+}
+
+func TestIntegerAndNumberType(t *testing.T) {
+	opts := DefaultOptions()
+	opts.IntegerType = "int64"
+	opts.NumberType = "float"
+	opts.FormatTypeMappings = map[string]string{"int32": "sint32"}
+
+	tests := []struct {
+		jsonType string
+		format   string
+		want     string
+	}{
+		{"integer", "", "int64"},
+		{"number", "", "float"},
+		{"integer", "int32", "sint32"},
+		{"string", "", "string"},
+		{"boolean", "", "bool"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, GetProtoType(tt.jsonType, tt.format, opts), "%s/%s", tt.jsonType, tt.format)
+	}
+
+	// They apply even when TypeMappings has its own entries
+	opts.TypeMappings = map[string]string{"integer": "uint32", "number": "double"}
+	assert.Equal(t, "int64", GetProtoType("integer", "", opts))
+	assert.Equal(t, "float", GetProtoType("number", "", opts))
+
+	schema := `{"type": "object", "properties": {
+		"count": {"type": "integer"},
+		"ratio": {"type": "number"},
+		"sizes": {"type": "array", "items": {"type": "integer"}}
+	}}`
+	got, err := ConvertJSONSchemaToProto(schema, &Options{PackageName: "schema", TypeMappings: DefaultOptions().TypeMappings, IntegerType: "int64"})
+	require.NoError(t, err)
+	assert.Contains(t, got, "  int64 count = 1;\n  double ratio = 2;\n  repeated int64 sizes = 3;")
+	assert.Empty(t, Validate(got))
+}
+
 func TestSanitizeFieldName(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -334,3 +546,2083 @@ func TestSanitizeFieldName(t *testing.T) {
 		})
 	}
 }
+
+func TestToProtoMessageName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"order", "Order"},
+		{"shipping_address", "ShippingAddress"},
+		{"lineItem", "LineItem"},
+		{"foo.bar", "FooBar"},
+		{"a/b", "AB"},
+		{"billing-address line", "BillingAddressLine"},
+		{"price($)", "Price"},
+		{"3d_model", "DModel3"},
+		{"42", "Message42"},
+		{"...", "Message"},
+	}
+
+	for _, tt := range tests {
+		got := toProtoMessageName(tt.input)
+		assert.Equal(t, tt.expected, got, tt.input)
+		assert.Regexp(t, protoIdentifier, got, tt.input)
+	}
+
+	schema := `{"type": "object", "properties": {
+		"foo.bar": {"type": "object", "properties": {"id": {"type": "string"}}},
+		"a/b": {"type": "array", "items": {"type": "object", "properties": {"n": {"type": "integer"}}}}
+	}}`
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  repeated ABItem a_b = 1;")
+	assert.Contains(t, got, "  FooBar foo_bar = 2;")
+	assert.Contains(t, got, "message FooBar {")
+	assert.Contains(t, got, "message ABItem {")
+	assert.Empty(t, Validate(got))
+}
+
+func TestPreserveFieldNames(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"createdAt": {"type": "string"},
+		"user-name": {"type": "string"},
+		"2fa": {"type": "boolean"},
+		"_id": {"type": "string"}
+	}}`
+
+	opts := DefaultOptions()
+	opts.PreserveFieldNames = true
+	opts.EmitJsonNameOption = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, `message Root {
+  bool fa2 = 1 [json_name = "2fa"];
+  string _id = 2;
+  string createdAt = 3;
+  string user_name = 4 [json_name = "user-name"];
+}`)
+	assert.Empty(t, Validate(got))
+
+	// FieldNameFunc wins
+	opts.FieldNameFunc = strings.ToUpper
+	got, err = ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  string CREATEDAT = 3")
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  string createdat = 3;")
+}
+
+func TestBufStyleNames(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"lineItems": {"type": "array", "items": {"$ref": "#/definitions/line_item"}},
+			"__meta--Data__": {"type": "string"},
+			"2fa": {"type": "boolean"},
+			"HTTPMode": {"enum": ["plain", "TLS"]}
+		},
+		"definitions": {
+			"line_item": {"type": "object", "properties": {
+				"unitPrice": {"type": "number"},
+				"priority": {"$ref": "#/definitions/priority-level"}
+			}},
+			"priority-level": {"type": "integer", "enum": [0, 1, 2]}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.SnakeCaseFieldNames = true
+	opts.PascalCaseDefinitions = true
+	opts.UnspecifiedEnumZero = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, `message Root {
+  bool fa2 = 1;
+  HTTPModeEnum http_mode = 2;
+  string meta_data = 3;
+  repeated LineItem line_items = 4;
+}`)
+	assert.Contains(t, got, `enum HTTPModeEnum {
+  HTTP_MODE_ENUM_UNSPECIFIED = 0;
+  HTTP_MODE_ENUM_PLAIN = 1;
+  HTTP_MODE_ENUM_TLS = 2;
+}`)
+	assert.Contains(t, got, `message LineItem {
+  PriorityLevel priority = 1;
+  double unit_price = 2;
+}`)
+	assert.Contains(t, got, `enum PriorityLevel {
+  PRIORITY_LEVEL_UNSPECIFIED = 0;
+  PRIORITY_LEVEL_VALUE_1 = 1;
+  PRIORITY_LEVEL_VALUE_2 = 2;
+}`)
+	assert.Empty(t, Validate(got))
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "message line_item {")
+	assert.Contains(t, got, "  PRIORITY_LEVEL_VALUE_0 = 0;")
+}
+
+func TestNestEnums(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"status": {"$ref": "#/definitions/Status"},
+		"order": {"$ref": "#/definitions/Order"},
+		"unit": {"enum": ["kg", "lb"]}
+	}, "definitions": {
+		"Order": {"type": "object", "properties": {
+			"status": {"$ref": "#/definitions/Status"},
+			"priority": {"type": "array", "items": {"enum": ["low", "high"]}}
+		}},
+		"Status": {"enum": ["open", "closed"]},
+		"Color": {"enum": ["red", "blue"]}
+	}}`
+
+	opts := DefaultOptions()
+	opts.NestEnums = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Equal(t, `syntax = "proto3";
+
+package schema;
+
+message Root {
+  Order order = 1;
+  Status status = 2;
+  UnitEnum unit = 3;
+
+  enum UnitEnum {
+    UNIT_ENUM_UNSPECIFIED = 0;
+    UNIT_ENUM_KG = 1;
+    UNIT_ENUM_LB = 2;
+  }
+}
+
+message Order {
+  repeated PriorityItemEnum priority = 1;
+  Status status = 2;
+
+  enum PriorityItemEnum {
+    PRIORITY_ITEM_ENUM_UNSPECIFIED = 0;
+    PRIORITY_ITEM_ENUM_LOW = 1;
+    PRIORITY_ITEM_ENUM_HIGH = 2;
+  }
+}
+
+enum Color {
+  COLOR_UNSPECIFIED = 0;
+  COLOR_RED = 1;
+  COLOR_BLUE = 2;
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_OPEN = 1;
+  STATUS_CLOSED = 2;
+}
+`, got)
+	assert.Empty(t, Validate(got))
+
+	avro, err := ConvertJSONSchemaToAvro(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, avro, `"name": "PriorityItemEnum"`)
+}
+
+func TestFlatten(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"customer": {"$ref": "#/definitions/Customer"},
+		"items": {"type": "array", "items": {"type": "object", "properties": {
+			"sku": {"type": "string"},
+			"status": {"$ref": "#/definitions/Status"}
+		}}}
+	}, "definitions": {
+		"Customer": {"type": "object", "properties": {
+			"name": {"type": "string"},
+			"referrer": {"$ref": "#/definitions/Customer"}
+		}},
+		"Status": {"enum": ["open", "closed"]},
+		"Unused": {"type": "object", "properties": {"x": {"type": "string"}}}
+	}}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, `message Root {
+  Customer customer = 1;
+  repeated ItemsItem items = 2;
+}
+
+message Customer {
+  string name = 1;
+  Customer referrer = 2;
+}
+`)
+	assert.Contains(t, got, "message Unused {")
+	assert.Contains(t, got, "\nenum Status {")
+
+	opts := DefaultOptions()
+	opts.Flatten = true
+	got, warnings, err := ConvertWithReport(schema, opts)
+	require.NoError(t, err)
+	assert.Equal(t, `syntax = "proto3";
+
+package schema;
+
+import "google/protobuf/any.proto";
+
+message Root {
+  Customer customer = 1;
+  repeated ItemsItem items = 2;
+
+  message Customer {
+    string name = 1;
+    // Recursive $ref to #/definitions/Customer not inlined.
+    google.protobuf.Any referrer = 2;
+  }
+
+  message ItemsItem {
+    string sku = 1;
+    StatusEnum status = 2;
+  }
+
+  enum StatusEnum {
+    STATUS_ENUM_UNSPECIFIED = 0;
+    STATUS_ENUM_OPEN = 1;
+    STATUS_ENUM_CLOSED = 2;
+  }
+}
+`, got)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, WarnRecursiveRef, warnings[0].Code)
+	assert.Equal(t, "#/definitions/Customer/properties/referrer", warnings[0].Path)
+	assert.Empty(t, Validate(got))
+
+	// A root $ref is wrapped rather than generated as a sibling type
+	got, err = ConvertJSONSchemaToProto(`{"$ref": "#/definitions/Customer", "definitions": {
+		"Customer": {"type": "object", "properties": {"name": {"type": "string"}}}
+	}}`, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, `message Root {
+  Customer value = 1;
+
+  message Customer {
+    string name = 1;
+  }
+}
+`)
+
+	opts.GenerateService = true
+	_, err = ConvertJSONSchemaToProto(schema, opts)
+	assert.EqualError(t, err, "Flatten can't be combined with GenerateService")
+}
+
+func TestInlineAndDefinitionNames(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"address": {"type": "object", "properties": {"line": {"type": "string"}}},
+			"home": {"$ref": "#/definitions/Address"},
+			"root": {"type": "object", "properties": {"id": {"type": "string"}}},
+			"status": {"enum": ["on", "off"]}
+		},
+		"definitions": {
+			"Zone": {"type": "object", "properties": {"address": {"type": "object", "properties": {"line": {"type": "string"}}}}},
+			"Address": {"type": "object", "properties": {"street": {"type": "string"}, "city": {"type": "string"}}},
+			"StatusEnum": {"type": "integer", "enum": [0, 1]},
+			"Address2": {"type": "object", "properties": {"zip": {"type": "string"}}}
+		}
+	}`
+
+	want := `syntax = "proto3";
+
+package schema;
+
+message Root {
+  Address3 address = 1;
+  Address home = 2;
+  Root2 root = 3;
+  StatusEnum2 status = 4;
+}
+
+message Address {
+  string city = 1;
+  string street = 2;
+}
+
+message Address2 {
+  string zip = 1;
+}
+
+message Address3 {
+  string line = 1;
+}
+
+message Address4 {
+  string line = 1;
+}
+
+message Root2 {
+  string id = 1;
+}
+
+message Zone {
+  Address4 address = 1;
+}
+
+enum StatusEnum {
+  STATUS_ENUM_VALUE_0 = 0;
+  STATUS_ENUM_VALUE_1 = 1;
+}
+
+enum StatusEnum2 {
+  STATUS_ENUM2_UNSPECIFIED = 0;
+  STATUS_ENUM2_ON = 1;
+  STATUS_ENUM2_OFF = 2;
+}
+`
+	// The output doesn't depend on the order schemas are converted in
+	for i := 0; i < 5; i++ {
+		got, warnings, err := ConvertWithReport(schema, DefaultOptions())
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+		codes := make(map[string]WarningCode)
+		for _, w := range warnings {
+			codes[w.Path] = w.Code
+		}
+		assert.Equal(t, map[string]WarningCode{
+			"#/properties/address":                  WarnNameCollision,
+			"#/properties/root":                     WarnNameCollision,
+			"#/properties/status":                   WarnNameCollision,
+			"#/definitions/Zone/properties/address": WarnNameCollision,
+		}, codes)
+		assert.Empty(t, Validate(got))
+	}
+}
+
+func TestInlineNameClashes(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"p": {"type": "object", "properties": {"meta": {"type": "object", "properties": {"a": {"type": "string"}}}}},
+			"q": {"type": "object", "properties": {"meta": {"type": "object", "properties": {"b": {"type": "string"}}}}},
+			"r": {"type": "object", "properties": {"meta": {"type": "object", "properties": {"a": {"type": "string"}}}}},
+			"first": {"$ref": "#/properties/p/properties/meta"}
+		}
+	}`
+
+	got, warnings, err := ConvertWithReport(schema, DefaultOptions())
+	require.NoError(t, err)
+	for _, want := range []string{
+		"message Meta {\n  string a = 1;\n}",
+		"message Meta2 {\n  string b = 1;\n}",
+		"message Meta3 {\n  string a = 1;\n}",
+		"message P {\n  Meta meta = 1;\n}",
+		"message Q {\n  Meta2 meta = 1;\n}",
+		"message R {\n  Meta3 meta = 1;\n}",
+		// A reference to the same schema reuses its type
+		"  Meta first = 1;",
+	} {
+		assert.Contains(t, got, want)
+	}
+	paths := make(map[string]bool)
+	for _, w := range warnings {
+		assert.Equal(t, WarnNameCollision, w.Code)
+		paths[w.Path] = true
+	}
+	assert.Equal(t, map[string]bool{"#/properties/q/properties/meta": true, "#/properties/r/properties/meta": true}, paths)
+	assert.Empty(t, Validate(got))
+
+	// Identical messages are only merged when asked to
+	opts := DefaultOptions()
+	opts.DedupeMessages = true
+	got, err = ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  P r = 4;")
+	assert.NotContains(t, got, "message R {")
+	assert.NotContains(t, got, "message Meta3 {")
+	assert.Contains(t, got, "message Meta2 {\n  string b = 1;\n}")
+}
+
+func TestInlineNameClashesWithWrapper(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"a": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}},
+			"string_list": {"type": "object", "properties": {"x": {"type": "integer"}}}
+		}
+	}`
+
+	got, warnings, err := ConvertWithReport(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  repeated StringList a = 1;\n  StringList2 string_list = 2;")
+	assert.Contains(t, got, "message StringList {\n  repeated string values = 1;\n}")
+	assert.Contains(t, got, "message StringList2 {\n  int32 x = 1;\n}")
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "#/properties/string_list", warnings[0].Path)
+	assert.Equal(t, "StringList is already the name of a generated wrapper message; generating it as StringList2", warnings[0].Message)
+}
+
+func TestConvertJSONSchemaToProtoImports(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"created": {"type": "string", "format": "date-time"},
+			"updated": {"type": "string", "format": "date-time"},
+			"count": {"type": "integer"},
+			"name": {"type": "string"}
+		}
+	}`
+
+	t.Run("well-known types", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.UseWellKnownTypes = true
+		opts.TypeMappings["integer"] = "google.protobuf.Int64Value"
+		opts.Imports = []string{"google/protobuf/timestamp.proto"}
+
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		assert.NoError(t, err)
+		assert.Contains(t, got, "package schema;\n\nimport \"google/protobuf/timestamp.proto\";\nimport \"google/protobuf/wrappers.proto\";\n\n")
+		assert.Equal(t, 1, strings.Count(got, "google/protobuf/timestamp.proto"))
+		assert.Contains(t, got, "google.protobuf.Timestamp created = 2;")
+		assert.Contains(t, got, "google.protobuf.Int64Value count = 1;")
+	})
+
+	t.Run("no imports when unused", func(t *testing.T) {
+		got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+		assert.NoError(t, err)
+		assert.NotContains(t, got, "import")
+	})
+}
+
+func TestFreeFormObjectsAsStruct(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"metadata": {"type": "object"},
+			"extra": {"type": "object", "additionalProperties": {}},
+			"user": {"type": "object", "properties": {"name": {"type": "string"}}}
+		}
+	}`
+
+	t.Run("struct", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.FreeFormObjectsAsStruct = true
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		assert.NoError(t, err)
+		assert.Contains(t, got, "import \"google/protobuf/struct.proto\";")
+		assert.Contains(t, got, "google.protobuf.Struct extra = 1;")
+		assert.Contains(t, got, "google.protobuf.Struct metadata = 2;")
+		assert.Contains(t, got, "User user = 3;")
+		assert.NotContains(t, got, "message Metadata")
+		assert.NotContains(t, got, "message Extra")
+	})
+
+	t.Run("empty message by default", func(t *testing.T) {
+		got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+		assert.NoError(t, err)
+		assert.NotContains(t, got, "google.protobuf.Struct")
+		assert.Contains(t, got, "message Metadata {\n}")
+	})
+}
+
+func TestWriteProto(t *testing.T) {
+	schema := `{"type": "object", "properties": {"name": {"type": "string"}}}`
+
+	var buf strings.Builder
+	err := WriteProto(&buf, schema, DefaultOptions())
+	assert.NoError(t, err)
+
+	want, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, want, buf.String())
+
+	err = WriteProto(&buf, `{invalid json}`, DefaultOptions())
+	assert.Error(t, err)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWriteProtoWriterError(t *testing.T) {
+	err := WriteProto(failingWriter{}, `{"type": "object", "properties": {}}`, DefaultOptions())
+	assert.EqualError(t, err, "write failed")
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestConvertFile(t *testing.T) {
+	schema := `{"type": "object", "properties": {"name": {"type": "string"}}}`
+	want, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.json")
+	require.NoError(t, os.WriteFile(path, []byte(schema), 0o644))
+
+	got, err := ConvertFile(path, DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	t.Run("missing file", func(t *testing.T) {
+		missing := filepath.Join(dir, "missing.json")
+		_, err := ConvertFile(missing, DefaultOptions())
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.ErrorContains(t, err, "reading schema file")
+		assert.ErrorContains(t, err, missing)
+	})
+
+	t.Run("invalid schema", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.json")
+		require.NoError(t, os.WriteFile(bad, []byte("{\n  \"type\": }"), 0o644))
+		_, err := ConvertFile(bad, DefaultOptions())
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, 2, parseErr.Line)
+		assert.ErrorContains(t, err, bad+": parse error at line 2")
+	})
+}
+
+func TestConvertReader(t *testing.T) {
+	schema := `{"type": "object", "properties": {"name": {"type": "string"}}}`
+	want, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+
+	got, err := ConvertReader(strings.NewReader(schema), DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = ConvertReader(failingReader{}, DefaultOptions())
+	assert.EqualError(t, err, "reading schema: read failed")
+}
+
+func TestConvertBytes(t *testing.T) {
+	schema := []byte(`{"type": "object", "properties": {"name": {"type": "string"}}}`)
+	want, err := ConvertJSONSchemaToProto(string(schema), DefaultOptions())
+	require.NoError(t, err)
+
+	got, err := ConvertBytes(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	var buf strings.Builder
+	require.NoError(t, WriteProtoBytes(&buf, schema, nil))
+	assert.Equal(t, want, buf.String())
+
+	_, err = ConvertBytes([]byte("{\n  \"type\": }"), DefaultOptions())
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, parseErr.Line)
+
+	assert.EqualError(t, WriteProtoBytes(failingWriter{}, schema, DefaultOptions()), "write failed")
+}
+
+func TestPropertiesNotAnObject(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{
+			name:   "root",
+			schema: `{"type": "object", "properties": [{"name": {"type": "string"}}]}`,
+			want:   "#/properties (message Root): properties must be an object, got array",
+		},
+		{
+			name:   "definition",
+			schema: `{"definitions": {"Order": {"type": "object", "properties": [{"id": {"type": "string"}}]}}}`,
+			want:   "#/definitions/Order/properties (message Order): properties must be an object, got array",
+		},
+		{
+			name:   "nested object",
+			schema: `{"type": "object", "properties": {"address": {"type": "object", "properties": "street"}}}`,
+			want:   "#/properties/address/properties (message Root): properties must be an object, got string",
+		},
+		{
+			name:   "array items",
+			schema: `{"type": "object", "properties": {"lines": {"type": "array", "items": {"type": "object", "properties": null}}}}`,
+			want:   "#/properties/lines/items/properties (message Root): properties must be an object, got null",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ConvertJSONSchemaToProto(tt.schema, DefaultOptions())
+			var convErr *ConversionError
+			require.ErrorAs(t, err, &convErr)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestConversionErrorAggregation(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"tags": {"type": "array", "items": "string"},
+			"name": {"type": "string"}
+		},
+		"definitions": {
+			"Order": {
+				"type": "object",
+				"properties": {
+					"lines": {"type": "array"},
+					"status": 42
+				}
+			}
+		}
+	}`
+
+	t.Run("all errors", func(t *testing.T) {
+		_, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+		var convErr *ConversionError
+		assert.ErrorAs(t, err, &convErr)
+		assert.Len(t, convErr.Errors, 3)
+		assert.Equal(t, "#/properties/tags/items", convErr.Errors[0].Path)
+		assert.Equal(t, "Root", convErr.Errors[0].Message)
+		assert.Equal(t, "#/definitions/Order/properties/lines/items", convErr.Errors[1].Path)
+		assert.Equal(t, "#/definitions/Order/properties/status", convErr.Errors[2].Path)
+		assert.Equal(t, "Order", convErr.Errors[2].Message)
+
+		lines := strings.Split(err.Error(), "\n")
+		assert.Len(t, lines, 4)
+		assert.Equal(t, "3 errors converting schema:", lines[0])
+		assert.Contains(t, lines[1], "#/properties/tags/items")
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.FailFast = true
+		_, err := ConvertJSONSchemaToProto(schema, opts)
+		var convErr *ConversionError
+		assert.ErrorAs(t, err, &convErr)
+		assert.Len(t, convErr.Errors, 1)
+		assert.Equal(t, "#/properties/tags/items (message Root): invalid array items format for tags", err.Error())
+	})
+}
+
+func TestMaxDepth(t *testing.T) {
+	// nested returns an object schema with depth levels of inline objects
+	// under properties l0, l1 and so on, and the path of the innermost one
+	nested := func(depth int) (string, string) {
+		schema := `{"type": "string"}`
+		for i := depth; i > 0; i-- {
+			schema = fmt.Sprintf(`{"type": "object", "properties": {"l%d": %s}}`, i, schema)
+		}
+		path := "#"
+		for i := 0; i < depth; i++ {
+			path += fmt.Sprintf("/properties/l%d", i)
+		}
+		return `{"type": "object", "properties": {"l0": ` + schema + `}}`, path
+	}
+
+	schema, _ := nested(10)
+	_, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+
+	schema, path := nested(DefaultMaxDepth)
+	_, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	var convErr *ConversionError
+	require.ErrorAs(t, err, &convErr)
+	require.Len(t, convErr.Errors, 1)
+	assert.Equal(t, path+fmt.Sprintf("/properties/l%d", DefaultMaxDepth), convErr.Errors[0].Path)
+	assert.EqualError(t, convErr.Errors[0].Err, "schema nesting exceeds the maximum depth of 64")
+
+	opts := DefaultOptions()
+	opts.MaxDepth = 3
+	schema, _ = nested(3)
+	_, err = ConvertJSONSchemaToProto(schema, opts)
+	assert.ErrorContains(t, err, "#/properties/l0/properties/l1/properties/l2/properties/l3 (message L2): schema nesting exceeds the maximum depth of 3")
+
+	// Objects nested under the same property name get types of their own,
+	// so the depth still counts
+	schema = `{"type": "string"}`
+	for i := 0; i < 4; i++ {
+		schema = `{"type": "object", "properties": {"meta": ` + schema + `}}`
+	}
+	_, err = ConvertJSONSchemaToProto(schema, opts)
+	assert.ErrorContains(t, err, "schema nesting exceeds the maximum depth of 3")
+
+	opts.MaxDepth = -1
+	schema, _ = nested(2 * DefaultMaxDepth)
+	_, err = ConvertJSONSchemaToProto(schema, opts)
+	assert.NoError(t, err)
+}
+
+func TestErrorPaths(t *testing.T) {
+	schema := `{
+		"definitions": {
+			"Order": {
+				"type": "object",
+				"properties": {
+					"shipping_address": {
+						"type": "object",
+						"properties": {
+							"lines": {
+								"type": "array",
+								"items": {"type": "array", "items": "string"}
+							}
+						}
+					}
+				}
+			}
+		}
+	}`
+
+	_, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	var convErr *ConversionError
+	assert.ErrorAs(t, err, &convErr)
+	assert.Len(t, convErr.Errors, 1)
+	assert.Equal(t, "#/definitions/Order/properties/shipping_address/properties/lines/items/items", convErr.Errors[0].Path)
+	assert.Equal(t, "ShippingAddress", convErr.Errors[0].Message)
+	assert.Contains(t, err.Error(), "invalid array items format for linesItem")
+}
+
+func TestParseErrorLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		line   int
+		column int
+		offset int64
+	}{
+		{"trailing comma", "{\n  \"type\": \"object\",\n  \"properties\": {\"a\": 1,}\n}", 3, 25, 47},
+		{"unexpected end", `{"type": "object",`, 1, 18, 18},
+		{"multi-byte characters count once", "{\"description\": \"caf\u00e9\" x}", 1, 24, 25},
+		{"wrong top-level type", `"object"`, 1, 8, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ConvertJSONSchemaToProto(tt.schema, DefaultOptions())
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tt.line, parseErr.Line)
+			assert.Equal(t, tt.column, parseErr.Column)
+			assert.Equal(t, tt.offset, parseErr.Offset)
+			assert.True(t, strings.HasPrefix(err.Error(), fmt.Sprintf("parse error at line %d, column %d: ", tt.line, tt.column)), err.Error())
+		})
+	}
+}
+
+func TestPointerJoin(t *testing.T) {
+	assert.Equal(t, "#/definitions/a~1b/properties/c~0d", pointerJoin("#/definitions", "a/b", "properties", "c~d"))
+}
+
+func TestNamingHooks(t *testing.T) {
+	// acronymFieldName converts camelCase to snake_case, treating runs of
+	// capitals such as ID or URL as a single word
+	acronymFieldName := func(name string) string {
+		var out strings.Builder
+		runes := []rune(name)
+		for i, r := range runes {
+			upper := r >= 'A' && r <= 'Z'
+			if upper && i > 0 {
+				prevLower := runes[i-1] >= 'a' && runes[i-1] <= 'z'
+				nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+				prevUpper := runes[i-1] >= 'A' && runes[i-1] <= 'Z'
+				if prevLower || (prevUpper && nextLower) {
+					out.WriteRune('_')
+				}
+			}
+			out.WriteString(strings.ToLower(string(r)))
+		}
+		return out.String()
+	}
+
+	schema := `{
+		"type": "object",
+		"properties": {
+			"userID": {"type": "string"},
+			"homepageURL": {"type": "string"},
+			"HTTPServer": {"type": "object", "properties": {"hostName": {"type": "string"}}}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.FieldNameFunc = acronymFieldName
+	opts.MessageNameFunc = func(name string) string { return name + "Msg" }
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, got, "HTTPServerMsg http_server = 1;")
+	assert.Contains(t, got, "string homepage_url = 2;")
+	assert.Contains(t, got, "string user_id = 3;")
+	assert.Contains(t, got, "message HTTPServerMsg {\n  string host_name = 1;\n}")
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, got, "string userid = 3;")
+}
+
+func TestEmitJsonNameOption(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"userName": {"type": "string"},
+			"123user": {"type": "string"},
+			"say \"hi\"": {"type": "string"},
+			"email": {"type": "string"}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.EmitJsonNameOption = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, got, `string user123 = 1 [json_name = "123user"];`)
+	assert.Contains(t, got, `string email = 2;`)
+	assert.Contains(t, got, `string say__hi_ = 3 [json_name = "say \"hi\""];`)
+	assert.Contains(t, got, `string username = 4 [json_name = "userName"];`)
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.NotContains(t, got, "json_name")
+}
+
+func TestGenerateService(t *testing.T) {
+	schema := `{
+		"definitions": {
+			"GetUserRequest": {"type": "object", "properties": {"id": {"type": "string"}}},
+			"GetUserResponse": {"type": "object", "properties": {"name": {"type": "string"}}},
+			"ListUsersRequest": {"type": "object", "properties": {}},
+			"ListUsersResponse": {"type": "object", "properties": {}},
+			"PingRequest": {"type": "object", "properties": {}}
+		}
+	}`
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	opts := DefaultOptions()
+	opts.GenerateService = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, got, `service SchemaService {
+  rpc GetUser(GetUserRequest) returns (GetUserResponse);
+  rpc ListUsers(ListUsersRequest) returns (ListUsersResponse);
+}`)
+	assert.NotContains(t, got, "rpc Ping")
+	assert.Contains(t, logs.String(), "PingRequest has no matching PingResponse")
+
+	opts.ServiceName = "UserAPI"
+	got, err = ConvertJSONSchemaToProto(schema, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, got, "service UserAPI {")
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.NotContains(t, got, "service")
+}
+
+func TestAnyFallbackImport(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"payload": {"description": "Arbitrary payload"},
+			"content": {"anyOf": [{"type": "string"}, {"type": "integer"}]},
+			"name": {"type": "string"}
+		}
+	}`
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(got, `import "google/protobuf/any.proto";`))
+	assert.Contains(t, got, "google.protobuf.Any content = 1;")
+	assert.Contains(t, got, "google.protobuf.Any payload = 3;")
+	assert.Contains(t, logs.String(), "#/properties/content: content has no type")
+}
+
+func TestFreeFormType(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"anything": true,
+			"payload": {"description": "Arbitrary payload"},
+			"values": {"type": "array", "items": {}},
+			"more": {"type": "array", "items": true, "maxItems": 2},
+			"names": {"type": "array", "items": {"type": "string"}}
+		}
+	}`
+
+	tests := []struct {
+		freeForm FreeFormType
+		want     []string
+		imp      string
+		warnings bool
+	}{
+		{
+			freeForm: FreeFormAny,
+			want: []string{
+				"  google.protobuf.Any anything = 1;",
+				"  repeated google.protobuf.Any more = 2;",
+				"  // Arbitrary payload\n  google.protobuf.Any payload = 4;",
+				"  repeated google.protobuf.Any values = 5;",
+			},
+			imp:      "google/protobuf/any.proto",
+			warnings: true,
+		},
+		{
+			freeForm: FreeFormValue,
+			want: []string{
+				"  google.protobuf.Value anything = 1;",
+				"  google.protobuf.ListValue more = 2;",
+				"  // Arbitrary payload\n  google.protobuf.Value payload = 4;",
+				"  google.protobuf.ListValue values = 5;",
+			},
+			imp: "google/protobuf/struct.proto",
+		},
+		{
+			freeForm: FreeFormStruct,
+			want: []string{
+				"  google.protobuf.Struct anything = 1;",
+				"  google.protobuf.ListValue more = 2;",
+				"  // Arbitrary payload\n  google.protobuf.Struct payload = 4;",
+				"  google.protobuf.ListValue values = 5;",
+			},
+			imp: "google/protobuf/struct.proto",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.freeForm), func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			opts := DefaultOptions()
+			opts.FreeFormType = tt.freeForm
+			got, err := ConvertJSONSchemaToProto(schema, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.Contains(t, got, "  repeated string names = 3;")
+			assert.Equal(t, 1, strings.Count(got, "import \""+tt.imp+"\";"))
+			assert.Equal(t, tt.warnings, strings.Contains(logs.String(), "has no type"))
+			assert.Empty(t, Validate(got))
+		})
+	}
+
+	opts := DefaultOptions()
+	opts.FreeFormType = "json"
+	_, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.EqualError(t, err, `unsupported free-form type "json"`)
+}
+
+func TestLogger(t *testing.T) {
+	var std bytes.Buffer
+	log.SetOutput(&std)
+	defer log.SetOutput(os.Stderr)
+
+	schema := `{"type": "object", "properties": {"payload": {}},
+		"definitions": {"Item": {"type": "object", "properties": {"id": {"type": "string"}}}}}`
+
+	var logs bytes.Buffer
+	opts := DefaultOptions()
+	opts.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	_, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Equal(t, `level=WARN msg="payload has no type; using google.protobuf.Any" path=#/properties/payload
+level=DEBUG msg="converting definition Item as Item" path=#/definitions/Item
+`, logs.String())
+	assert.Empty(t, std.String())
+
+	// A handler that drops warnings silences them
+	logs.Reset()
+	opts.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelError}))
+	_, err = ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Empty(t, logs.String())
+	assert.Empty(t, std.String())
+
+	// Without a Logger warnings go to the standard logger
+	_, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, std.String(), "warning: #/properties/payload: payload has no type; using google.protobuf.Any\n")
+}
+
+func TestConvertWithReport(t *testing.T) {
+	var std bytes.Buffer
+	log.SetOutput(&std)
+	defer log.SetOutput(os.Stderr)
+
+	schema := `{"type": "object", "properties": {
+		"payload": {},
+		"never": false,
+		"mixed": {"enum": ["a", 1]},
+		"name": {"type": "string"}
+	}}`
+	got, warnings, err := ConvertWithReport(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  string name = 2;")
+	assert.Equal(t, []Warning{
+		{Path: "#/properties/mixed", Code: WarnUnrepresentableEnum, Message: "enum for mixed has values a proto enum can't represent; using google.protobuf.Any"},
+		{Path: "#/properties/never", Code: WarnFalseSchema, Message: "never has a false schema, which no value matches; skipping it"},
+		{Path: "#/properties/payload", Code: WarnUntyped, Message: "payload has no type; using google.protobuf.Any"},
+	}, warnings)
+	assert.Equal(t, "#/properties/payload: payload has no type; using google.protobuf.Any", warnings[2].String())
+	assert.Empty(t, std.String())
+
+	_, warnings, err = ConvertWithReport(`{"type": "object", "properties": {"name": {"type": "string"}}}`, DefaultOptions())
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	// Warnings found before an error are returned with it
+	_, warnings, err = ConvertWithReport(`{"type": "object", "properties": {"a": {}, "b": {"$ref": "#/definitions/Missing"}}}`, DefaultOptions())
+	assert.Error(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, WarnUntyped, warnings[0].Code)
+}
+
+func TestUnknownRequired(t *testing.T) {
+	schema := `{"type": "object", "required": ["id", "nmae"], "properties": {
+		"id": {"type": "string"},
+		"name": {"type": "string"},
+		"address": {"type": "object", "required": ["street", 7], "properties": {"city": {"type": "string"}}}
+	}, "definitions": {
+		"Tag": {"type": "object", "required": ["label"]}
+	}}`
+	got, warnings, err := ConvertWithReport(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, `message Root {
+  Address address = 1;
+  string id = 2;
+  string name = 3;
+}`)
+	assert.NotContains(t, got, "nmae")
+	assert.NotContains(t, got, "street")
+	assert.Equal(t, []Warning{
+		{Path: "#/required/1", Code: WarnUnknownRequired, Message: `required property "nmae" of Root is not declared in its properties`},
+		{Path: "#/properties/address/required/0", Code: WarnUnknownRequired, Message: `required property "street" of Address is not declared in its properties`},
+		{Path: "#/properties/address/required/1", Code: WarnUnknownRequired, Message: "required entry 7 of Address is not a property name"},
+		{Path: "#/definitions/Tag/required/0", Code: WarnUnknownRequired, Message: `required property "label" of Tag is not declared in its properties`},
+	}, warnings)
+	assert.Empty(t, Validate(got))
+}
+
+func TestDurationFormat(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"timeout": {"type": "string", "format": "duration"},
+			"backoff": {"type": "array", "items": {"type": "string", "format": "duration"}}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.UseWellKnownTypes = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, got, "repeated google.protobuf.Duration backoff = 1;")
+	assert.Contains(t, got, "google.protobuf.Duration timeout = 2;")
+	assert.Equal(t, 1, strings.Count(got, `import "google/protobuf/duration.proto";`))
+
+	got, err = ConvertJSONSchemaToProto(`{"type": "object", "properties": {"at": {"type": "string", "format": "date-time"}}}`, opts)
+	assert.NoError(t, err)
+	assert.NotContains(t, got, "duration.proto")
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, got, "string timeout = 2;")
+	assert.NotContains(t, got, "import")
+}
+
+func TestDateAndTimeFormats(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"born": {"type": "string", "format": "date"},
+			"opens": {"type": "string", "format": "time"},
+			"holidays": {"type": "array", "items": {"type": "string", "format": "date"}}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.UseWellKnownTypes = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "google.type.Date born = 1;")
+	assert.Contains(t, got, "repeated google.type.Date holidays = 2;")
+	assert.Contains(t, got, "google.type.TimeOfDay opens = 3;")
+	assert.Equal(t, 1, strings.Count(got, `import "google/type/date.proto";`))
+	assert.Contains(t, got, `import "google/type/timeofday.proto";`)
+
+	got, err = ConvertJSONSchemaToProto(`{"type": "object", "properties": {"born": {"type": "string", "format": "date"}}}`, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, `import "google/type/date.proto";`)
+	assert.NotContains(t, got, "timeofday.proto")
+
+	opts.DatesAsStrings = true
+	got, err = ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "string born = 1;")
+	assert.Contains(t, got, "string opens = 3;")
+	assert.NotContains(t, got, "import")
+
+	got, err = ConvertJSONSchemaToProto(`{"type": "object", "properties": {"at": {"type": "string", "format": "date-time"}}}`, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "google.protobuf.Timestamp at = 1;")
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "string born = 1;")
+	assert.NotContains(t, got, "import")
+}
+
+func TestGoPackageOption(t *testing.T) {
+	schema := `{"type": "object", "properties": {"at": {"type": "string", "format": "date-time"}}}`
+
+	opts := DefaultOptions()
+	opts.UseWellKnownTypes = true
+	opts.GoPackage = "github.com/user/project/schema"
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got, `syntax = "proto3";
+
+package schema;
+
+option go_package = "github.com/user/project/schema";
+
+import "google/protobuf/timestamp.proto";
+`), got)
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.NotContains(t, got, "option")
+}
+
+func TestFileOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.GoPackage = "example.com/schema"
+	opts.FileOptions = []FileOption{
+		{Name: "java_package", Value: "com.example.schema"},
+		{Name: "optimize_for", Value: "SPEED"},
+		{Name: "java_multiple_files", Value: "true"},
+		{Name: "(my.custom)", Value: "42"},
+		{Name: "csharp_namespace", Value: `"Example.Schema"`},
+		{Name: "go_package", Value: "example.com/override"},
+	}
+
+	got, err := ConvertJSONSchemaToProto(`{"type": "object", "properties": {"a": {"type": "string"}}}`, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, got, `package schema;
+
+option go_package = "example.com/override";
+option java_package = "com.example.schema";
+option optimize_for = SPEED;
+option java_multiple_files = true;
+option (my.custom) = 42;
+option csharp_namespace = "Example.Schema";
+
+message Root {`)
+}
+
+func TestNestedArrays(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"grid": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}},
+			"rows": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}},
+			"cube": {"type": "array", "items": {"type": "array", "items": {"type": "array", "items": {"type": "integer"}}}}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, got, "repeated Int32ListList cube = 1;")
+	assert.Contains(t, got, "repeated StringList grid = 2;")
+	assert.Contains(t, got, "repeated StringList rows = 3;")
+	assert.Contains(t, got, "message StringList {\n  repeated string values = 1;\n}")
+	assert.Contains(t, got, "message Int32List {\n  repeated int32 values = 1;\n}")
+	assert.Contains(t, got, "message Int32ListList {\n  repeated Int32List values = 1;\n}")
+	assert.Equal(t, 1, strings.Count(got, "message StringList"))
+	assert.NotContains(t, got, "repeated repeated")
+	assert.Empty(t, Validate(got))
+}
+
+func TestWrapperNamedLikeDefinition(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"a": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}},
+			"b": {"$ref": "#/definitions/StringList"}
+		},
+		"definitions": {
+			"StringList": {"type": "object", "properties": {"x": {"type": "integer"}}}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  repeated StringList2 a = 1;\n  StringList b = 2;")
+	assert.Contains(t, got, "message StringList {\n  int32 x = 1;\n}")
+	assert.Contains(t, got, "message StringList2 {\n  repeated string values = 1;\n}")
+	assert.Empty(t, Validate(got))
+}
+
+func TestTypeResolver(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"price": {"type": "string", "x-proto-type": "MyMoney"},
+			"id": {"type": "string", "format": "uuid"},
+			"tags": {"type": "array", "items": {"type": "string", "x-proto-type": "Tag"}},
+			"count": {"type": "integer"}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.TypeResolver = func(jsonType, format string, prop map[string]interface{}) (string, bool) {
+		if custom, ok := prop["x-proto-type"].(string); ok {
+			return custom, true
+		}
+		if jsonType == "string" && format == "uuid" {
+			return "bytes", true
+		}
+		return "", false
+	}
+
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, got, "int32 count = 1;")
+	assert.Contains(t, got, "bytes id = 2;")
+	assert.Contains(t, got, "MyMoney price = 3;")
+	assert.Contains(t, got, "repeated Tag tags = 4;")
+}
+
+func TestArrayItemsRef(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"orders": {"type": "array", "items": {"$ref": "#/definitions/Order"}},
+			"statuses": {"type": "array", "items": {"$ref": "#/definitions/Status"}}
+		},
+		"definitions": {
+			"Order": {"type": "object", "properties": {"id": {"type": "string"}}},
+			"Status": {"type": "string", "enum": ["open", "closed"]}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, got, "repeated Order orders = 1;")
+	assert.Contains(t, got, "repeated Status statuses = 2;")
+	assert.NotContains(t, got, "OrdersItem")
+	assert.NotContains(t, got, "StatusesItem")
+	assert.Empty(t, Validate(got))
+
+	_, err = ConvertJSONSchemaToProto(`{"type": "object", "properties": {"orders": {"type": "array", "items": {"$ref": "#/definitions/Missing"}}}}`, DefaultOptions())
+	var convErr *ConversionError
+	assert.ErrorAs(t, err, &convErr)
+	assert.Equal(t, "#/properties/orders/items/$ref", convErr.Errors[0].Path)
+}
+
+func TestCommentStyle(t *testing.T) {
+	long := strings.Repeat("word ", 15)
+	schema := `{
+		"type": "object",
+		"description": "An order",
+		"properties": {
+			"id": {"type": "string", "description": "Order identifier"},
+			"notes": {"type": "string", "description": "First paragraph.\n\nSecond */ paragraph."},
+			"summary": {"type": "string", "description": "` + long + `"}
+		}
+	}`
+
+	tests := []struct {
+		style CommentStyle
+		want  []string
+	}{
+		{
+			style: CommentStyleLeading,
+			want: []string{
+				"// An order\nmessage Root {",
+				"  // Order identifier\n  string id = 1;",
+				"  // First paragraph.\n  // \n  // Second */ paragraph.\n  string notes = 2;",
+			},
+		},
+		{
+			style: CommentStyleTrailing,
+			want: []string{
+				"// An order\nmessage Root {",
+				"  string id = 1; // Order identifier\n",
+				"  // First paragraph.\n  // \n  // Second */ paragraph.\n  string notes = 2;\n",
+				"// " + long + "\n  string summary = 3;\n",
+			},
+		},
+		{
+			style: CommentStyleBlock,
+			want: []string{
+				"/* An order */\nmessage Root {",
+				"  /* Order identifier */\n  string id = 1;",
+				"  /*\n   * First paragraph.\n   *\n   * Second * / paragraph.\n   */\n  string notes = 2;",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.style), func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.CommentStyle = tt.style
+			got, err := ConvertJSONSchemaToProto(schema, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestBooleanSubschemas(t *testing.T) {
+	tests := []struct {
+		name     string
+		prop     string
+		want     string
+		warnings string
+	}{
+		{name: "true property", prop: `true`, want: "  google.protobuf.Any value = 1;"},
+		{name: "false property", prop: `false`, warnings: "#/properties/value: value has a false schema"},
+		{name: "true items", prop: `{"type": "array", "items": true}`, want: "  repeated google.protobuf.Any value = 1;"},
+		{name: "false items", prop: `{"type": "array", "items": false}`, warnings: "#/properties/value/items: valueItem has a false schema"},
+		{name: "true additionalProperties", prop: `{"type": "object", "additionalProperties": true}`, want: "  google.protobuf.Struct value = 1;"},
+		{
+			name: "false additionalProperties",
+			prop: `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`,
+			want: "  Value value = 1;",
+		},
+		{
+			name:     "false pattern value",
+			prop:     `{"type": "object", "patternProperties": {"^x-": {"type": "string"}, "^y-": false}}`,
+			want:     "  map<string, string> value = 1;",
+			warnings: "#/properties/value/patternProperties/^y-: valueValue has a false schema",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			opts := DefaultOptions()
+			opts.FreeFormObjectsAsStruct = true
+			schema := `{"type": "object", "properties": {"value": ` + tt.prop + `, "zz": {"type": "string"}}}`
+			got, err := ConvertJSONSchemaToProto(schema, opts)
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.NotContains(t, got, " value = ")
+				// Skipped fields don't consume a field number
+				assert.Contains(t, got, "  string zz = 1;")
+			} else {
+				assert.Contains(t, got, tt.want)
+			}
+			if tt.warnings == "" {
+				assert.Empty(t, logs.String())
+			} else {
+				assert.Contains(t, logs.String(), tt.warnings)
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestSkippedPropertiesNumbering(t *testing.T) {
+	skipped := map[string]string{
+		"false schema":        `false`,
+		"false items":         `{"type": "array", "items": false}`,
+		"unsatisfiable oneOf": `{"oneOf": [false, false]}`,
+		"false map values":    `{"type": "object", "patternProperties": {"^x-": false}}`,
+	}
+	for name, prop := range skipped {
+		t.Run(name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			schema := `{"type": "object", "properties": {"a": {"type": "string"}, "b": ` + prop + `, "c": {"type": "string"}},
+				"definitions": {"Thing": {"type": "object", "properties": {"a": {"type": "string"}, "b": ` + prop + `, "c": {"type": "string"}}}}}`
+
+			got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+			require.NoError(t, err)
+			assert.Equal(t, 2, strings.Count(got, "  string a = 1;\n  string c = 2;\n"))
+			assert.NotContains(t, got, " b = ")
+
+			// The split output used by the CLI numbers fields the same way
+			files, err := ConvertToFiles(schema, DefaultOptions())
+			require.NoError(t, err)
+			assert.Contains(t, files["root.proto"], "  string a = 1;\n  string c = 2;\n")
+			assert.Contains(t, files["thing.proto"], "  string a = 1;\n  string c = 2;\n")
+		})
+	}
+}
+
+func TestConvertJSONSchemaToProtoContext(t *testing.T) {
+	schema := `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "string"}, "c": {"type": "string"}},
+		"definitions": {"X": {"type": "object", "properties": {"d": {"type": "string"}}}}}`
+
+	t.Run("matches the plain conversion", func(t *testing.T) {
+		want, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+		require.NoError(t, err)
+		got, err := ConvertJSONSchemaToProtoContext(context.Background(), schema, DefaultOptions())
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("already canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ConvertJSONSchemaToProtoContext(ctx, schema, DefaultOptions())
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("canceled during traversal", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		calls := 0
+		opts := DefaultOptions()
+		opts.TypeResolver = func(string, string, map[string]interface{}) (string, bool) {
+			calls++
+			cancel()
+			return "", false
+		}
+		_, err := ConvertJSONSchemaToProtoContext(ctx, schema, opts)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("canceled after the traversal", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := DefaultOptions()
+		opts.Transform = func(*ProtoFile) error {
+			cancel()
+			return nil
+		}
+		_, err := ConvertJSONSchemaToProtoContext(ctx, schema, opts)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		_, err := ConvertJSONSchemaToProtoContext(ctx, schema, DefaultOptions())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		var convErr *ConversionError
+		assert.False(t, errors.As(err, &convErr))
+	})
+}
+
+func TestFileHeader(t *testing.T) {
+	schema := `{"type": "object", "properties": {"id": {"type": "string"}}}`
+
+	opts := DefaultOptions()
+	opts.FileHeader = "Code generated by schema2proto from order.json. DO NOT EDIT.\n\nSource: order.json"
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got, "// Code generated by schema2proto from order.json. DO NOT EDIT.\n//\n// Source: order.json\n\nsyntax = \"proto3\";\n"), got)
+	assert.Empty(t, Validate(got))
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got, "syntax = "), got)
+}
+
+func TestIndent(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"description": "A request",
+		"properties": {
+			"id": {"type": "string", "description": "Identifier"},
+			"status": {"type": "string", "enum": ["open", "closed"]},
+			"body": {"oneOf": [{"type": "string"}, {"type": "integer"}]}
+		},
+		"definitions": {
+			"GetRequest": {"type": "object", "properties": {"id": {"type": "string"}}},
+			"GetResponse": {"type": "object", "properties": {"name": {"type": "string"}}}
+		}
+	}`
+
+	base := DefaultOptions()
+	base.GenerateService = true
+	want, err := ConvertJSONSchemaToProto(schema, base)
+	require.NoError(t, err)
+
+	// reindent replaces each two-space level of leading indentation
+	reindent := func(proto, indent string) string {
+		lines := strings.Split(proto, "\n")
+		for i, line := range lines {
+			trimmed := strings.TrimLeft(line, " ")
+			levels := (len(line) - len(trimmed)) / 2
+			lines[i] = strings.Repeat(indent, levels) + trimmed
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for _, indent := range []string{"    ", "\t"} {
+		opts := DefaultOptions()
+		opts.GenerateService = true
+		opts.Indent = indent
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		require.NoError(t, err)
+		assert.Equal(t, reindent(want, indent), got)
+		assert.Contains(t, got, indent+indent+"string body_string = 1;")
+		assert.Contains(t, got, indent+"STATUS_ENUM_OPEN = 1;")
+		assert.Contains(t, got, indent+"rpc Get(GetRequest) returns (GetResponse);")
+		assert.Empty(t, Validate(got))
+	}
+}
+
+func TestIndentComments(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "string", "description": "Identifier"},
+			"shipping": {
+				"type": "object",
+				"description": "Where to ship",
+				"properties": {"city": {"type": "string", "description": "City name\nas written locally"}}
+			}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.Flatten = true
+	opts.Indent = "\t"
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "syntax = \"proto3\";\n\npackage schema;\n\nmessage Root {\n"+
+		"\t// Identifier\n"+
+		"\tstring id = 1;\n"+
+		"\t// Where to ship\n"+
+		"\tShipping shipping = 2;\n"+
+		"\n"+
+		"\tmessage Shipping {\n"+
+		"\t\t// City name\n"+
+		"\t\t// as written locally\n"+
+		"\t\tstring city = 1;\n"+
+		"\t}\n"+
+		"}\n", got)
+
+	opts.CommentStyle = CommentStyleBlock
+	got, err = ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "\t\t/*\n\t\t * City name\n\t\t * as written locally\n\t\t */\n\t\tstring city = 1;\n")
+}
+
+func TestProto2Syntax(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"counts": {"type": "array", "items": {"type": "integer"}},
+			"weights": {"type": "array", "items": {"type": "number"}},
+			"flags": {"type": "array", "items": {"type": "boolean"}},
+			"states": {"type": "array", "items": {"type": "string", "enum": ["on", "off"]}},
+			"names": {"type": "array", "items": {"type": "string"}},
+			"children": {"type": "array", "items": {"type": "object", "properties": {"id": {"type": "string"}}}},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"title": {"type": "string"},
+			"body": {"oneOf": [{"type": "string"}, {"type": "integer"}]}
+		}
+	}`
+
+	t.Run("proto2", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Syntax = "proto2"
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		require.NoError(t, err)
+		for _, want := range []string{
+			`syntax = "proto2";`,
+			"  repeated int32 counts = 4 [packed = true];",
+			"  repeated double weights = 10 [packed = true];",
+			"  repeated bool flags = 5 [packed = true];",
+			"  repeated StatesItemEnum states = 8 [packed = true];",
+			"  repeated string names = 7;",
+			"  repeated ChildrenItem children = 3;",
+			"  map<string, string> labels = 6;",
+			"  optional string title = 9;",
+			"    string body_string = 1;",
+		} {
+			assert.Contains(t, got, want)
+		}
+		assert.Empty(t, Validate(got))
+	})
+
+	t.Run("proto3 is unchanged", func(t *testing.T) {
+		got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+		require.NoError(t, err)
+		assert.NotContains(t, got, "packed")
+		assert.NotContains(t, got, "optional")
+	})
+
+	t.Run("unsupported syntax", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Syntax = "editions"
+		_, err := ConvertJSONSchemaToProto(schema, opts)
+		assert.EqualError(t, err, `unsupported syntax "editions"`)
+	})
+}
+
+func TestDefaultComments(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"count": {"type": "integer", "default": 10},
+		"enabled": {"type": "boolean", "default": false},
+		"greeting": {"type": "string", "description": "Shown on login", "default": "say \"hi\"\n<b>now</b>"},
+		"tags": {"type": "array", "items": {"type": "string"}, "default": ["a", "b"]},
+		"window": {"type": "object", "properties": {"size": {"type": "integer"}}, "default": {"size": 3, "label": null}},
+		"plain": {"type": "string"}
+	}}`
+
+	opts := DefaultOptions()
+	opts.EmitDefaultComments = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	for _, want := range []string{
+		"  // default: 10\n  int32 count = 1;",
+		"  // default: false\n  bool enabled = 2;",
+		"  // Shown on login\n  // default: \"say \\\"hi\\\"\\n<b>now</b>\"\n  string greeting = 3;",
+		"  // default: [\"a\",\"b\"]\n  repeated string tags = 5;",
+		"  // default: {\"label\":null,\"size\":3}\n  Window window = 6;",
+		"\n  string plain = 4;",
+	} {
+		assert.Contains(t, got, want)
+	}
+	assert.Empty(t, Validate(got))
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.NotContains(t, got, "default")
+}
+
+func TestReadOnlyWriteOnly(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"id": {"type": "string", "description": "Server assigned", "readOnly": true},
+		"name": {"type": "string"},
+		"password": {"type": "string", "writeOnly": true}
+	}}`
+
+	t.Run("comments by default", func(t *testing.T) {
+		got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+		require.NoError(t, err)
+		assert.Contains(t, got, "  // Server assigned\n  // readOnly\n  string id = 1;")
+		assert.Contains(t, got, "{\n  // Server assigned")
+		assert.Contains(t, got, ";\n  string name = 2;")
+		assert.Contains(t, got, "  // writeOnly\n  string password = 3;")
+	})
+
+	t.Run("custom options", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.ReadOnlyOption = "(api.read_only)"
+		opts.WriteOnlyOption = "(api.write_only)"
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		require.NoError(t, err)
+		assert.Contains(t, got, "  string id = 1 [(api.read_only) = true];")
+		assert.Contains(t, got, "  string name = 2;")
+		assert.Contains(t, got, "  string password = 3 [(api.write_only) = true];")
+		assert.NotContains(t, got, "// readOnly")
+		assert.Empty(t, Validate(got))
+	})
+}
+
+func TestDeprecated(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"id": {"type": "string"},
+		"legacy_id": {"type": "string", "description": "Old identifier", "deprecated": true},
+		"tags": {"type": "array", "items": {"type": "string"}, "deprecated": true}
+	},
+	"definitions": {
+		"OldAddress": {"type": "object", "deprecated": true, "description": "Use Address", "properties": {"city": {"type": "string"}}},
+		"Address": {"type": "object", "deprecated": false, "properties": {"city": {"type": "string"}}}
+	}}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  string id = 1;")
+	assert.Contains(t, got, "  // Old identifier\n  // Deprecated.\n  string legacy_id = 2 [deprecated = true];")
+	assert.Contains(t, got, "  // Deprecated.\n  repeated string tags = 3 [deprecated = true];")
+	assert.Contains(t, got, "// Use Address\nmessage OldAddress {\n  option deprecated = true;\n  string city = 1;\n}")
+	assert.Contains(t, got, "message Address {\n  string city = 1;\n}")
+	assert.Empty(t, Validate(got))
+}
+
+func TestPackageFromID(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/schemas/order.json":           "order",
+		"https://example.com/schemas/acme.Order-V2.json":   "acme.order_v2",
+		"https://example.com/schemas/order/":               "order",
+		"https://example.com/schemas/order.json#/defs/foo": "order",
+		"http://example.com/2020/12.json":                  "_12",
+		"urn:example:billing.invoice":                      "billing.invoice",
+		"order":                                            "order",
+		"https://example.com/":                             "",
+		"#":                                                "",
+		"":                                                 "",
+	}
+	for id, want := range tests {
+		assert.Equal(t, want, packageFromID(id), id)
+	}
+}
+
+func TestDeriveNamingFromID(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		derive bool
+		want   string
+	}{
+		{"$id", `{"$id": "https://example.com/schemas/order.json", "type": "object", "properties": {}}`, true, "package order;"},
+		{"draft-04 id", `{"id": "https://example.com/schemas/invoice.json", "type": "object", "properties": {}}`, true, "package invoice;"},
+		{"no id", `{"type": "object", "properties": {}}`, true, "package schema;"},
+		{"unusable id", `{"$id": "https://example.com/", "type": "object", "properties": {}}`, true, "package schema;"},
+		{"disabled", `{"$id": "https://example.com/schemas/order.json", "type": "object", "properties": {}}`, false, "package schema;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.DeriveNamingFromId = tt.derive
+			got, err := ConvertJSONSchemaToProto(tt.schema, opts)
+			require.NoError(t, err)
+			assert.Contains(t, got, tt.want)
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	schema := `{"type": "object", "properties": {"user_name": {"type": "string"}},
+		"definitions": {"Empty": {"type": "object", "properties": {}}}}`
+
+	opts := DefaultOptions()
+	opts.OmitEmptyMessages = true
+	var seen []string
+	opts.Transform = func(file *ProtoFile) error {
+		for _, msg := range file.Messages {
+			seen = append(seen, msg.Name)
+			for _, field := range msg.Fields {
+				field.Name = strings.TrimPrefix(field.Name, "user_")
+			}
+			msg.Fields = append(msg.Fields, &Field{Name: "created_at", Type: "google.protobuf.Timestamp", Number: len(msg.Fields) + 1})
+		}
+		return nil
+	}
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	// Transforms run after the other options, so Empty is already gone
+	assert.Equal(t, []string{"Root"}, seen)
+	assert.Contains(t, got, `import "google/protobuf/timestamp.proto";`)
+	assert.Contains(t, got, "message Root {\n  string name = 1;\n  google.protobuf.Timestamp created_at = 2;\n}")
+	assert.Empty(t, Validate(got))
+
+	opts.Transform = func(*ProtoFile) error { return errors.New("policy violation") }
+	_, err = ConvertJSONSchemaToProto(schema, opts)
+	assert.EqualError(t, err, "transform: policy violation")
+}
+
+func TestEmitOrder(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"customer": {"$ref": "#/definitions/Customer"},
+		"lines": {"type": "array", "items": {"$ref": "#/definitions/Line"}}
+	},
+	"definitions": {
+		"Address": {"type": "object", "properties": {"city": {"type": "string"}}},
+		"Customer": {"type": "object", "properties": {"address": {"$ref": "#/definitions/Address"}, "tier": {"$ref": "#/definitions/Tier"}}},
+		"Line": {"type": "object", "properties": {"product": {"$ref": "#/definitions/Product"}, "notes": {"type": "object", "additionalProperties": {"$ref": "#/definitions/Address"}}}},
+		"Product": {"type": "object", "properties": {"related": {"type": "array", "items": {"$ref": "#/definitions/Product"}}, "bundle": {"$ref": "#/definitions/Bundle"}}},
+		"Bundle": {"type": "object", "properties": {"products": {"type": "array", "items": {"$ref": "#/definitions/Product"}}}},
+		"Tier": {"type": "string", "enum": ["gold", "silver"]}
+	}}`
+
+	declarations := func(proto string) []string {
+		var names []string
+		for _, line := range strings.Split(proto, "\n") {
+			if strings.HasPrefix(line, "message ") || strings.HasPrefix(line, "enum ") {
+				names = append(names, strings.TrimSuffix(line, " {"))
+			}
+		}
+		return names
+	}
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"message Root", "message Address", "message Bundle", "message Customer",
+		"message Line", "message Product", "enum Tier",
+	}, declarations(got))
+
+	opts := DefaultOptions()
+	opts.EmitOrder = EmitOrderTopological
+	got, err = ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	// Every type follows the types it uses; the Bundle/Product cycle is
+	// written together in alphabetical order
+	assert.Equal(t, []string{
+		"enum Tier", "message Address", "message Bundle", "message Product",
+		"message Customer", "message Line", "message Root",
+	}, declarations(got))
+	assert.Contains(t, got, "package schema;\n\nenum Tier {")
+	assert.Contains(t, got, "}\n\nmessage Address {")
+	assert.Empty(t, Validate(got))
+
+	opts.EmitOrder = "random"
+	_, err = ConvertJSONSchemaToProto(schema, opts)
+	assert.EqualError(t, err, `unsupported emit order "random"`)
+}
+
+func TestTopLevelArray(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		field  string
+		want   []string
+	}{
+		{
+			name:   "array of references",
+			schema: `{"type": "array", "description": "All items", "items": {"$ref": "#/definitions/Item"}, "definitions": {"Item": {"type": "object", "properties": {"id": {"type": "string"}}}}}`,
+			want: []string{
+				"// All items\nmessage Root {\n  repeated Item items = 1;\n}",
+				"message Item {\n  string id = 1;\n}",
+			},
+		},
+		{
+			name:   "array of inline objects",
+			schema: `{"type": "array", "items": {"type": "object", "properties": {"id": {"type": "integer"}}}}`,
+			want: []string{
+				"message Root {\n  repeated ItemsItem items = 1;\n}",
+				"message ItemsItem {\n  int32 id = 1;\n}",
+			},
+		},
+		{
+			name:   "custom field name",
+			schema: `{"type": "array", "items": {"type": "string"}, "maxItems": 5}`,
+			field:  "names",
+			want:   []string{"message Root {\n  repeated string names = 1;\n}"},
+		},
+		{
+			name:   "recursive",
+			schema: `{"type": "array", "items": {"$ref": "#"}}`,
+			want:   []string{"message Root {\n  repeated Root items = 1;\n}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.RootArrayFieldName = tt.field
+			got, err := ConvertJSONSchemaToProto(tt.schema, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestTopLevelScalarAndRef(t *testing.T) {
+	defs := `"definitions": {"Order": {"type": "object", "properties": {"id": {"type": "string"}}}}`
+	tests := []struct {
+		name    string
+		schema  string
+		wrap    bool
+		want    []string
+		notWant string
+	}{
+		{
+			name:    "ref as alias",
+			schema:  `{"$ref": "#/definitions/Order", ` + defs + `}`,
+			want:    []string{"message Order {\n  string id = 1;\n}"},
+			notWant: "message Root",
+		},
+		{
+			name:   "ref wrapped",
+			schema: `{"$ref": "#/definitions/Order", ` + defs + `}`,
+			wrap:   true,
+			want: []string{
+				"message Root {\n  Order value = 1;\n}",
+				"message Order {\n  string id = 1;\n}",
+			},
+		},
+		{
+			name:    "ref to a nested schema is generated",
+			schema:  `{"$ref": "#/$defs/Line", "$defs": {"Line": {"type": "object", "properties": {"qty": {"type": "integer"}}}}}`,
+			want:    []string{"message Line {\n  int32 qty = 1;\n}"},
+			notWant: "message Root",
+		},
+		{
+			name:   "string",
+			schema: `{"type": "string", "description": "A name", "maxLength": 10}`,
+			want:   []string{"// A name\nmessage Root {\n  string value = 1;\n}"},
+		},
+		{
+			name:   "nullable integer",
+			schema: `{"type": ["integer", "null"]}`,
+			want:   []string{"message Root {\n  optional int32 value = 1;\n}"},
+		},
+		{
+			name:   "enum",
+			schema: `{"type": "string", "enum": ["on", "off"]}`,
+			want: []string{
+				"message Root {\n  ValueEnum value = 1;\n}",
+				"enum ValueEnum {\n  VALUE_ENUM_UNSPECIFIED = 0;\n  VALUE_ENUM_ON = 1;\n  VALUE_ENUM_OFF = 2;\n}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.WrapRootRef = tt.wrap
+			got, err := ConvertJSONSchemaToProto(tt.schema, opts)
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			if tt.notWant != "" {
+				assert.NotContains(t, got, tt.notWant)
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+
+	_, err := ConvertJSONSchemaToProto(`{"$ref": "#/definitions/Missing"}`, DefaultOptions())
+	assert.EqualError(t, err, `#/$ref (message Root): unresolved $ref "#/definitions/Missing"`)
+	_, err = ConvertJSONSchemaToProto(`{"$ref": "#"}`, DefaultOptions())
+	assert.ErrorContains(t, err, `circular $ref "#"`)
+}
+
+func TestRootMessageName(t *testing.T) {
+	schema := `{"title": "purchase_order", "type": "object",
+		"properties": {"id": {"type": "string"}, "self": {"$ref": "#"}},
+		"definitions": {"Address": {"type": "object", "properties": {"city": {"type": "string"}}}}}`
+
+	tests := []struct {
+		name      string
+		rootName  string
+		fromTitle bool
+		want      string
+	}{
+		{name: "default", want: "Root"},
+		{name: "explicit override", rootName: "Order", want: "Order"},
+		{name: "derived from title", fromTitle: true, want: "PurchaseOrder"},
+		{name: "title wins over override", rootName: "Order", fromTitle: true, want: "PurchaseOrder"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.RootMessageName = tt.rootName
+			opts.UseTitleAsRootName = tt.fromTitle
+			file, err := BuildProtoFile(schema, opts)
+			require.NoError(t, err)
+			// The root message is emitted first whatever its name
+			assert.Equal(t, tt.want, file.Messages[0].Name)
+			assert.Equal(t, "Address", file.Messages[1].Name)
+			assert.Equal(t, tt.want, file.Messages[0].Fields[1].Type)
+			assert.Empty(t, Validate(RenderProto(file)))
+		})
+	}
+
+	t.Run("title words", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.UseTitleAsRootName = true
+		for title, want := range map[string]string{
+			"Order":          "Order",
+			"Purchase Order": "PurchaseOrder",
+			"order-line v2":  "OrderLineV2",
+			"Invoice (EU)":   "InvoiceEU",
+		} {
+			file, err := BuildProtoFile(`{"title": "`+title+`", "type": "object", "properties": {"id": {"type": "string"}}}`, opts)
+			require.NoError(t, err)
+			assert.Equal(t, want, file.Messages[0].Name, title)
+		}
+	})
+
+	t.Run("title naming a definition falls back", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.UseTitleAsRootName = true
+		_, warnings, err := ConvertWithReport(`{"title": "order", "type": "object", "properties": {"id": {"type": "string"}},
+			"definitions": {"Order": {"type": "object", "properties": {"total": {"type": "number"}}}}}`, opts)
+		require.NoError(t, err)
+		require.Len(t, warnings, 1)
+		assert.Equal(t, WarnNameCollision, warnings[0].Code)
+		assert.Equal(t, `#/title: title "order" gives the name of definition "Order"; using Root`, warnings[0].String())
+	})
+
+	t.Run("invalid title falls back", func(t *testing.T) {
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		opts := DefaultOptions()
+		opts.UseTitleAsRootName = true
+		file, err := BuildProtoFile(`{"title": "1st order", "type": "object", "properties": {"id": {"type": "string"}}}`, opts)
+		require.NoError(t, err)
+		assert.Equal(t, "Root", file.Messages[0].Name)
+		assert.Contains(t, logs.String(), `#/title: title "1st order" is not a valid message name; using Root`)
+	})
+}
+
+func TestOmitEmptyMessages(t *testing.T) {
+	opts := DefaultOptions()
+	opts.OmitEmptyMessages = true
+
+	t.Run("empty root is dropped", func(t *testing.T) {
+		schema := `{"type": "object", "properties": {},
+			"definitions": {"Order": {"type": "object", "properties": {"id": {"type": "string"}}}}}`
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		require.NoError(t, err)
+		assert.NotContains(t, got, "message Root")
+		assert.Contains(t, got, "message Order {")
+
+		got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+		require.NoError(t, err)
+		assert.Contains(t, got, "message Root {\n}")
+	})
+
+	t.Run("referenced empty messages are kept", func(t *testing.T) {
+		schema := `{"type": "object", "properties": {"marker": {"$ref": "#/definitions/Marker"}},
+			"definitions": {
+				"Marker": {"type": "object"},
+				"Unused": {"type": "object", "properties": {}}
+			}}`
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		require.NoError(t, err)
+		assert.Contains(t, got, "message Marker {\n}")
+		assert.NotContains(t, got, "message Unused")
+		assert.Empty(t, Validate(got))
+	})
+
+	t.Run("rpc messages are kept", func(t *testing.T) {
+		serviceOpts := DefaultOptions()
+		serviceOpts.OmitEmptyMessages = true
+		serviceOpts.GenerateService = true
+		schema := `{"definitions": {
+			"ListRequest": {"type": "object"},
+			"ListResponse": {"type": "object", "properties": {"ids": {"type": "array", "items": {"type": "string"}}}}
+		}}`
+		got, err := ConvertJSONSchemaToProto(schema, serviceOpts)
+		require.NoError(t, err)
+		assert.Contains(t, got, "message ListRequest {\n}")
+		assert.Empty(t, Validate(got))
+	})
+}
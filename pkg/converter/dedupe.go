@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dedupeMessages collapses structurally identical inline messages, those
+// with the same fields (names, types, numbers, labels and options) and
+// message options, into the one whose name sorts first, and points every
+// field at it. Merging can make the messages that referred to the merged
+// ones identical in turn, so it repeats until nothing changes. Messages
+// generated for the root or a definition keep their names and are never
+// merged.
+func (c *conversion) dedupeMessages() {
+	for {
+		groups := make(map[string][]string)
+		for name, msg := range c.messages {
+			if msg.Source == "" || isTopLevelSource(msg.Source) {
+				continue
+			}
+			key := messageSignature(msg)
+			groups[key] = append(groups[key], name)
+		}
+
+		renames := make(map[string]string)
+		for _, names := range groups {
+			if len(names) < 2 {
+				continue
+			}
+			sort.Strings(names)
+			for _, name := range names[1:] {
+				renames[name] = names[0]
+				delete(c.messages, name)
+			}
+		}
+		if len(renames) == 0 {
+			return
+		}
+		rename := func(typ string) string {
+			if renamed, ok := renames[typ]; ok {
+				return renamed
+			}
+			return typ
+		}
+		for _, msg := range c.messages {
+			for _, field := range msg.Fields {
+				field.Type = mapFieldType(field.Type, rename)
+			}
+		}
+	}
+}
+
+// messageSignature describes a message's structure, ignoring its name and
+// comments
+func messageSignature(msg *Message) string {
+	var sig strings.Builder
+	for _, opt := range msg.Options {
+		fmt.Fprintf(&sig, "option %s=%s;", opt.Name, opt.Value)
+	}
+	for _, f := range msg.Fields {
+		fmt.Fprintf(&sig, "%s %s %d %t %t %s", f.Name, f.Type, f.Number, f.Repeated, f.Optional, f.Oneof)
+		for _, opt := range f.Options {
+			fmt.Fprintf(&sig, " %s=%s", opt.Name, opt.Value)
+		}
+		sig.WriteString(";")
+	}
+	return sig.String()
+}
+
+// mapFieldType applies f to the type named by a field type, or to the value
+// type of a map<K, V>
+func mapFieldType(typ string, f func(string) string) string {
+	if strings.HasPrefix(typ, "map<") && strings.HasSuffix(typ, ">") {
+		parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(typ, "map<"), ">"), ",", 2)
+		if len(parts) == 2 {
+			return fmt.Sprintf("map<%s, %s>", strings.TrimSpace(parts[0]), mapFieldType(strings.TrimSpace(parts[1]), f))
+		}
+	}
+	return f(typ)
+}
@@ -0,0 +1,57 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupeMessages(t *testing.T) {
+	point := `{"type": "object", "properties": {"x": {"type": "number"}, "y": {"type": "number"}}}`
+	schema := `{
+		"type": "object",
+		"properties": {
+			"origin": ` + point + `,
+			"target": {"type": "object", "description": "Where to go", "properties": {"x": {"type": "number"}, "y": {"type": "number"}}},
+			"path": {"type": "array", "items": ` + point + `},
+			"named": {"type": "object", "additionalProperties": ` + point + `},
+			"from": {"type": "object", "properties": {"at": ` + point + `}},
+			"to": {"type": "object", "properties": {"at": ` + point + `}},
+			"size": {"type": "object", "properties": {"x": {"type": "integer"}, "y": {"type": "integer"}}}
+		},
+		"definitions": {
+			"Point": ` + point + `
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "message Origin {")
+	assert.Contains(t, got, "message Target {")
+
+	opts := DefaultOptions()
+	opts.DedupeMessages = true
+	got, err = ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	// From and To become identical once their At fields are merged
+	assert.Contains(t, got, `message Root {
+  From from = 1;
+  map<string, At> named = 2;
+  At origin = 3;
+  repeated At path = 4;
+  Size size = 5;
+  // Where to go
+  At target = 6;
+  From to = 7;
+}`)
+	assert.Contains(t, got, "message At {\n  double x = 1;\n  double y = 2;\n}")
+	assert.Contains(t, got, "message From {\n  At at = 1;\n}")
+	assert.Contains(t, got, "message Size {\n  int32 x = 1;\n  int32 y = 2;\n}")
+	// Definitions keep their own message
+	assert.Contains(t, got, "message Point {\n  double x = 1;\n  double y = 2;\n}")
+	for _, merged := range []string{"message Origin", "message Target", "message PathItem", "message NamedValue", "message To "} {
+		assert.NotContains(t, got, merged)
+	}
+	assert.Empty(t, Validate(got))
+}
@@ -0,0 +1,107 @@
+package converter
+
+import "sort"
+
+// messageDependencies returns the names of the types referenced by msg's
+// fields, sorted and de-duplicated. Scalars are included; callers filter to
+// the types they know about.
+func messageDependencies(msg *Message) []string {
+	set := make(map[string]bool)
+	for _, field := range msg.Fields {
+		for _, typ := range referencedTypes(field.Type) {
+			set[typ] = true
+		}
+	}
+	deps := make([]string, 0, len(set))
+	for typ := range set {
+		deps = append(deps, typ)
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// topologicalOrder orders messages so each follows the messages its fields
+// refer to. Messages in a reference cycle are kept together, sorted by name,
+// and independent messages keep their alphabetical order.
+func topologicalOrder(messages []*Message) []*Message {
+	byName := make(map[string]*Message, len(messages))
+	nodes := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		byName[msg.Name] = msg
+		nodes = append(nodes, msg.Name)
+	}
+	edges := make(map[string][]string, len(messages))
+	for _, msg := range messages {
+		for _, dep := range messageDependencies(msg) {
+			if _, ok := byName[dep]; ok {
+				edges[msg.Name] = append(edges[msg.Name], dep)
+			}
+		}
+	}
+
+	// Tarjan's algorithm completes a component only after every component
+	// it refers to, so the components come out in dependency order
+	ordered := make([]*Message, 0, len(messages))
+	for _, component := range stronglyConnected(nodes, edges) {
+		for _, name := range component {
+			ordered = append(ordered, byName[name])
+		}
+	}
+	return ordered
+}
+
+// stronglyConnected returns the strongly connected components of a directed
+// graph using Tarjan's algorithm. Nodes and edges are visited in sorted order
+// so the result is deterministic; members of each component are sorted.
+func stronglyConnected(nodes []string, edges map[string][]string) [][]string {
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+	next := 0
+
+	var visit func(n string)
+	visit = func(n string) {
+		index[n] = next
+		lowlink[n] = next
+		next++
+		stack = append(stack, n)
+		onStack[n] = true
+
+		for _, m := range edges[n] {
+			if _, seen := index[m]; !seen {
+				visit(m)
+				if lowlink[m] < lowlink[n] {
+					lowlink[n] = lowlink[m]
+				}
+			} else if onStack[m] && index[m] < lowlink[n] {
+				lowlink[n] = index[m]
+			}
+		}
+
+		if lowlink[n] == index[n] {
+			var component []string
+			for {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[m] = false
+				component = append(component, m)
+				if m == n {
+					break
+				}
+			}
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+
+	sorted := append([]string(nil), nodes...)
+	sort.Strings(sorted)
+	for _, n := range sorted {
+		if _, seen := index[n]; !seen {
+			visit(n)
+		}
+	}
+	return components
+}
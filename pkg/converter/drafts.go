@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Draft identifies a JSON Schema specification version
+type Draft string
+
+const (
+	// Draft4 is draft-04, where exclusiveMinimum and exclusiveMaximum are
+	// booleans modifying minimum and maximum and schemas can't be booleans
+	Draft4 Draft = "draft-04"
+	// Draft6 is draft-06, which made exclusiveMinimum and exclusiveMaximum
+	// numbers and introduced boolean schemas
+	Draft6 Draft = "draft-06"
+	// Draft7 is draft-07
+	Draft7 Draft = "draft-07"
+	// Draft201909 is draft 2019-09, which introduced $defs
+	Draft201909 Draft = "2019-09"
+	// Draft202012 is draft 2020-12
+	Draft202012 Draft = "2020-12"
+)
+
+// drafts lists the supported drafts from oldest to newest
+var drafts = []Draft{Draft4, Draft6, Draft7, Draft201909, Draft202012}
+
+// draftFromURI returns the draft a $schema meta-schema URI such as
+// http://json-schema.org/draft-07/schema# or
+// https://json-schema.org/draft/2020-12/schema declares
+func draftFromURI(uri string) (Draft, bool) {
+	for _, d := range drafts {
+		if strings.Contains(uri, "/"+string(d)+"/") || strings.Contains(uri, "/draft/"+string(d)+"/") {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// schemaDraft chooses the draft used to interpret schema: Options.Draft when
+// set, otherwise the one its $schema declares, falling back to
+// Options.DefaultDraft. The empty draft accepts the forms of every draft.
+func (c *conversion) schemaDraft(schema map[string]interface{}) (Draft, error) {
+	for _, d := range []Draft{c.opts.Draft, c.opts.DefaultDraft} {
+		if d != "" && draftIndex(d) < 0 {
+			return "", fmt.Errorf("unsupported draft %q", d)
+		}
+	}
+	if c.opts.Draft != "" {
+		return c.opts.Draft, nil
+	}
+	if uri, ok := schema["$schema"].(string); ok {
+		if d, ok := draftFromURI(uri); ok {
+			return d, nil
+		}
+		if c.opts.DefaultDraft != "" {
+			c.warnf("#/$schema", WarnUnknownDraft, "unrecognized $schema %q; interpreting the schema as %s", uri, c.opts.DefaultDraft)
+		} else {
+			c.warnf("#/$schema", WarnUnknownDraft, "unrecognized $schema %q; accepting the keywords of every draft", uri)
+		}
+	}
+	return c.opts.DefaultDraft, nil
+}
+
+// draftIndex returns the position of d in drafts, or -1 if it isn't a
+// supported draft
+func draftIndex(d Draft) int {
+	for i, known := range drafts {
+		if d == known {
+			return i
+		}
+	}
+	return -1
+}
+
+// before reports whether d is a draft older than other. The empty draft,
+// which accepts the forms of every draft, is never before another.
+func (d Draft) before(other Draft) bool {
+	return d != "" && draftIndex(d) < draftIndex(other)
+}
+
+// hoistDefs moves the $defs of a schema using 2019-09 or later into its
+// definitions, so that both are converted as top-level definitions, and
+// reports whether it did. References into $defs are redirected by
+// refPointer. Definitions take precedence when a name is in both.
+func hoistDefs(schema map[string]interface{}, draft Draft) (map[string]interface{}, bool) {
+	defs, ok := schema["$defs"].(map[string]interface{})
+	if !ok || draft.before(Draft201909) {
+		return schema, false
+	}
+	out := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		out[k] = v
+	}
+	merged := make(map[string]interface{}, len(defs))
+	for name, def := range defs {
+		merged[name] = def
+	}
+	existing, _ := out["definitions"].(map[string]interface{})
+	for name, def := range existing {
+		merged[name] = def
+	}
+	out["definitions"] = merged
+	delete(out, "$defs")
+	return out, true
+}
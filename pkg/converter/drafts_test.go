@@ -0,0 +1,106 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDraftExclusiveBounds(t *testing.T) {
+	const draft4 = "http://json-schema.org/draft-04/schema#"
+	const draft6 = "http://json-schema.org/draft-06/schema#"
+
+	tests := []struct {
+		name    string
+		schema  string
+		draft   Draft
+		want    string
+		notWant string
+	}{
+		{
+			name:   "draft-04 boolean",
+			schema: `{"$schema": "` + draft4 + `", "type": "object", "properties": {"n": {"type": "number", "minimum": 1, "exclusiveMinimum": true}}}`,
+			want:   "  // range: exclusiveMin=1\n  double n = 1;",
+		},
+		{
+			name:   "draft-06 ignores a boolean",
+			schema: `{"$schema": "` + draft6 + `", "type": "object", "properties": {"n": {"type": "number", "minimum": 1, "exclusiveMinimum": true}}}`,
+			want:   "  // range: min=1\n  double n = 1;",
+		},
+		{
+			name:   "draft-06 number",
+			schema: `{"$schema": "` + draft6 + `", "type": "object", "properties": {"n": {"type": "number", "exclusiveMinimum": 1}}}`,
+			want:   "  // range: exclusiveMin=1\n  double n = 1;",
+		},
+		{
+			name:    "draft-04 ignores a number",
+			schema:  `{"$schema": "` + draft4 + `", "type": "object", "properties": {"n": {"type": "number", "exclusiveMinimum": 1}}}`,
+			want:    "  double n = 1;",
+			notWant: "exclusiveMin",
+		},
+		{
+			name:   "Draft overrides $schema",
+			schema: `{"$schema": "` + draft6 + `", "type": "object", "properties": {"n": {"type": "number", "maximum": 9, "exclusiveMaximum": true}}}`,
+			draft:  Draft4,
+			want:   "  // range: exclusiveMax=9\n  double n = 1;",
+		},
+		{
+			name:   "no $schema accepts both forms",
+			schema: `{"type": "object", "properties": {"a": {"type": "number", "minimum": 1, "exclusiveMinimum": true}, "b": {"type": "number", "exclusiveMinimum": 2}}}`,
+			want:   "  // range: exclusiveMin=1\n  double a = 1;\n  // range: exclusiveMin=2\n  double b = 2;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Draft = tt.draft
+			opts.EmitConstraintComments = true
+			got, err := ConvertJSONSchemaToProto(tt.schema, opts)
+			require.NoError(t, err)
+			assert.Contains(t, got, tt.want)
+			if tt.notWant != "" {
+				assert.NotContains(t, got, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestDraftDefs(t *testing.T) {
+	schema := `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object",
+		"properties": {"address": {"$ref": "#/$defs/Address"}},
+		"$defs": {"Address": {"type": "object", "properties": {"city": {"type": "string"}}}}}`
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  Address address = 1;")
+	assert.Contains(t, got, "message Address {\n  string city = 1;\n}")
+
+	// Before 2019-09 $defs is an ordinary keyword, whose entries are only
+	// converted where they are referenced
+	opts := DefaultOptions()
+	opts.Draft = Draft7
+	got, err = ConvertJSONSchemaToProto(`{"$defs": {"Address": {"type": "object", "properties": {"city": {"type": "string"}}}}}`, opts)
+	require.NoError(t, err)
+	assert.NotContains(t, got, "message Address")
+}
+
+func TestDraftSelection(t *testing.T) {
+	_, err := ConvertJSONSchemaToProto(`{"$schema": "http://json-schema.org/draft-04/schema#", "type": "object",
+		"properties": {"any": true}}`, DefaultOptions())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "#/properties/any (message Root): boolean schemas require draft-06 or later")
+
+	opts := DefaultOptions()
+	opts.DefaultDraft = Draft4
+	_, warnings, err := ConvertWithReport(`{"$schema": "https://example.com/custom-meta", "type": "object",
+		"properties": {"n": {"type": "integer"}}}`, opts)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, WarnUnknownDraft, warnings[0].Code)
+	assert.Equal(t, `#/$schema: unrecognized $schema "https://example.com/custom-meta"; interpreting the schema as draft-04`, warnings[0].String())
+
+	opts = DefaultOptions()
+	opts.Draft = "draft-99"
+	_, err = ConvertJSONSchemaToProto(`{}`, opts)
+	assert.EqualError(t, err, `unsupported draft "draft-99"`)
+}
@@ -0,0 +1,248 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stringEnumValues returns the values of a string-valued enum schema. It
+// reports false when the schema has no enum or its values aren't all strings.
+func stringEnumValues(propMap map[string]interface{}) ([]string, bool) {
+	raw, ok := propMap["enum"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, false
+	}
+	if propType, _ := propMap["type"].(string); propType != "" && propType != "string" {
+		return nil, false
+	}
+	values := make([]string, 0, len(raw))
+	for _, v := range raw {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, s)
+	}
+	return values, true
+}
+
+// integerEnumValues returns the values of an integer-valued enum schema. It
+// reports false when the schema has no enum or its values aren't all integers.
+func integerEnumValues(propMap map[string]interface{}) ([]int, bool) {
+	raw, ok := propMap["enum"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, false
+	}
+	if propType, _ := propMap["type"].(string); propType != "" && propType != "integer" && propType != "number" {
+		return nil, false
+	}
+	values := make([]int, 0, len(raw))
+	for _, v := range raw {
+		f, ok := v.(float64)
+		if !ok || f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+			return nil, false
+		}
+		values = append(values, int(f))
+	}
+	return values, true
+}
+
+// processEnumProperty converts a property declaring an enum. String enums are
+// promoted to enum types and integer enums to enum types numbered by value.
+// Enums mixing value types can't be represented, so they fall back to the
+// declared scalar type, or google.protobuf.Any when there is none. It reports
+// false when the property has no enum.
+func (c *conversion) processEnumProperty(name string, propMap map[string]interface{}, path string) (fieldType, bool) {
+	if _, ok := propMap["enum"].([]interface{}); !ok {
+		return fieldType{}, false
+	}
+	if values, ok := stringEnumValues(propMap); ok {
+		return c.processInlineEnum(name, values, path), true
+	}
+	if values, ok := integerEnumValues(propMap); ok {
+		return c.processInlineIntegerEnum(name, values, path), true
+	}
+
+	propType, _ := propMap["type"].(string)
+	if propType == "" || propType == "array" || propType == "object" {
+		c.warnf(path, WarnUnrepresentableEnum, "enum for %s has values a proto enum can't represent; using google.protobuf.Any", name)
+		return fieldType{name: "google.protobuf.Any"}, true
+	}
+	format, _ := propMap["format"].(string)
+	protoType := GetProtoType(propType, format, c.opts)
+	c.warnf(path, WarnUnrepresentableEnum, "enum for %s has values a proto enum can't represent; using %s", name, protoType)
+	return fieldType{name: protoType}, true
+}
+
+// processInlineEnum promotes an enum declared directly on a property to a
+// generated enum type named after the property. Properties with identical
+// value sets share a single enum.
+func (c *conversion) processInlineEnum(name string, values []string, path string) fieldType {
+	values = c.enumValueOrder(values)
+	if c.opts.InlineEnumsAsStrings {
+		return fieldType{name: "string", notes: []string{"allowed values: " + strings.Join(values, ", ")}}
+	}
+
+	key := strings.Join(values, "\x00")
+	if enumName, ok := c.inlineEnums[key]; ok {
+		return fieldType{name: enumName}
+	}
+	enumName := c.inlineTypeName(c.messageName(name)+"Enum", path)
+	c.inlineEnums[key] = enumName
+	c.enums[enumName] = c.buildEnum(enumName, "", values)
+	c.enums[enumName].Source = path
+	return fieldType{name: enumName}
+}
+
+// processInlineIntegerEnum promotes an integer enum declared directly on a
+// property to a generated enum type, sharing identical value sets
+func (c *conversion) processInlineIntegerEnum(name string, values []int, path string) fieldType {
+	values = c.integerEnumValueOrder(values)
+	if c.opts.InlineEnumsAsStrings {
+		strs := make([]string, len(values))
+		for i, v := range values {
+			strs[i] = fmt.Sprint(v)
+		}
+		return fieldType{name: GetProtoType("integer", "", c.opts), notes: []string{"allowed values: " + strings.Join(strs, ", ")}}
+	}
+
+	key := fmt.Sprint("int:", values)
+	if enumName, ok := c.inlineEnums[key]; ok {
+		return fieldType{name: enumName}
+	}
+	enumName := c.inlineTypeName(c.messageName(name)+"Enum", path)
+	c.inlineEnums[key] = enumName
+	c.enums[enumName] = c.buildIntegerEnum(enumName, "", values)
+	c.enums[enumName].Source = path
+	return fieldType{name: enumName}
+}
+
+// buildIntegerEnum creates an enum for integer values, numbering each
+// VALUE_<n> constant with the value itself. proto3 requires the first value
+// to be 0, so 0 is moved to the front when present and an UNSPECIFIED value is
+// synthesized when it isn't. With UnspecifiedEnumZero a 0 value is named
+// UNSPECIFIED too.
+func (c *conversion) buildIntegerEnum(name, comment string, values []int) *Enum {
+	prefix := toEnumValueName(name)
+	enum := &Enum{Name: name, Comment: comment}
+
+	ordered := c.integerEnumValueOrder(values)
+	if len(ordered) == 0 || ordered[0] != 0 {
+		enum.Values = append(enum.Values, &EnumValue{Name: prefix + "_UNSPECIFIED", Number: 0})
+	}
+	for _, v := range ordered {
+		valueName := fmt.Sprintf("%s_VALUE_%d", prefix, v)
+		if v == 0 && c.opts.UnspecifiedEnumZero {
+			valueName = prefix + "_UNSPECIFIED"
+		} else if v < 0 {
+			valueName = fmt.Sprintf("%s_VALUE_NEG_%d", prefix, -v)
+		}
+		enum.Values = append(enum.Values, &EnumValue{Name: valueName, Number: v})
+	}
+	return enum
+}
+
+// buildEnum creates an enum whose values are prefixed with the enum name, as
+// proto enum values share their parent's scope. A synthesized UNSPECIFIED value
+// takes number 0 and the schema values follow, numbered from 1 in the order
+// given by enumValueOrder. Distinct values whose names collide, such as
+// in-progress and in_progress, get a _2, _3... suffix and numbers of their
+// own, or with AliasEnumCollisions the number of the first value of that name
+// and an allow_alias option.
+func (c *conversion) buildEnum(name, comment string, values []string) *Enum {
+	prefix := toEnumValueName(name)
+	enum := &Enum{Name: name, Comment: comment}
+	enum.Values = append(enum.Values, &EnumValue{Name: prefix + "_UNSPECIFIED", Number: 0})
+	// numbers maps each base name generated for a schema value to the
+	// number of its first value
+	numbers := make(map[string]int)
+	used := map[string]bool{prefix + "_UNSPECIFIED": true}
+	next := 1
+	for _, v := range c.enumValueOrder(values) {
+		base := fmt.Sprintf("%s_%s", prefix, toEnumValueName(v))
+		valueName := base
+		for n := 2; used[valueName]; n++ {
+			valueName = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[valueName] = true
+		number, collides := numbers[base]
+		if !collides || !c.opts.AliasEnumCollisions {
+			number = next
+			next++
+		}
+		if !collides {
+			numbers[base] = number
+		} else if c.opts.AliasEnumCollisions && len(enum.Options) == 0 {
+			enum.Options = []FileOption{{Name: "allow_alias", Value: "true"}}
+		}
+		value := &EnumValue{Name: valueName, Number: number}
+		if c.opts.EmitEnumValueComments && strings.TrimPrefix(valueName, prefix+"_") != v {
+			value.Comment = compactJSON(v)
+		}
+		enum.Values = append(enum.Values, value)
+	}
+	return enum
+}
+
+// enumValueOrder returns string enum values in the order they are numbered:
+// schema order with repeated values dropped, or sorted when SortEnumValues is
+// set
+func (c *conversion) enumValueOrder(values []string) []string {
+	ordered := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			ordered = append(ordered, v)
+		}
+	}
+	if c.opts.SortEnumValues {
+		sort.Strings(ordered)
+	}
+	return ordered
+}
+
+// integerEnumValueOrder is enumValueOrder for integer enums. 0 always comes
+// first, since proto3 requires it to be the first value.
+func (c *conversion) integerEnumValueOrder(values []int) []int {
+	ordered := make([]int, 0, len(values))
+	seen := make(map[int]bool, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			ordered = append(ordered, v)
+		}
+	}
+	if c.opts.SortEnumValues {
+		sort.Ints(ordered)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i] == 0 && ordered[j] != 0 })
+	return ordered
+}
+
+// toEnumValueName converts a value to UPPER_SNAKE_CASE, splitting camelCase
+// words, including those after an acronym as in HTTPServer, and replacing
+// any character that isn't a letter or digit
+func toEnumValueName(s string) string {
+	var out strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+			unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			out.WriteRune('_')
+		}
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			out.WriteRune(unicode.ToUpper(r))
+		} else {
+			out.WriteRune('_')
+		}
+	}
+	parts := strings.FieldsFunc(out.String(), func(r rune) bool { return r == '_' })
+	if len(parts) == 0 {
+		return "EMPTY"
+	}
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,306 @@
+package converter
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineEnums(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"color": {"type": "string", "enum": ["red", "green", "blue"]},
+			"trim": {"type": "string", "enum": ["red", "green", "blue"]},
+			"status": {"enum": ["in-progress", "doneAlready"]}
+		}
+	}`
+
+	t.Run("promoted to enum types", func(t *testing.T) {
+		got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+		assert.NoError(t, err)
+		assert.Contains(t, got, "ColorEnum color = 1;")
+		assert.Contains(t, got, "StatusEnum status = 2;")
+		assert.Contains(t, got, "ColorEnum trim = 3;")
+		assert.Contains(t, got, `enum ColorEnum {
+  COLOR_ENUM_UNSPECIFIED = 0;
+  COLOR_ENUM_RED = 1;
+  COLOR_ENUM_GREEN = 2;
+  COLOR_ENUM_BLUE = 3;
+}`)
+		assert.Contains(t, got, `enum StatusEnum {
+  STATUS_ENUM_UNSPECIFIED = 0;
+  STATUS_ENUM_IN_PROGRESS = 1;
+  STATUS_ENUM_DONE_ALREADY = 2;
+}`)
+		assert.NotContains(t, got, "TrimEnum")
+		assert.Empty(t, Validate(got))
+	})
+
+	t.Run("kept as strings", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.InlineEnumsAsStrings = true
+		got, err := ConvertJSONSchemaToProto(schema, opts)
+		assert.NoError(t, err)
+		assert.Contains(t, got, "  // allowed values: red, green, blue\n  string color = 1;")
+		assert.NotContains(t, got, "enum ")
+	})
+}
+
+func TestDefinitionEnums(t *testing.T) {
+	schema := `{
+		"definitions": {
+			"Role": {"type": "string", "description": "The sender role", "enum": ["user", "assistant"]},
+			"Message": {"type": "object", "properties": {"role": {"$ref": "#/definitions/Role"}}}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, got, "Role role = 1;")
+	assert.Contains(t, got, `// The sender role
+enum Role {
+  ROLE_UNSPECIFIED = 0;
+  ROLE_USER = 1;
+  ROLE_ASSISTANT = 2;
+}`)
+	assert.NotContains(t, got, "message Role")
+}
+
+func TestEnumValueOrder(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"size": {"type": "string", "enum": ["medium", "small", "large", "small"]},
+			"fit": {"type": "string", "enum": ["large", "medium", "small"]},
+			"level": {"enum": [5, 0, -1, 5]}
+		},
+		"definitions": {
+			"Tone": {"type": "string", "enum": ["warm", "cool"]}
+		}
+	}`
+
+	tests := []struct {
+		name string
+		sort bool
+		want []string
+	}{
+		{
+			name: "schema order",
+			want: []string{
+				"  FitEnum fit = 1;\n  LevelEnum level = 2;\n  SizeEnum size = 3;",
+				"enum SizeEnum {\n  SIZE_ENUM_UNSPECIFIED = 0;\n  SIZE_ENUM_MEDIUM = 1;\n  SIZE_ENUM_SMALL = 2;\n  SIZE_ENUM_LARGE = 3;\n}",
+				"enum FitEnum {\n  FIT_ENUM_UNSPECIFIED = 0;\n  FIT_ENUM_LARGE = 1;\n  FIT_ENUM_MEDIUM = 2;\n  FIT_ENUM_SMALL = 3;\n}",
+				"enum LevelEnum {\n  LEVEL_ENUM_VALUE_0 = 0;\n  LEVEL_ENUM_VALUE_5 = 5;\n  LEVEL_ENUM_VALUE_NEG_1 = -1;\n}",
+				"enum Tone {\n  TONE_UNSPECIFIED = 0;\n  TONE_WARM = 1;\n  TONE_COOL = 2;\n}",
+			},
+		},
+		{
+			name: "sorted",
+			sort: true,
+			want: []string{
+				// Both value sets sort the same, so they share one enum
+				"  FitEnum fit = 1;\n  LevelEnum level = 2;\n  FitEnum size = 3;",
+				"enum FitEnum {\n  FIT_ENUM_UNSPECIFIED = 0;\n  FIT_ENUM_LARGE = 1;\n  FIT_ENUM_MEDIUM = 2;\n  FIT_ENUM_SMALL = 3;\n}",
+				"enum LevelEnum {\n  LEVEL_ENUM_VALUE_0 = 0;\n  LEVEL_ENUM_VALUE_NEG_1 = -1;\n  LEVEL_ENUM_VALUE_5 = 5;\n}",
+				"enum Tone {\n  TONE_UNSPECIFIED = 0;\n  TONE_COOL = 1;\n  TONE_WARM = 2;\n}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.SortEnumValues = tt.sort
+			got, err := ConvertJSONSchemaToProto(schema, opts)
+			assert.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.Empty(t, Validate(got))
+
+			// Numbering only depends on the input
+			again, err := ConvertJSONSchemaToProto(schema, opts)
+			assert.NoError(t, err)
+			assert.Equal(t, got, again)
+		})
+	}
+}
+
+func TestToEnumValueName(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"red", "RED"},
+		{"in-progress", "IN_PROGRESS"},
+		{"inProgress", "IN_PROGRESS"},
+		{"HTTP2", "HTTP2"},
+		{"  spaced  out ", "SPACED_OUT"},
+		{"ColorEnum", "COLOR_ENUM"},
+		{"", "EMPTY"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, toEnumValueName(tt.input), tt.input)
+	}
+}
+
+func TestIntegerEnums(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"priority": {"type": "integer", "enum": [1, 2, 3]},
+			"level": {"enum": [5, 0, -1]}
+		},
+		"definitions": {
+			"Code": {"type": "integer", "enum": [200, 404]}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, got, "LevelEnum level = 1;")
+	assert.Contains(t, got, "PriorityEnum priority = 2;")
+	assert.Contains(t, got, `enum PriorityEnum {
+  PRIORITY_ENUM_UNSPECIFIED = 0;
+  PRIORITY_ENUM_VALUE_1 = 1;
+  PRIORITY_ENUM_VALUE_2 = 2;
+  PRIORITY_ENUM_VALUE_3 = 3;
+}`)
+	assert.Contains(t, got, `enum LevelEnum {
+  LEVEL_ENUM_VALUE_0 = 0;
+  LEVEL_ENUM_VALUE_5 = 5;
+  LEVEL_ENUM_VALUE_NEG_1 = -1;
+}`)
+	assert.Contains(t, got, `enum Code {
+  CODE_UNSPECIFIED = 0;
+  CODE_VALUE_200 = 200;
+  CODE_VALUE_404 = 404;
+}`)
+	assert.Empty(t, Validate(got))
+}
+
+func TestMixedTypeEnums(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	schema := `{
+		"type": "object",
+		"properties": {
+			"untyped": {"enum": ["a", 1, true]},
+			"typed": {"type": "string", "enum": ["a", 1]},
+			"fraction": {"type": "number", "enum": [0.5, 1]}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, got, "double fraction = 1;")
+	assert.Contains(t, got, "string typed = 2;")
+	assert.Contains(t, got, "google.protobuf.Any untyped = 3;")
+	assert.Contains(t, got, `import "google/protobuf/any.proto";`)
+	assert.NotContains(t, got, "enum ")
+	assert.Contains(t, logs.String(), "#/properties/untyped: enum for untyped has values a proto enum can't represent; using google.protobuf.Any")
+	assert.Contains(t, logs.String(), "#/properties/typed: enum for typed has values a proto enum can't represent; using string")
+}
+
+func TestEnumValueCollisions(t *testing.T) {
+	schema := `{"type": "object", "properties": {"status": {"type": "string",
+		"enum": ["in-progress", "in_progress", "done", "In Progress", "unspecified"]}}}`
+
+	tests := []struct {
+		name  string
+		alias bool
+		want  string
+	}{
+		{
+			name: "distinct numbers",
+			want: `enum StatusEnum {
+  STATUS_ENUM_UNSPECIFIED = 0;
+  STATUS_ENUM_IN_PROGRESS = 1;
+  STATUS_ENUM_IN_PROGRESS_2 = 2;
+  STATUS_ENUM_DONE = 3;
+  STATUS_ENUM_IN_PROGRESS_3 = 4;
+  STATUS_ENUM_UNSPECIFIED_2 = 5;
+}`,
+		},
+		{
+			name:  "aliases",
+			alias: true,
+			want: `enum StatusEnum {
+  option allow_alias = true;
+  STATUS_ENUM_UNSPECIFIED = 0;
+  STATUS_ENUM_IN_PROGRESS = 1;
+  STATUS_ENUM_IN_PROGRESS_2 = 1;
+  STATUS_ENUM_DONE = 2;
+  STATUS_ENUM_IN_PROGRESS_3 = 1;
+  STATUS_ENUM_UNSPECIFIED_2 = 3;
+}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.AliasEnumCollisions = tt.alias
+			got, err := ConvertJSONSchemaToProto(schema, opts)
+			require.NoError(t, err)
+			assert.Contains(t, got, tt.want)
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestEnumValueComments(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"status": {"enum": ["in-progress", "DONE", "doneAlready", "Done", "a \"quoted\" value"]}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.EmitEnumValueComments = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, `enum StatusEnum {
+  STATUS_ENUM_UNSPECIFIED = 0;
+  STATUS_ENUM_IN_PROGRESS = 1; // "in-progress"
+  STATUS_ENUM_DONE = 2;
+  STATUS_ENUM_DONE_ALREADY = 3; // "doneAlready"
+  STATUS_ENUM_DONE_2 = 4; // "Done"
+  STATUS_ENUM_A_QUOTED_VALUE = 5; // "a \"quoted\" value"
+}`)
+	assert.Empty(t, Validate(got))
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "STATUS_ENUM_IN_PROGRESS = 1;\n")
+	assert.NotContains(t, got, "//")
+}
+
+func TestEnumsWithoutMessages(t *testing.T) {
+	schema := `{"definitions": {"Color": {"type": "string", "enum": ["red", "green"]}, "Size": {"type": "string", "enum": ["small"]}}}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, `syntax = "proto3";
+
+package schema;
+
+enum Color {
+  COLOR_UNSPECIFIED = 0;
+  COLOR_RED = 1;
+  COLOR_GREEN = 2;
+}
+
+enum Size {
+  SIZE_UNSPECIFIED = 0;
+  SIZE_SMALL = 1;
+}
+`, got)
+}
@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError reports malformed JSON input along with where it went wrong
+type ParseError struct {
+	// Offset is the byte offset in the input at which the error was detected
+	Offset int64
+	// Line and Column are the 1-based position of the offending character;
+	// Column counts characters, not bytes
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error at line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// unmarshalJSON decodes data into v, converting syntax and type errors into a
+// ParseError locating the problem
+func unmarshalJSON(data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	line, column := lineColumn(data, offset)
+	return &ParseError{Offset: offset, Line: line, Column: column, Err: err}
+}
+
+// lineColumn converts the offset reported by encoding/json, which counts the
+// offending byte as already read, into a 1-based line and column
+func lineColumn(data []byte, offset int64) (int, int) {
+	pos := int(offset) - 1
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(data) {
+		pos = len(data)
+	}
+	before := data[:pos]
+	line := 1 + strings.Count(string(before), "\n")
+	lineStart := strings.LastIndexByte(string(before), '\n') + 1
+	return line, utf8.RuneCount(before[lineStart:]) + 1
+}
+
+// PathError is a conversion error tagged with the schema location where it occurred
+type PathError struct {
+	// Path is a JSON-pointer-style location, e.g. #/definitions/Order/properties/lines
+	Path string
+	// Message is the proto message being generated when the error occurred
+	Message string
+	Err     error
+}
+
+func (e *PathError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s (message %s): %v", e.Path, e.Message, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// ConversionError aggregates every error found while converting a schema
+type ConversionError struct {
+	Errors []*PathError
+}
+
+func (e *ConversionError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%d errors converting schema:", len(e.Errors)))
+	for _, err := range e.Errors {
+		out.WriteString("\n  ")
+		out.WriteString(err.Error())
+	}
+	return out.String()
+}
+
+// Unwrap exposes the individual errors to errors.Is and errors.As
+func (e *ConversionError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// pointerJoin appends a reference token to a JSON pointer, escaping it per RFC 6901
+func pointerJoin(base string, tokens ...string) string {
+	for _, token := range tokens {
+		token = strings.ReplaceAll(token, "~", "~0")
+		token = strings.ReplaceAll(token, "/", "~1")
+		base += "/" + token
+	}
+	return base
+}
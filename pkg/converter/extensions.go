@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// messageOptionsKeyword is the definition extension whose entries are emitted
+// as message options when EmitVendorExtensions is set, e.g.
+// {"x-proto-message-options": {"(my.table)": "orders"}}
+const messageOptionsKeyword = "x-proto-message-options"
+
+// applyVendorExtensions handles the x- extensions of the schema a message was
+// generated from. x-proto-message-options entries become message options,
+// validated and formatted like x-proto-options field options; other x- keys
+// are dropped, or documented as "key: value" comment lines with
+// VendorExtensionComments. x-proto-options, which holds field options, is
+// never treated as unrecognized.
+func (c *conversion) applyVendorExtensions(msg *Message, schema map[string]interface{}, path string) error {
+	if !c.opts.EmitVendorExtensions {
+		return nil
+	}
+	keys := make([]string, 0, len(schema))
+	for k := range schema {
+		if strings.HasPrefix(k, "x-") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case messageOptionsKeyword:
+			options, err := extensionOptions(schema, key, path)
+			if err != nil {
+				return err
+			}
+			for _, opt := range options {
+				msg.Options = setMessageOption(msg.Options, FileOption{Name: opt.Name, Value: opt.Value})
+			}
+		case protoOptionsKeyword:
+		default:
+			if !c.opts.VendorExtensionComments {
+				continue
+			}
+			value, err := json.Marshal(schema[key])
+			if err != nil {
+				return &PathError{Path: pointerJoin(path, key), Err: err}
+			}
+			msg.Comment = appendComment(msg.Comment, key+": "+string(value))
+		}
+	}
+	return nil
+}
+
+// setMessageOption adds opt to opts, replacing an option of the same name
+func setMessageOption(opts []FileOption, opt FileOption) []FileOption {
+	for i, o := range opts {
+		if o.Name == opt.Name {
+			out := append([]FileOption(nil), opts...)
+			out[i] = opt
+			return out
+		}
+	}
+	return append(opts, opt)
+}
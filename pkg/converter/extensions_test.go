@@ -0,0 +1,68 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVendorExtensions(t *testing.T) {
+	def := `{"type": "object", "deprecated": true, "description": "An order",
+		"x-proto-message-options": {"(my.table)": "orders", "deprecated": false},
+		"x-go-name": "OrderModel",
+		"x-tags": ["billing", "v2"],
+		"x-proto-options": {"(my.field)": true},
+		"properties": {"id": {"type": "string"}}}`
+	schema := `{"definitions": {"Order": ` + def + `}}`
+
+	tests := []struct {
+		name     string
+		emit     bool
+		comments bool
+		want     string
+	}{
+		{
+			name: "disabled",
+			want: "// An order\nmessage Order {\n  option deprecated = true;\n  string id = 1;\n}",
+		},
+		{
+			name: "unrecognized keys dropped",
+			emit: true,
+			want: "// An order\nmessage Order {\n  option deprecated = false;\n  option (my.table) = \"orders\";\n  string id = 1;\n}",
+		},
+		{
+			name:     "unrecognized keys as comments",
+			emit:     true,
+			comments: true,
+			want:     "// An order\n// x-go-name: \"OrderModel\"\n// x-tags: [\"billing\",\"v2\"]\nmessage Order {\n  option deprecated = false;\n  option (my.table) = \"orders\";\n  string id = 1;\n}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.EmitVendorExtensions = tt.emit
+			opts.VendorExtensionComments = tt.comments
+			got, err := ConvertJSONSchemaToProto(schema, opts)
+			require.NoError(t, err)
+			assert.Contains(t, got, tt.want)
+		})
+	}
+
+	opts := DefaultOptions()
+	opts.EmitVendorExtensions = true
+	got, err := ConvertJSONSchemaToProto(`{"type": "object", "x-proto-message-options": {"(my.root)": 1},
+		"properties": {"id": {"type": "string"}}}`, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "message Root {\n  option (my.root) = 1;\n")
+}
+
+func TestVendorExtensionErrors(t *testing.T) {
+	opts := DefaultOptions()
+	opts.EmitVendorExtensions = true
+	_, err := ConvertJSONSchemaToProto(`{"definitions": {"Order": {"type": "object",
+		"x-proto-message-options": {"bad name": true}}}}`, opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `#/definitions/Order/x-proto-message-options/bad name`)
+	assert.Contains(t, err.Error(), `invalid option name "bad name"`)
+}
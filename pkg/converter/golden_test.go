@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// TestGoldenDeterministicOutput converts each schema in testdata and compares
+// the result with the .proto file beside it. collisions.json has inline
+// schemas whose generated names clash with each other and with definitions.
+func TestGoldenDeterministicOutput(t *testing.T) {
+	log.SetOutput(&bytes.Buffer{})
+	defer log.SetOutput(os.Stderr)
+
+	for _, name := range []string{"complex", "collisions"} {
+		t.Run(name, func(t *testing.T) {
+			schema, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+			require.NoError(t, err)
+			goldenPath := filepath.Join("testdata", name+".proto")
+
+			first, err := ConvertJSONSchemaToProto(string(schema), DefaultOptions())
+			require.NoError(t, err)
+			if *update {
+				require.NoError(t, os.WriteFile(goldenPath, []byte(first), 0644))
+			}
+
+			golden, err := os.ReadFile(goldenPath)
+			require.NoError(t, err)
+			assert.Equal(t, string(golden), first)
+
+			for i := 0; i < 100; i++ {
+				got, err := ConvertJSONSchemaToProto(string(schema), DefaultOptions())
+				require.NoError(t, err)
+				if got != first {
+					t.Fatalf("run %d produced different output:\n%s", i, got)
+				}
+			}
+		})
+	}
+}
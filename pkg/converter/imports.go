@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"sort"
+	"strings"
+)
+
+// wellKnownImports maps well-known proto types to the file that declares them
+var wellKnownImports = map[string]string{
+	"google.protobuf.Any":         "google/protobuf/any.proto",
+	"google.protobuf.Duration":    "google/protobuf/duration.proto",
+	"google.protobuf.Empty":       "google/protobuf/empty.proto",
+	"google.protobuf.FieldMask":   "google/protobuf/field_mask.proto",
+	"google.protobuf.Struct":      "google/protobuf/struct.proto",
+	"google.protobuf.Value":       "google/protobuf/struct.proto",
+	"google.protobuf.ListValue":   "google/protobuf/struct.proto",
+	"google.protobuf.NullValue":   "google/protobuf/struct.proto",
+	"google.protobuf.Timestamp":   "google/protobuf/timestamp.proto",
+	"google.protobuf.DoubleValue": "google/protobuf/wrappers.proto",
+	"google.protobuf.FloatValue":  "google/protobuf/wrappers.proto",
+	"google.protobuf.Int64Value":  "google/protobuf/wrappers.proto",
+	"google.protobuf.UInt64Value": "google/protobuf/wrappers.proto",
+	"google.protobuf.Int32Value":  "google/protobuf/wrappers.proto",
+	"google.protobuf.UInt32Value": "google/protobuf/wrappers.proto",
+	"google.protobuf.BoolValue":   "google/protobuf/wrappers.proto",
+	"google.protobuf.StringValue": "google/protobuf/wrappers.proto",
+	"google.protobuf.BytesValue":  "google/protobuf/wrappers.proto",
+	"google.type.Date":            "google/type/date.proto",
+	"google.type.Decimal":         "google/type/decimal.proto",
+	"google.type.Money":           "google/type/money.proto",
+	"google.type.TimeOfDay":       "google/type/timeofday.proto",
+}
+
+// collectImports returns the sorted, de-duplicated set of imports required by
+// the field types and validation options used in file, plus any extra imports requested by the caller.
+// typeImports names the files declaring custom types, as Options.TypeImports.
+func collectImports(file *ProtoFile, extra []string, typeImports map[string]string) []string {
+	set := make(map[string]bool)
+	for _, imp := range extra {
+		if imp != "" {
+			set[imp] = true
+		}
+	}
+	for _, msg := range allMessages(file.Messages) {
+		for _, field := range msg.Fields {
+			for _, typ := range referencedTypes(field.Type) {
+				if imp, ok := typeImports[typ]; ok {
+					set[imp] = true
+				} else if imp, ok := wellKnownImports[typ]; ok {
+					set[imp] = true
+				}
+			}
+			for _, opt := range field.Options {
+				if strings.HasPrefix(opt.Name, "(validate.rules)") {
+					set[validateImport] = true
+				}
+			}
+		}
+	}
+
+	imports := make([]string, 0, len(set))
+	for imp := range set {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// allMessages returns messages followed by the messages nested within them,
+// at any depth
+func allMessages(messages []*Message) []*Message {
+	var all []*Message
+	for _, msg := range messages {
+		all = append(all, msg)
+		all = append(all, allMessages(msg.Messages)...)
+	}
+	return all
+}
+
+// allEnums returns the top-level enums of file followed by those nested in
+// its messages
+func allEnums(file *ProtoFile) []*Enum {
+	all := append([]*Enum(nil), file.Enums...)
+	for _, msg := range allMessages(file.Messages) {
+		all = append(all, msg.Enums...)
+	}
+	return all
+}
+
+// referencedTypes returns the type names referenced by a field type, looking
+// inside map<K, V> types
+func referencedTypes(typ string) []string {
+	if strings.HasPrefix(typ, "map<") && strings.HasSuffix(typ, ">") {
+		parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(typ, "map<"), ">"), ",", 2)
+		types := make([]string, 0, len(parts))
+		for _, part := range parts {
+			types = append(types, referencedTypes(strings.TrimSpace(part))...)
+		}
+		return types
+	}
+	return []string{strings.TrimPrefix(typ, "repeated ")}
+}
@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// isMapObject reports whether an object schema describes a keyed map: it has
+// no declared properties but constrains its values through a non-empty
+// additionalProperties schema or through patternProperties
+func isMapObject(propMap map[string]interface{}) bool {
+	if props, ok := propMap["properties"].(map[string]interface{}); ok && len(props) > 0 {
+		return false
+	}
+	if patterns, ok := propMap["patternProperties"].(map[string]interface{}); ok && len(patterns) > 0 {
+		return true
+	}
+	additional, ok := propMap["additionalProperties"].(map[string]interface{})
+	return ok && len(additional) > 0
+}
+
+// processMap converts a map-like object into a map<string, V> field. Value
+// schemas from additionalProperties and every patternProperties entry must
+// agree on a single proto type; when they don't, the map falls back to
+// google.protobuf.Any values. Array and map values, which proto maps can't
+// hold directly, are wrapped in a generated message. Key patterns from
+// patternProperties and propertyNames are documented in comments, and
+// minProperties/maxProperties become size constraints.
+func (c *conversion) processMap(name string, propMap map[string]interface{}, path string) (fieldType, error) {
+	var valueTypes []string
+	var notes []string
+	// keyPatterns are the patternProperties patterns, one of which a key
+	// matches
+	var keyPatterns []string
+
+	if additional, ok := propMap["additionalProperties"].(map[string]interface{}); ok && len(additional) > 0 {
+		vt, err := c.processPropertyCollect(name+"Value", additional, pointerJoin(path, "additionalProperties"))
+		if err != nil {
+			return fieldType{}, err
+		}
+		vt = c.singleValue(name, name+"Value", vt, pointerJoin(path, "additionalProperties"))
+		valueTypes = append(valueTypes, c.wrapNested(vt))
+	}
+
+	if patterns, ok := propMap["patternProperties"].(map[string]interface{}); ok && len(patterns) > 0 {
+		keys := make([]string, 0, len(patterns))
+		for k := range patterns {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, pattern := range keys {
+			vt, err := c.processPropertyCollect(name+"Value", patterns[pattern], pointerJoin(path, "patternProperties", pattern))
+			if err != nil {
+				return fieldType{}, err
+			}
+			vt = c.singleValue(name, name+"Value", vt, pointerJoin(path, "patternProperties", pattern))
+			if vt.name == "" {
+				// A false schema forbids keys matching the pattern, so
+				// leave it out of the keys note
+				continue
+			}
+			valueTypes = append(valueTypes, c.wrapNested(vt))
+			keyPatterns = append(keyPatterns, pattern)
+		}
+	}
+	// propertyNames constrains every key, whichever schema its value matches
+	namePattern := ""
+	if names, ok := propMap["propertyNames"].(map[string]interface{}); ok {
+		namePattern, _ = names["pattern"].(string)
+	}
+	if note := keysNote(keyPatterns, namePattern); note != "" {
+		notes = append(notes, note)
+	}
+	if len(valueTypes) == 0 {
+		return fieldType{}, nil
+	}
+
+	valueType := valueTypes[0]
+	for _, vt := range valueTypes[1:] {
+		if vt != valueType {
+			c.warnf(path, WarnMixedMapValues, "map values have differing types (%s); using google.protobuf.Any", strings.Join(valueTypes, ", "))
+			valueType = "google.protobuf.Any"
+			break
+		}
+	}
+	ft := fieldType{name: fmt.Sprintf("map<string, %s>", valueType), notes: notes}
+	c.applyConstraints(&ft, "map", mapBounds(propMap))
+	return ft, nil
+}
+
+// keysNote documents the keys of a map: each matches one of patterns, from
+// patternProperties, and namePattern, from propertyNames, as in
+// "keys match: (^x- | ^y-) and ^[a-z-]+$". It returns "" when neither
+// constrains the keys.
+func keysNote(patterns []string, namePattern string) string {
+	var rules []string
+	if len(patterns) > 0 {
+		rule := strings.Join(patterns, " | ")
+		if len(patterns) > 1 && namePattern != "" {
+			rule = "(" + rule + ")"
+		}
+		rules = append(rules, rule)
+	}
+	if namePattern != "" {
+		rules = append(rules, namePattern)
+	}
+	if len(rules) == 0 {
+		return ""
+	}
+	return "keys match: " + strings.Join(rules, " and ")
+}
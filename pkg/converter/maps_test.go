@@ -0,0 +1,148 @@
+package converter
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		prop     string
+		want     []string
+		warnings string
+	}{
+		{
+			name: "additionalProperties",
+			prop: `{"type": "object", "additionalProperties": {"type": "integer"}}`,
+			want: []string{"map<string, int32> labels = 1;"},
+		},
+		{
+			name: "single pattern",
+			prop: `{"type": "object", "patternProperties": {"^x-": {"type": "string"}}}`,
+			want: []string{"  // keys match: ^x-\n  map<string, string> labels = 1;"},
+		},
+		{
+			name: "patterns with same value type collapse",
+			prop: `{"type": "object", "patternProperties": {"^x-": {"type": "string"}, "^y-": {"type": "string"}}}`,
+			want: []string{"  // keys match: ^x- | ^y-\n  map<string, string> labels = 1;"},
+		},
+		{
+			name: "patterns with differing value types",
+			prop: `{"type": "object", "patternProperties": {"^n-": {"type": "number"}, "^s-": {"type": "string"}}}`,
+			want: []string{
+				`import "google/protobuf/any.proto";`,
+				"  // keys match: ^n- | ^s-\n  map<string, google.protobuf.Any> labels = 1;",
+			},
+			warnings: "map values have differing types (double, string)",
+		},
+		{
+			name:     "patterns with false schemas aren't listed",
+			prop:     `{"type": "object", "patternProperties": {"^x-": {"type": "string"}, "^y-": false}}`,
+			want:     []string{"  // keys match: ^x-\n  map<string, string> labels = 1;"},
+			warnings: "labelsValue has a false schema",
+		},
+		{
+			name: "propertyNames pattern",
+			prop: `{"type": "object", "additionalProperties": {"type": "string"}, "propertyNames": {"pattern": "^[a-z]+$"}}`,
+			want: []string{"  // keys match: ^[a-z]+$\n  map<string, string> labels = 1;"},
+		},
+		{
+			name: "propertyNames with patternProperties",
+			prop: `{"type": "object", "patternProperties": {"^x-": {"type": "string"}}, "propertyNames": {"pattern": "^x-[a-z]+$"}}`,
+			want: []string{"  // keys match: ^x- and ^x-[a-z]+$\n  map<string, string> labels = 1;"},
+		},
+		{
+			name: "propertyNames with several patternProperties",
+			prop: `{"type": "object", "patternProperties": {"^x-": {"type": "string"}, "^y-": {"type": "string"}}, "propertyNames": {"pattern": "^[a-z-]+$"}}`,
+			want: []string{"  // keys match: (^x- | ^y-) and ^[a-z-]+$\n  map<string, string> labels = 1;"},
+		},
+		{
+			name: "array values are wrapped",
+			prop: `{"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}}`,
+			want: []string{
+				"  map<string, StringList> labels = 1;",
+				"message StringList {\n  repeated string values = 1;\n}",
+			},
+		},
+		{
+			name: "map values are wrapped",
+			prop: `{"type": "object", "additionalProperties": {"type": "object", "additionalProperties": {"type": "integer"}}}`,
+			want: []string{
+				"  map<string, Int32Map> labels = 1;",
+				"message Int32Map {\n  map<string, int32> values = 1;\n}",
+			},
+		},
+		{
+			name: "maps of maps of arrays",
+			prop: `{"type": "object", "additionalProperties": {"type": "object", "patternProperties": {"^x-": {"type": "array", "items": {"type": "number"}}}}}`,
+			want: []string{
+				"  map<string, DoubleListMap> labels = 1;",
+				"message DoubleListMap {\n  map<string, DoubleList> values = 1;\n}",
+				"message DoubleList {\n  repeated double values = 1;\n}",
+			},
+		},
+		{
+			name: "array of maps",
+			prop: `{"type": "array", "items": {"type": "object", "additionalProperties": {"type": "boolean"}}}`,
+			want: []string{
+				"  repeated BoolMap labels = 1;",
+				"message BoolMap {\n  map<string, bool> values = 1;\n}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			schema := `{"type": "object", "properties": {"labels": ` + tt.prop + `}}`
+			got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+			assert.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.NotContains(t, got, "message Labels")
+			assert.Empty(t, Validate(got))
+			if tt.warnings == "" {
+				assert.Empty(t, logs.String())
+			} else {
+				assert.Contains(t, logs.String(), tt.warnings)
+			}
+		})
+	}
+}
+
+func TestMapWrapperNamedLikeDefinition(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"counts": {"type": "object", "additionalProperties": {"type": "object", "additionalProperties": {"type": "integer"}}},
+			"tags": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}}
+		},
+		"definitions": {
+			"Int32Map": {"type": "object", "properties": {"x": {"type": "string"}}},
+			"StringList": {"type": "object", "properties": {"y": {"type": "string"}}}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	for _, want := range []string{
+		"  map<string, Int32Map2> counts = 1;",
+		"  map<string, StringList2> tags = 2;",
+		"message Int32Map {\n  string x = 1;\n}",
+		"message Int32Map2 {\n  map<string, int32> values = 1;\n}",
+		"message StringList {\n  string y = 1;\n}",
+		"message StringList2 {\n  repeated string values = 1;\n}",
+	} {
+		assert.Contains(t, got, want)
+	}
+	assert.Empty(t, Validate(got))
+}
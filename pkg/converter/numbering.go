@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+)
+
+// fieldNumberKeyword sets a property's field number explicitly
+const fieldNumberKeyword = "x-proto-field-number"
+
+// fieldNumberAllocator hands out the field numbers of a message. Every field
+// draws its number from the message's allocator, whatever its shape: scalar,
+// repeated and map fields take one number each, and the members of a oneof
+// take one each from the same space as the message's other fields. Numbers
+// start at 1 and are step apart, skipping numbers reserved explicitly and the
+// range protobuf reserves for its own use.
+type fieldNumberAllocator struct {
+	next int
+	step int
+	used map[int]bool
+	// err is set once the numbers run past the largest field number
+	err error
+}
+
+// newFieldNumberAllocator returns an allocator starting at field number 1
+// and spacing numbers step apart; a step below 1 numbers sequentially
+func newFieldNumberAllocator(step int) *fieldNumberAllocator {
+	if step < 1 {
+		step = 1
+	}
+	return &fieldNumberAllocator{next: 1, step: step, used: make(map[int]bool)}
+}
+
+// reserve marks n as taken, so allocate never hands it out
+func (a *fieldNumberAllocator) reserve(n int) {
+	a.used[n] = true
+}
+
+// allocate returns the lowest unused field number not below the previous one
+// plus the step
+func (a *fieldNumberAllocator) allocate() int {
+	for a.used[a.next] || (a.next >= reservedFieldNumberStart && a.next <= reservedFieldNumberEnd) {
+		a.next++
+	}
+	n := a.next
+	if n > maxFieldNumber && a.err == nil {
+		a.err = fmt.Errorf("field numbers exceed the maximum of %d", maxFieldNumber)
+	}
+	a.used[n] = true
+	a.next += a.step
+	return n
+}
+
+// explicitFieldNumber returns the number a property sets with
+// x-proto-field-number, reporting false when it sets none. The number must
+// be a valid field number outside the reserved range.
+func explicitFieldNumber(propMap map[string]interface{}) (int, bool, error) {
+	raw, ok := propMap[fieldNumberKeyword]
+	if !ok {
+		return 0, false, nil
+	}
+	f, ok := raw.(float64)
+	if !ok || f != math.Trunc(f) || f < 1 || f > maxFieldNumber {
+		return 0, false, fmt.Errorf("%s must be an integer from 1 to %d, not %v", fieldNumberKeyword, maxFieldNumber, compactJSON(raw))
+	}
+	n := int(f)
+	if n >= reservedFieldNumberStart && n <= reservedFieldNumberEnd {
+		return 0, false, fmt.Errorf("%s %d is in the range %d-%d reserved by protobuf", fieldNumberKeyword, n, reservedFieldNumberStart, reservedFieldNumberEnd)
+	}
+	return n, true, nil
+}
@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldNumbering(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"a_name": {"type": "string"},
+		"b_labels": {"type": "object", "additionalProperties": {"type": "string"}},
+		"c_tags": {"type": "array", "items": {"type": "string"}},
+		"d_value": {"oneOf": [{"type": "string"}, {"type": "integer"}, {"type": "boolean"}]},
+		"e_count": {"type": "integer"}}}`
+
+	file, err := BuildProtoFile(schema, DefaultOptions())
+	require.NoError(t, err)
+	require.NotEmpty(t, file.Messages)
+	root := file.Messages[0]
+	require.Equal(t, "Root", root.Name)
+
+	var names []string
+	var numbers []int
+	for _, f := range root.Fields {
+		names = append(names, f.Name)
+		numbers = append(numbers, f.Number)
+	}
+	assert.Equal(t, []string{"a_name", "b_labels", "c_tags", "d_value_string", "d_value_int32", "d_value_bool", "e_count"}, names)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7}, numbers)
+	assert.Empty(t, Validate(RenderProto(file)))
+}
+
+func TestFieldNumberAllocator(t *testing.T) {
+	numbers := newFieldNumberAllocator(1)
+	numbers.next = reservedFieldNumberStart - 1
+	assert.Equal(t, reservedFieldNumberStart-1, numbers.allocate())
+	assert.Equal(t, reservedFieldNumberEnd+1, numbers.allocate())
+}
+
+func TestFieldNumberStep(t *testing.T) {
+	schema := `{"type": "object", "properties": {
+		"a": {"type": "string"},
+		"b": {"type": "string"},
+		"c": {"type": "string"}}}`
+
+	opts := DefaultOptions()
+	opts.FieldNumberStep = 10
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  string a = 1;\n  string b = 11;\n  string c = 21;\n")
+	assert.Empty(t, Validate(got))
+
+	// Explicit numbers override the stepping, and the other fields are
+	// numbered around them
+	got, err = ConvertJSONSchemaToProto(`{"type": "object", "properties": {
+		"a": {"type": "string"},
+		"b": {"type": "string", "x-proto-field-number": 5},
+		"c": {"type": "string", "x-proto-field-number": 11},
+		"d": {"type": "string"}}}`, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  string a = 1;\n  string b = 5;\n  string c = 11;\n  string d = 12;\n")
+	assert.Empty(t, Validate(got))
+
+	numbers := newFieldNumberAllocator(10)
+	numbers.next = reservedFieldNumberStart - 5
+	assert.Equal(t, reservedFieldNumberStart-5, numbers.allocate())
+	assert.Equal(t, reservedFieldNumberEnd+1, numbers.allocate())
+	assert.Equal(t, reservedFieldNumberEnd+11, numbers.allocate())
+
+	numbers.next = maxFieldNumber
+	assert.Equal(t, maxFieldNumber, numbers.allocate())
+	assert.NoError(t, numbers.err)
+	numbers.allocate()
+	assert.Error(t, numbers.err)
+}
+
+func TestExplicitFieldNumberErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		prop string
+		want string
+	}{
+		{"reserved", `{"type": "string", "x-proto-field-number": 19500}`, "x-proto-field-number 19500 is in the range 19000-19999 reserved by protobuf"},
+		{"not an integer", `{"type": "string", "x-proto-field-number": 1.5}`, "x-proto-field-number must be an integer from 1 to 536870911, not 1.5"},
+		{"zero", `{"type": "string", "x-proto-field-number": 0}`, "x-proto-field-number must be an integer from 1 to 536870911, not 0"},
+		{"duplicate", `{"type": "string", "x-proto-field-number": 1}`, "x-proto-field-number 1 is already used by a"},
+		{"oneOf", `{"oneOf": [{"type": "string"}, {"type": "integer"}], "x-proto-field-number": 3}`, "x-proto-field-number can't number the fields of a oneOf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := `{"type": "object", "properties": {"a": {"type": "string", "x-proto-field-number": 1}, "b": ` + tt.prop + `}}`
+			_, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// oneofMember is a single alternative of a oneOf property. suffix
+// distinguishes the member's field name within the oneof group.
+type oneofMember struct {
+	suffix string
+	typ    fieldType
+}
+
+// processOneOf converts a oneOf property. A {"type": "null"} alternative makes
+// the property nullable; if a single alternative remains it is used directly,
+// otherwise each alternative becomes a member of a proto oneof. Array and map
+// alternatives are wrapped in a message, since oneof members can't be
+// repeated or maps.
+func (c *conversion) processOneOf(name string, members []interface{}, path string) (fieldType, error) {
+	var alternatives []interface{}
+	var paths []string
+	for i, member := range members {
+		if m, ok := member.(map[string]interface{}); ok && m["type"] == "null" {
+			continue
+		}
+		alternatives = append(alternatives, member)
+		paths = append(paths, pointerJoin(path, strconv.Itoa(i)))
+	}
+
+	nullable := len(alternatives) < len(members)
+	switch len(alternatives) {
+	case 0:
+		c.warnf(path, WarnEmptyOneOf, "oneOf for %s has no non-null alternatives; using google.protobuf.Any", name)
+		return fieldType{name: "google.protobuf.Any"}, nil
+	case 1:
+		ft, err := c.processPropertyCollect(name, alternatives[0], paths[0])
+		if err != nil {
+			return fieldType{}, err
+		}
+		if nullable && ft.canBeOptional() {
+			ft.optional = true
+		}
+		return ft, nil
+	}
+
+	used := make(map[string]bool)
+	result := make([]oneofMember, 0, len(alternatives))
+	for i, alt := range alternatives {
+		// Inline alternatives are named after their position, since they
+		// have no name of their own
+		optionName := fmt.Sprintf("%sOption%d", name, i+1)
+		ft, err := c.processPropertyCollect(optionName, alt, paths[i])
+		if err != nil {
+			return fieldType{}, err
+		}
+		ft = c.singleValue(name, optionName, ft, paths[i])
+		if ft.name == "" {
+			continue
+		}
+		if ft.repeated || strings.HasPrefix(ft.name, "map<") {
+			// oneof members can't be repeated or maps, so wrap them in a
+			// message; rules for the repeated field no longer apply
+			ft = fieldType{name: c.wrapNested(ft), notes: ft.notes}
+		}
+		base := oneofSuffix(ft.name)
+		if c.messages[ft.name] != nil && c.inlineSources[ft.name] == paths[i] {
+			base = fmt.Sprintf("option%d", i+1)
+		}
+		suffix := base
+		for n := 2; used[suffix]; n++ {
+			suffix = fmt.Sprintf("%s%d", base, n)
+		}
+		used[suffix] = true
+		result = append(result, oneofMember{suffix: suffix, typ: ft})
+	}
+	return fieldType{oneof: result}, nil
+}
+
+// singleValue replaces a oneOf type, which can only appear directly as a
+// message field, with a single type where one is needed, such as array items
+// and map values. With WrapNestedOneOf the oneof is moved into a message
+// named after typeName; otherwise google.protobuf.Any is used.
+func (c *conversion) singleValue(name, typeName string, ft fieldType, path string) fieldType {
+	if len(ft.oneof) == 0 {
+		return ft
+	}
+	if c.opts.WrapNestedOneOf {
+		return fieldType{name: c.oneofWrapper(typeName, ft.oneof, path)}
+	}
+	c.warnf(path, WarnNestedOneOf, "oneOf for %s can't be nested here; using google.protobuf.Any", name)
+	return fieldType{name: "google.protobuf.Any"}
+}
+
+// oneofWrapper returns the name of a message holding members as a oneof
+// named value, e.g. message TagsItem { oneof value { string value_string = 1;
+// int32 value_int32 = 2; } }
+func (c *conversion) oneofWrapper(typeName string, members []oneofMember, path string) string {
+	messageName := c.inlineTypeName(c.messageName(typeName), path)
+	if _, exists := c.messages[messageName]; !exists {
+		c.messages[messageName] = &Message{
+			Name:   messageName,
+			Fields: oneofFields("value", members, "", newFieldNumberAllocator(c.opts.FieldNumberStep)),
+			Source: path,
+		}
+	}
+	return messageName
+}
+
+// oneofFields returns the fields of a oneof group, numbered from the
+// allocator of the message holding it. The group's comment goes on its first
+// member.
+func oneofFields(group string, members []oneofMember, comment string, numbers *fieldNumberAllocator) []*Field {
+	fields := make([]*Field, 0, len(members))
+	for i, member := range members {
+		field := &Field{
+			Name:     group + "_" + member.suffix,
+			Type:     member.typ.name,
+			Number:   numbers.allocate(),
+			Repeated: member.typ.repeated,
+			Oneof:    group,
+			Comment:  appendComment("", member.typ.notes...),
+			Options:  member.typ.options,
+		}
+		if i == 0 {
+			field.Comment = appendComment(comment, member.typ.notes...)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// oneofSuffix derives a oneof member's field name suffix from its type, e.g.
+// google.protobuf.Timestamp becomes timestamp
+func oneofSuffix(typeName string) string {
+	if i := strings.LastIndex(typeName, "."); i >= 0 {
+		typeName = typeName[i+1:]
+	}
+	return strings.ToLower(toEnumValueName(typeName))
+}
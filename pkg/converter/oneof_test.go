@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOneOfProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		prop     string
+		want     []string
+		warnings string
+	}{
+		{
+			name: "alternatives become a oneof",
+			prop: `{"oneOf": [{"$ref": "#/definitions/Text"}, {"type": "string"}, {"type": "integer"}]}`,
+			want: []string{"  oneof content {\n    Text content_text = 1;\n    string content_string = 2;\n    int32 content_int32 = 3;\n  }\n  string title = 4;"},
+		},
+		{
+			name: "inline alternatives are named by position",
+			prop: `{"oneOf": [{"type": "object", "properties": {"url": {"type": "string"}}}, {"type": "string"}]}`,
+			want: []string{
+				"    ContentOption1 content_option1 = 1;",
+				"message ContentOption1 {\n  string url = 1;\n}",
+			},
+		},
+		{
+			name: "repeated member types get distinct names",
+			prop: `{"oneOf": [{"type": "string"}, {"type": "string", "format": "email"}]}`,
+			want: []string{"    string content_string = 1;\n    string content_string2 = 2;"},
+		},
+		{
+			name: "null alternative makes a single type optional",
+			prop: `{"oneOf": [{"type": "null"}, {"$ref": "#/definitions/Text"}]}`,
+			want: []string{"  optional Text content = 1;"},
+		},
+		{
+			name: "description goes on the first member",
+			prop: `{"description": "The body", "oneOf": [{"type": "string"}, {"type": "boolean"}]}`,
+			want: []string{"  oneof content {\n    // The body\n    string content_string = 1;"},
+		},
+		{
+			name: "array members are wrapped",
+			prop: `{"oneOf": [{"type": "array", "items": {"type": "string"}, "minItems": 1}, {"type": "string"}]}`,
+			want: []string{
+				"  oneof content {\n    StringList content_string_list = 1;\n    string content_string = 2;\n  }",
+				"message StringList {\n  repeated string values = 1;\n}",
+			},
+		},
+		{
+			name: "map members are wrapped",
+			prop: `{"oneOf": [{"type": "object", "additionalProperties": {"type": "integer"}}, {"type": "string"}]}`,
+			want: []string{
+				"    Int32Map content_int32_map = 1;",
+				"message Int32Map {\n  map<string, int32> values = 1;\n}",
+			},
+		},
+		{
+			name: "allOf member keeps its array type",
+			prop: `{"oneOf": [{"allOf": [{"type": "array", "items": {"type": "integer"}}, {"maxItems": 3}]}, {"type": "boolean"}]}`,
+			want: []string{"    Int32List content_int32_list = 1;\n    bool content_bool = 2;"},
+		},
+		{
+			name:     "oneOf items fall back to Any",
+			prop:     `{"type": "array", "items": {"oneOf": [{"type": "string"}, {"type": "integer"}]}}`,
+			want:     []string{"  repeated google.protobuf.Any content = 1;"},
+			warnings: "#/properties/content/items: oneOf for content can't be nested here",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			schema := `{"type": "object", "properties": {"content": ` + tt.prop + `, "title": {"type": "string"}},
+				"definitions": {"Text": {"type": "object", "properties": {"body": {"type": "string"}}}}}`
+			got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+			assert.Empty(t, Validate(got))
+			if tt.warnings == "" {
+				assert.Empty(t, logs.String())
+			} else {
+				assert.Contains(t, logs.String(), tt.warnings)
+			}
+		})
+	}
+}
+
+func TestNullableProperties(t *testing.T) {
+	tests := []struct {
+		name string
+		prop string
+		want string
+	}{
+		{"type list with null", `{"type": ["string", "null"]}`, "  optional string value = 1;"},
+		{"openapi nullable", `{"type": "integer", "nullable": true}`, "  optional int32 value = 1;"},
+		{"nullable object", `{"type": ["object", "null"], "properties": {"a": {"type": "string"}}}`, "  optional Value value = 1;"},
+		{"nullable array stays repeated", `{"type": ["array", "null"], "items": {"type": "string"}}`, "  repeated string value = 1;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := `{"type": "object", "properties": {"value": ` + tt.prop + `}}`
+			got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+			require.NoError(t, err)
+			assert.Contains(t, got, tt.want)
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestWrapNestedOneOf(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	schema := `{"type": "object", "properties": {
+		"events": {"type": "array", "items": {"oneOf": [
+			{"$ref": "#/definitions/Click"},
+			{"type": "string"},
+			{"type": "array", "items": {"type": "integer"}}
+		]}},
+		"labels": {"type": "object", "additionalProperties": {"oneOf": [{"type": "string"}, {"type": "boolean"}]}}
+	},
+	"definitions": {"Click": {"type": "object", "properties": {"x": {"type": "integer"}}}}}`
+
+	opts := DefaultOptions()
+	opts.WrapNestedOneOf = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	for _, want := range []string{
+		"  repeated EventsItem events = 1;",
+		"  map<string, LabelsValue> labels = 2;",
+		"message EventsItem {\n  oneof value {\n    Click value_click = 1;\n    string value_string = 2;\n    Int32List value_int32_list = 3;\n  }\n}",
+		"message LabelsValue {\n  oneof value {\n    string value_string = 1;\n    bool value_bool = 2;\n  }\n}",
+	} {
+		assert.Contains(t, got, want)
+	}
+	assert.NotContains(t, got, "google.protobuf.Any")
+	assert.Empty(t, logs.String())
+	assert.Empty(t, Validate(got))
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  repeated google.protobuf.Any events = 1;")
+	assert.Contains(t, got, "  map<string, google.protobuf.Any> labels = 2;")
+	assert.Contains(t, logs.String(), "#/properties/events/items: oneOf for events can't be nested here")
+}
@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// componentsRefPrefix is the $ref prefix OpenAPI 3 uses for reusable schemas
+const componentsRefPrefix = "#/components/schemas/"
+
+// ConvertOpenAPIToProto converts the schemas of an OpenAPI 3 document
+// (components.schemas) to Protocol Buffers format. Every component schema is
+// treated as a definition; paths and operations are ignored.
+func ConvertOpenAPIToProto(specStr string, opts *Options) (string, error) {
+	file, err := BuildOpenAPIProtoFile(specStr, opts)
+	if err != nil {
+		return "", err
+	}
+	return RenderProto(file), nil
+}
+
+// BuildOpenAPIProtoFile converts the schemas of an OpenAPI 3 document into
+// the intermediate ProtoFile representation
+func BuildOpenAPIProtoFile(specStr string, opts *Options) (*ProtoFile, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	var spec map[string]interface{}
+	if err := unmarshalJSON([]byte(specStr), &spec); err != nil {
+		return nil, err
+	}
+	schema, err := openAPIToJSONSchema(spec)
+	if err != nil {
+		return nil, err
+	}
+	return buildProtoFile(context.Background(), schema, opts, nil, componentsRefPrefix)
+}
+
+// openAPIToJSONSchema lifts components.schemas into a JSON Schema document
+// with top-level definitions, rewriting component references to match
+func openAPIToJSONSchema(spec map[string]interface{}) (map[string]interface{}, error) {
+	components, _ := spec["components"].(map[string]interface{})
+	schemas, ok := components["schemas"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("OpenAPI document has no components.schemas")
+	}
+
+	return map[string]interface{}{
+		"definitions": rewriteRefs(schemas, componentsRefPrefix, definitionsRefPrefix),
+	}, nil
+}
+
+// rewriteRefs returns a copy of node with every $ref starting with from
+// rewritten to start with to
+func rewriteRefs(node interface{}, from, to string) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if ref, ok := val.(string); ok && key == "$ref" && strings.HasPrefix(ref, from) {
+				out[key] = to + strings.TrimPrefix(ref, from)
+				continue
+			}
+			out[key] = rewriteRefs(val, from, to)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = rewriteRefs(val, from, to)
+		}
+		return out
+	default:
+		return node
+	}
+}
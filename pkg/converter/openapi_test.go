@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertOpenAPIToProto(t *testing.T) {
+	spec := `{
+		"openapi": "3.0.3",
+		"info": {"title": "Pets", "version": "1.0"},
+		"paths": {"/pets": {"get": {"responses": {"200": {"description": "ok"}}}}},
+		"components": {
+			"schemas": {
+				"Pet": {
+					"type": "object",
+					"properties": {
+						"name": {"type": "string"},
+						"owner": {"$ref": "#/components/schemas/Owner"},
+						"tags": {"type": "array", "items": {"$ref": "#/components/schemas/Tag"}}
+					}
+				},
+				"Owner": {"type": "object", "properties": {"email": {"type": "string"}}},
+				"Tag": {"type": "object", "properties": {"label": {"type": "string"}}}
+			}
+		}
+	}`
+
+	got, err := ConvertOpenAPIToProto(spec, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, `syntax = "proto3";
+
+package schema;
+
+message Owner {
+  string email = 1;
+}
+
+message Pet {
+  string name = 1;
+  Owner owner = 2;
+  repeated Tag tags = 3;
+}
+
+message Tag {
+  string label = 1;
+}
+`, got)
+}
+
+func TestConvertOpenAPIToProtoErrors(t *testing.T) {
+	_, err := ConvertOpenAPIToProto(`{"openapi": "3.0.0", "paths": {}}`, nil)
+	assert.EqualError(t, err, "OpenAPI document has no components.schemas")
+
+	_, err = ConvertOpenAPIToProto(`{"components": {"schemas": {"A": {"type": "object", "properties": {"b": {"$ref": "#/components/schemas/B"}}}}}}`, nil)
+	assert.ErrorContains(t, err, `unresolved $ref "#/definitions/B"`)
+
+	_, err = ConvertOpenAPIToProto(`not json`, nil)
+	assert.Error(t, err)
+}
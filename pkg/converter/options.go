@@ -0,0 +1,60 @@
+package converter
+
+import "reflect"
+
+// defaultTypeMappings maps each JSON Schema type onto its proto type. Types
+// missing from Options.TypeMappings fall back to these.
+var defaultTypeMappings = map[string]string{
+	"string":  "string",
+	"integer": "int32",
+	"number":  "double",
+	"boolean": "bool",
+	"array":   "repeated",
+	"object":  "message",
+}
+
+// MergeOptions returns a new Options combining base with override. Maps such
+// as TypeMappings are merged key by key and slices such as FileOptions and
+// Imports are concatenated, base first; every other field takes the value
+// from override unless it is the zero value there. A boolean can therefore
+// be turned on but not off by override. Either argument may be nil, and
+// neither is modified.
+func MergeOptions(base, override *Options) *Options {
+	merged := &Options{}
+	if base != nil {
+		*merged = *base
+	}
+	if override == nil {
+		override = &Options{}
+	}
+
+	dst := reflect.ValueOf(merged).Elem()
+	src := reflect.ValueOf(override).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		d, s := dst.Field(i), src.Field(i)
+		switch d.Kind() {
+		case reflect.Map:
+			if d.IsNil() && s.IsNil() {
+				continue
+			}
+			m := reflect.MakeMapWithSize(d.Type(), d.Len()+s.Len())
+			for _, from := range []reflect.Value{d, s} {
+				iter := from.MapRange()
+				for iter.Next() {
+					m.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			d.Set(m)
+		case reflect.Slice:
+			if s.Len() > 0 {
+				joined := reflect.MakeSlice(d.Type(), 0, d.Len()+s.Len())
+				d.Set(reflect.AppendSlice(reflect.AppendSlice(joined, d), s))
+			}
+		default:
+			if !s.IsZero() {
+				d.Set(s)
+			}
+		}
+	}
+	return merged
+}
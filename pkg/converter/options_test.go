@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeOptions(t *testing.T) {
+	base := DefaultOptions()
+	base.FileOptions = []FileOption{{Name: "java_package", Value: "com.example"}}
+	base.EmitExamples = true
+	override := &Options{
+		PackageName:  "orders",
+		TypeMappings: map[string]string{"integer": "int64"},
+		FileOptions:  []FileOption{{Name: "optimize_for", Value: "SPEED"}},
+		Imports:      []string{"common.proto"},
+	}
+
+	merged := MergeOptions(base, override)
+	assert.Equal(t, "orders", merged.PackageName)
+	assert.Equal(t, map[string]string{
+		"string":  "string",
+		"integer": "int64",
+		"number":  "double",
+		"boolean": "bool",
+		"array":   "repeated",
+		"object":  "message",
+	}, merged.TypeMappings)
+	assert.Equal(t, []FileOption{{Name: "java_package", Value: "com.example"}, {Name: "optimize_for", Value: "SPEED"}}, merged.FileOptions)
+	assert.Equal(t, []string{"common.proto"}, merged.Imports)
+	assert.True(t, merged.EmitExamples)
+
+	// Neither input is modified
+	assert.Equal(t, "int32", base.TypeMappings["integer"])
+	assert.Equal(t, "schema", base.PackageName)
+	assert.Len(t, base.FileOptions, 1)
+	assert.Len(t, override.TypeMappings, 1)
+
+	assert.Equal(t, DefaultOptions(), MergeOptions(DefaultOptions(), nil))
+	assert.Equal(t, &Options{PackageName: "x"}, MergeOptions(nil, &Options{PackageName: "x"}))
+}
+
+func TestPartialTypeMappings(t *testing.T) {
+	opts := &Options{TypeMappings: map[string]string{"boolean": "BOOL"}}
+	for jsonType, want := range map[string]string{
+		"boolean": "BOOL",
+		"integer": "int32",
+		"number":  "double",
+		"string":  "string",
+		"unknown": "string",
+	} {
+		assert.Equal(t, want, GetProtoType(jsonType, "", opts), jsonType)
+	}
+	assert.Equal(t, "int32", GetProtoType("integer", "", &Options{}))
+}
@@ -0,0 +1,73 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// presets maps the names accepted by Preset to the functions building them
+var presets = map[string]func() *Options{
+	"buf":     PresetBuf,
+	"grpc":    PresetGRPC,
+	"openapi": PresetOpenAPI,
+}
+
+// PresetBuf returns options for output that passes buf lint's STANDARD
+// rules: snake_case field names, PascalCase message names and enums whose
+// zero value is <ENUM>_UNSPECIFIED. Formats such as date-time map to
+// well-known types.
+func PresetBuf() *Options {
+	opts := DefaultOptions()
+	opts.SnakeCaseFieldNames = true
+	opts.PascalCaseDefinitions = true
+	opts.UnspecifiedEnumZero = true
+	opts.UseWellKnownTypes = true
+	return opts
+}
+
+// PresetGRPC returns PresetBuf's options plus a gRPC service pairing each
+// <Name>Request message with its <Name>Response. Fields whose name was
+// snake_cased get a json_name option so JSON transcoding keeps the original
+// property names.
+func PresetGRPC() *Options {
+	opts := PresetBuf()
+	opts.GenerateService = true
+	opts.EmitJsonNameOption = true
+	return opts
+}
+
+// PresetOpenAPI returns options suited to the component schemas of an
+// OpenAPI document, which tend to repeat the same inline shapes and carry
+// constraints and defaults meant for API documentation: identical inline
+// messages are merged, constraints and defaults become comments, and
+// formats and content media types map to well-known types and bytes.
+func PresetOpenAPI() *Options {
+	opts := DefaultOptions()
+	opts.UseWellKnownTypes = true
+	opts.UseContentMediaTypes = true
+	opts.DedupeMessages = true
+	opts.EmitConstraintComments = true
+	opts.EmitDefaultComments = true
+	return opts
+}
+
+// Preset returns the options of the named preset: buf, grpc or openapi.
+// Fields of the result can be changed freely before converting.
+func Preset(name string) (*Options, error) {
+	preset, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (want one of %s)", name, strings.Join(PresetNames(), ", "))
+	}
+	return preset(), nil
+}
+
+// PresetNames returns the names accepted by Preset, sorted
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
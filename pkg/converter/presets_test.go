@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresets(t *testing.T) {
+	buf := PresetBuf()
+	assert.Equal(t, "schema", buf.PackageName)
+	assert.Equal(t, "int32", buf.TypeMappings["integer"])
+	assert.True(t, buf.SnakeCaseFieldNames)
+	assert.True(t, buf.PascalCaseDefinitions)
+	assert.True(t, buf.UnspecifiedEnumZero)
+	assert.True(t, buf.UseWellKnownTypes)
+	assert.False(t, buf.GenerateService)
+
+	grpc := PresetGRPC()
+	assert.True(t, grpc.SnakeCaseFieldNames)
+	assert.True(t, grpc.UseWellKnownTypes)
+	assert.True(t, grpc.GenerateService)
+	assert.True(t, grpc.EmitJsonNameOption)
+
+	openAPI := PresetOpenAPI()
+	assert.True(t, openAPI.UseWellKnownTypes)
+	assert.True(t, openAPI.UseContentMediaTypes)
+	assert.True(t, openAPI.DedupeMessages)
+	assert.True(t, openAPI.EmitConstraintComments)
+	assert.False(t, openAPI.SnakeCaseFieldNames)
+
+	// Each call returns fresh options that can be changed independently
+	buf.TypeMappings["integer"] = "int64"
+	buf.UseWellKnownTypes = false
+	assert.Equal(t, "int32", PresetBuf().TypeMappings["integer"])
+	assert.True(t, PresetBuf().UseWellKnownTypes)
+
+	schema := `{"type": "object", "properties": {"createdAt": {"type": "string", "format": "date-time"}}, "definitions": {
+		"getOrderRequest": {"type": "object", "properties": {"orderId": {"type": "string"}}},
+		"getOrderResponse": {"type": "object", "properties": {"status": {"enum": ["open"]}}}
+	}}`
+	got, err := ConvertJSONSchemaToProto(schema, PresetGRPC())
+	require.NoError(t, err)
+	assert.Contains(t, got, `google.protobuf.Timestamp created_at = 1 [json_name = "createdAt"];`)
+	assert.Contains(t, got, "message GetOrderRequest {")
+	assert.Contains(t, got, "rpc GetOrder(GetOrderRequest) returns (GetOrderResponse);")
+	assert.Empty(t, Validate(got))
+}
+
+func TestPresetByName(t *testing.T) {
+	assert.Equal(t, []string{"buf", "grpc", "openapi"}, PresetNames())
+	for _, name := range PresetNames() {
+		opts, err := Preset(name)
+		require.NoError(t, err)
+		assert.True(t, opts.UseWellKnownTypes, name)
+	}
+
+	_, err := Preset("protoc")
+	assert.EqualError(t, err, `unknown preset "protoc" (want one of buf, grpc, openapi)`)
+}
@@ -0,0 +1,78 @@
+//go:build protoc
+
+package converter
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestProtocRoundTrip converts every schema in testdata and testdata/protoc
+// and checks that protoc accepts the result, in both proto3 and proto2 syntax
+// and as split per-definition files. Run it with
+//
+//	go test -tags protoc -run TestProtocRoundTrip ./pkg/converter
+//
+// It is skipped when protoc is not on PATH.
+func TestProtocRoundTrip(t *testing.T) {
+	protoc, err := exec.LookPath("protoc")
+	if err != nil {
+		t.Skip("protoc not found on PATH")
+	}
+	log.SetOutput(&bytes.Buffer{})
+	defer log.SetOutput(os.Stderr)
+
+	corpus, err := filepath.Glob(filepath.Join("testdata", "protoc", "*.json"))
+	require.NoError(t, err)
+	corpus = append(corpus, filepath.Join("testdata", "complex.json"), filepath.Join("testdata", "collisions.json"))
+
+	for _, path := range corpus {
+		schema, err := os.ReadFile(path)
+		require.NoError(t, err)
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+
+		for _, syntax := range []string{"proto3", "proto2"} {
+			opts := DefaultOptions()
+			opts.Syntax = syntax
+			opts.UseWellKnownTypes = true
+
+			t.Run(name+"/"+syntax, func(t *testing.T) {
+				got, err := ConvertJSONSchemaToProto(string(schema), opts)
+				require.NoError(t, err)
+				runProtoc(t, protoc, map[string]string{name + ".proto": got})
+			})
+			t.Run(name+"/"+syntax+"/split", func(t *testing.T) {
+				files, err := ConvertToFiles(string(schema), opts)
+				require.NoError(t, err)
+				runProtoc(t, protoc, files)
+			})
+		}
+	}
+}
+
+// runProtoc writes files into a temporary directory and compiles them
+// together with protoc, failing the test with protoc's output on error
+func runProtoc(t *testing.T, protoc string, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	args := []string{"--proto_path", dir, "-o", os.DevNull}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+		args = append(args, filepath.Join(dir, name))
+	}
+	out, err := exec.Command(protoc, args...).CombinedOutput()
+	if err != nil {
+		var sources strings.Builder
+		for name, content := range files {
+			sources.WriteString("// " + name + "\n" + content + "\n")
+		}
+		t.Fatalf("protoc failed: %v\n%s\n%s", err, out, sources.String())
+	}
+}
@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// protoOptionsKeyword is the property extension whose entries are emitted as
+// field options, e.g. {"x-proto-options": {"(gogoproto.nullable)": false}}
+const protoOptionsKeyword = "x-proto-options"
+
+// optionName matches a field option name: a simple identifier or a
+// parenthesized extension name, optionally followed by .field selectors
+var optionName = regexp.MustCompile(`^(?:[A-Za-z_][A-Za-z0-9_]*|\([A-Za-z_.][A-Za-z0-9_.]*\))(?:\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// applyProtoOptions adds the field options listed in a property's
+// x-proto-options map to ft, sorted by name. An option with the same name as
+// one the converter generated, such as deprecated, replaces it. Names must be
+// valid option names and values booleans, numbers, strings or objects, which
+// become aggregate {...} values; strings are quoted unless they are enum
+// constants.
+func applyProtoOptions(ft *fieldType, propMap map[string]interface{}, path string) error {
+	options, err := extensionOptions(propMap, protoOptionsKeyword, path)
+	if err != nil {
+		return err
+	}
+	for _, opt := range options {
+		ft.options = setFieldOption(ft.options, opt)
+	}
+	return nil
+}
+
+// extensionOptions parses the options listed in the keyword extension of
+// schema, sorted by name
+func extensionOptions(schema map[string]interface{}, keyword, path string) ([]FieldOption, error) {
+	raw, ok := schema[keyword]
+	if !ok {
+		return nil, nil
+	}
+	optsPath := pointerJoin(path, keyword)
+	options, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, &PathError{Path: optsPath, Err: fmt.Errorf("%s must be an object", keyword)}
+	}
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]FieldOption, 0, len(names))
+	for _, name := range names {
+		if !optionName.MatchString(name) {
+			return nil, &PathError{Path: pointerJoin(optsPath, name), Err: fmt.Errorf("invalid option name %q", name)}
+		}
+		value, err := optionValue(options[name])
+		if err != nil {
+			return nil, &PathError{Path: pointerJoin(optsPath, name), Err: err}
+		}
+		out = append(out, FieldOption{Name: name, Value: value})
+	}
+	return out, nil
+}
+
+// optionValue formats a JSON value as a proto option value
+func optionValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	case float64:
+		return formatNumber(v), nil
+	case string:
+		return formatOptionValue(v), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			if !protoIdentifier.MatchString(k) {
+				return "", fmt.Errorf("invalid aggregate field name %q", k)
+			}
+			value, err := optionValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			parts[i] = k + ": " + value
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	default:
+		return "", fmt.Errorf("unsupported option value %s", compactJSON(v))
+	}
+}
+
+// setFieldOption returns opts with opt added, replacing an option of the same
+// name. opts itself is left unchanged since it may be shared with a cached
+// type.
+func setFieldOption(opts []FieldOption, opt FieldOption) []FieldOption {
+	out := make([]FieldOption, 0, len(opts)+1)
+	replaced := false
+	for _, o := range opts {
+		if o.Name == opt.Name {
+			o, replaced = opt, true
+		}
+		out = append(out, o)
+	}
+	if !replaced {
+		out = append(out, opt)
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtoOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		prop string
+		want string
+	}{
+		{
+			name: "single option",
+			prop: `{"type": "string", "x-proto-options": {"(gogoproto.nullable)": false}}`,
+			want: "  string value = 1 [(gogoproto.nullable) = false];",
+		},
+		{
+			name: "options are sorted and joined",
+			prop: `{"type": "integer", "x-proto-options": {"(gogoproto.moretags)": "yaml:\"v\"", "(a.b).c": 3, "ctype": "CORD"}}`,
+			want: `  int32 value = 1 [(a.b).c = 3, (gogoproto.moretags) = "yaml:\"v\"", ctype = CORD];`,
+		},
+		{
+			name: "aggregate value",
+			prop: `{"type": "string", "x-proto-options": {"(my.rules)": {"max": 2, "name": "x", "strict": true}}}`,
+			want: `  string value = 1 [(my.rules) = {max: 2, name: "x", strict: true}];`,
+		},
+		{
+			name: "follows generated options",
+			prop: `{"type": "string", "minLength": 1, "x-proto-options": {"(gogoproto.nullable)": false}}`,
+			want: "  string value = 1 [(validate.rules).string = {min_len: 1}, (gogoproto.nullable) = false];",
+		},
+		{
+			name: "replaces a generated option of the same name",
+			prop: `{"type": "string", "deprecated": true, "x-proto-options": {"deprecated": false}}`,
+			want: "  string value = 1 [deprecated = false];",
+		},
+		{
+			name: "array items",
+			prop: `{"type": "array", "items": {"type": "string"}, "x-proto-options": {"packed": false}}`,
+			want: "  repeated string value = 1 [packed = false];",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.EmitValidateOptions = true
+			got, err := ConvertJSONSchemaToProto(`{"type": "object", "properties": {"value": `+tt.prop+`}}`, opts)
+			require.NoError(t, err)
+			assert.Contains(t, got, tt.want)
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestProtoOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		prop string
+		path string
+		want string
+	}{
+		{
+			name: "not an object",
+			prop: `{"type": "string", "x-proto-options": ["deprecated"]}`,
+			path: "#/properties/value/x-proto-options",
+			want: "x-proto-options must be an object",
+		},
+		{
+			name: "unbalanced parentheses",
+			prop: `{"type": "string", "x-proto-options": {"(gogoproto.nullable": false}}`,
+			path: "#/properties/value/x-proto-options/(gogoproto.nullable",
+			want: `invalid option name "(gogoproto.nullable"`,
+		},
+		{
+			name: "injected brackets",
+			prop: `{"type": "string", "x-proto-options": {"a] [b": true}}`,
+			path: "#/properties/value/x-proto-options/a] [b",
+			want: `invalid option name "a] [b"`,
+		},
+		{
+			name: "array value",
+			prop: `{"type": "string", "x-proto-options": {"(my.list)": [1, 2]}}`,
+			path: "#/properties/value/x-proto-options/(my.list)",
+			want: "unsupported option value [1,2]",
+		},
+		{
+			name: "invalid aggregate field",
+			prop: `{"type": "string", "x-proto-options": {"(my.rules)": {"a b": 1}}}`,
+			path: "#/properties/value/x-proto-options/(my.rules)",
+			want: `invalid aggregate field name "a b"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ConvertJSONSchemaToProto(`{"type": "object", "properties": {"value": `+tt.prop+`}}`, DefaultOptions())
+			var convErr *ConversionError
+			require.ErrorAs(t, err, &convErr)
+			assert.Equal(t, tt.path, convErr.Errors[0].Path)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+)
+
+// recordAccess notes whether field belongs to a readOnly or writeOnly
+// property
+func (c *conversion) recordAccess(field *Field, propMap map[string]interface{}) {
+	for _, keyword := range []string{"readOnly", "writeOnly"} {
+		if set, _ := propMap[keyword].(bool); set {
+			c.access[field] = keyword
+		}
+	}
+}
+
+// splitReadWrite replaces each message affected by readOnly or writeOnly
+// fields with its Request and Response forms, as described for
+// Options.SplitReadWrite
+func (c *conversion) splitReadWrite() error {
+	split := make(map[string]bool)
+	for name, msg := range c.messages {
+		for _, field := range msg.Fields {
+			if c.access[field] != "" {
+				split[name] = true
+			}
+		}
+	}
+	// Messages using a split message must be split too, so that each form
+	// refers to the matching form of its fields' types
+	for changed := true; changed; {
+		changed = false
+		for name, msg := range c.messages {
+			if split[name] {
+				continue
+			}
+			for _, dep := range messageDependencies(msg) {
+				if split[dep] {
+					split[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(split))
+	for name := range split {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, suffix := range []string{"Request", "Response"} {
+			if _, exists := c.messages[name+suffix]; exists && !split[name+suffix] {
+				return fmt.Errorf("splitting %s into request and response messages: message %s%s already exists", name, name, suffix)
+			}
+		}
+	}
+
+	forms := []struct{ suffix, omit string }{
+		{"Request", "readOnly"},
+		{"Response", "writeOnly"},
+	}
+	generated := make(map[string]*Message)
+	for _, name := range names {
+		msg := c.messages[name]
+		for _, form := range forms {
+			retarget := func(typ string) string {
+				if split[typ] {
+					return typ + form.suffix
+				}
+				return typ
+			}
+			copied := *msg
+			copied.Name = name + form.suffix
+			copied.Fields = nil
+			for _, field := range msg.Fields {
+				if c.access[field] == form.omit {
+					continue
+				}
+				fieldCopy := *field
+				fieldCopy.Type = mapFieldType(field.Type, retarget)
+				copied.Fields = append(copied.Fields, &fieldCopy)
+			}
+			generated[copied.Name] = &copied
+		}
+	}
+	for _, name := range names {
+		delete(c.messages, name)
+	}
+	for name, msg := range generated {
+		c.messages[name] = msg
+	}
+	if split[c.rootName] {
+		c.rootName += "Request"
+	}
+	return nil
+}
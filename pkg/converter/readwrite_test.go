@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitReadWrite(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"user": {"$ref": "#/definitions/User"},
+			"note": {"type": "string"}
+		},
+		"definitions": {
+			"User": {
+				"type": "object",
+				"properties": {
+					"id": {"type": "string", "readOnly": true},
+					"password": {"type": "string", "writeOnly": true},
+					"name": {"type": "string"},
+					"address": {"$ref": "#/definitions/Address"},
+					"friends": {"type": "array", "items": {"$ref": "#/definitions/User"}}
+				}
+			},
+			"Address": {"type": "object", "properties": {"city": {"type": "string"}}}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.SplitReadWrite = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, `message RootRequest {
+  string note = 1;
+  UserRequest user = 2;
+}`)
+	assert.Contains(t, got, `message RootResponse {
+  string note = 1;
+  UserResponse user = 2;
+}`)
+	assert.Contains(t, got, `message UserRequest {
+  Address address = 1;
+  repeated UserRequest friends = 2;
+  string name = 4;
+  // writeOnly
+  string password = 5;
+}`)
+	assert.Contains(t, got, `message UserResponse {
+  Address address = 1;
+  repeated UserResponse friends = 2;
+  // readOnly
+  string id = 3;
+  string name = 4;
+}`)
+	// Address has no readOnly or writeOnly fields, so it is shared
+	assert.Contains(t, got, "message Address {\n  string city = 1;\n}")
+	assert.NotContains(t, got, "message User {")
+	assert.NotContains(t, got, "message Root {")
+	assert.Empty(t, Validate(got))
+
+	// Without the option a single message holds every field
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "message User {")
+	assert.NotContains(t, got, "UserRequest")
+}
+
+func TestSplitReadWriteNameClash(t *testing.T) {
+	schema := `{"definitions": {
+		"User": {"type": "object", "properties": {"id": {"type": "string", "readOnly": true}}},
+		"UserRequest": {"type": "object", "properties": {"name": {"type": "string"}}}
+	}}`
+	opts := DefaultOptions()
+	opts.SplitReadWrite = true
+	_, err := ConvertJSONSchemaToProto(schema, opts)
+	assert.EqualError(t, err, "splitting User into request and response messages: message UserRequest already exists")
+}
@@ -0,0 +1,405 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// resolveRef returns the type referenced by a $ref found at path. A reference
+// to a whole definition (#/definitions/Name) that defines a type, per
+// definesType, uses the definition's type; any other reference is resolved
+// within the schema document, as described for refPointer, and the subschema
+// it points at is converted in place, named after the property it belongs
+// to. Each target is converted once; later references reuse the result.
+func (c *conversion) resolveRef(ref, path string) (fieldType, error) {
+	refPath := pointerJoin(path, "$ref")
+	segments, err := c.refPointer(ref, path)
+	if err != nil {
+		return fieldType{}, &PathError{Path: refPath, Err: err}
+	}
+	target, ok := lookupPointer(c.schema, segments)
+	if !ok {
+		return fieldType{}, &PathError{Path: refPath, Err: fmt.Errorf("unresolved $ref %q", ref)}
+	}
+
+	switch {
+	case len(segments) == 0:
+		if c.hasRootMessage() {
+			return fieldType{name: c.rootName}, nil
+		}
+	case len(segments) == 2 && segments[0] == "definitions" && !c.flatten() && definesType(target):
+		return fieldType{name: c.definitionName(segments[1])}, nil
+	}
+	return c.convertTarget(segments, target, ref, path)
+}
+
+// convertTarget converts the subschema target found at the reference tokens
+// segments in place, on behalf of the reference ref at path. A target that
+// leads back to itself, such as definitions aliasing each other in a cycle,
+// is an error, or with Flatten a free-form value.
+func (c *conversion) convertTarget(segments []string, target interface{}, ref, path string) (fieldType, error) {
+	refPath := pointerJoin(path, "$ref")
+	pointer := pointerJoin("#", segments...)
+	if ft, ok := c.resolved[pointer]; ok {
+		return ft, nil
+	}
+	if c.aliasCycles[pointer] {
+		// Already reported; the conversion fails either way
+		return fieldType{}, nil
+	}
+	if c.resolving[pointer] {
+		if c.flatten() {
+			ft := fieldType{name: c.freeFormType()}
+			c.warnf(path, WarnRecursiveRef, "$ref %q refers back to %s, which can't be inlined within itself; using %s", ref, pointer, ft.name)
+			ft.notes = append(ft.notes, fmt.Sprintf("Recursive $ref to %s not inlined.", pointer))
+			return ft, nil
+		}
+		return fieldType{}, &PathError{Path: refPath, Err: fmt.Errorf("circular $ref %q", ref)}
+	}
+	c.resolving[pointer] = true
+	defer delete(c.resolving, pointer)
+	ft, err := c.processPropertyCollect(refName(segments), target, pointer)
+	if err != nil {
+		return fieldType{}, err
+	}
+	c.resolved[pointer] = ft
+	return ft, nil
+}
+
+// checkAliasCycles reports, once each, the cycles of definitions that are
+// only aliases of one another, such as {"A": {"$ref": "#/definitions/B"},
+// "B": {"$ref": "#/definitions/A"}}, at the $ref of the first definition of
+// the cycle in names. Their references then have no type, rather than
+// reporting the cycle again wherever it is reached.
+func (c *conversion) checkAliasCycles(defs map[string]interface{}, names []string) error {
+	// aliasOf returns the definition name aliases, if it is an alias of one
+	aliasOf := func(name string) (string, string, bool) {
+		defMap, ok := defs[name].(map[string]interface{})
+		if !ok || definesType(defMap) {
+			return "", "", false
+		}
+		ref, ok := defMap["$ref"].(string)
+		if !ok {
+			return "", "", false
+		}
+		segments, err := c.refPointer(ref, pointerJoin("#/definitions", name))
+		if err != nil || len(segments) != 2 || segments[0] != "definitions" {
+			return "", "", false
+		}
+		if _, ok := defs[segments[1]]; !ok {
+			return "", "", false
+		}
+		return segments[1], ref, true
+	}
+
+	visited := make(map[string]bool)
+	for _, start := range names {
+		var chain []string
+		onChain := make(map[string]int)
+		name := start
+		for !visited[name] {
+			visited[name] = true
+			onChain[name] = len(chain)
+			chain = append(chain, name)
+			next, _, ok := aliasOf(name)
+			if !ok {
+				name = ""
+				break
+			}
+			name = next
+		}
+		// The chain is a cycle when it leads back to one of its own
+		// definitions, rather than to a type or a chain already followed
+		i, ok := onChain[name]
+		if !ok {
+			continue
+		}
+		cycle := append([]string(nil), chain[i:]...)
+		sort.Strings(cycle)
+		for _, member := range cycle {
+			c.aliasCycles[pointerJoin("#/definitions", member)] = true
+		}
+		first := pointerJoin("#/definitions", cycle[0])
+		_, ref, _ := aliasOf(cycle[0])
+		if err := c.addError(pointerJoin(first, "$ref"), cycle[0], fmt.Errorf("circular $ref %q", ref)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// definesType reports whether a definition generates a named type of its own,
+// a message or an enum, which references use by name. Scalar, array and map
+// definitions, and aliases of another $ref, have no type to name and are
+// converted in place wherever they are referenced.
+func definesType(def interface{}) bool {
+	defMap, ok := def.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := stringEnumValues(defMap); ok {
+		return true
+	}
+	if _, ok := integerEnumValues(defMap); ok {
+		return true
+	}
+	if _, ok := defMap["properties"]; ok {
+		return true
+	}
+	if _, ok := defMap["allOf"]; ok {
+		return true
+	}
+	if _, ok := defMap["$ref"]; ok {
+		return false
+	}
+	if isResourceContainer(defMap) {
+		return false
+	}
+	switch defType, _ := schemaType(defMap); defType {
+	case "array", "string", "integer", "number", "boolean":
+		return false
+	case "object":
+		return !isMapObject(defMap)
+	}
+	return true
+}
+
+// schemaKeywords are the keywords that make a subschema describe values,
+// as opposed to only holding other schemas
+var schemaKeywords = []string{
+	"type", "properties", "additionalProperties", "patternProperties", "items",
+	"allOf", "anyOf", "oneOf", "not", "if", "enum", "const", "$ref",
+}
+
+// isResourceContainer reports whether a definition only bundles other
+// schemas, such as {"$id": "https://example.com/common", "definitions":
+// {...}}: it has an $id or definitions of its own but describes no values.
+// Nothing is generated for it; its definitions are converted when referenced.
+func isResourceContainer(def map[string]interface{}) bool {
+	_, hasID := def["$id"]
+	_, hasDefs := def["definitions"]
+	_, hasNewDefs := def["$defs"]
+	if !hasID && !hasDefs && !hasNewDefs {
+		return false
+	}
+	for _, k := range schemaKeywords {
+		if _, ok := def[k]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupRef returns the subschema a local $ref found at path points at,
+// together with its canonical JSON pointer
+func (c *conversion) lookupRef(ref, path string) (interface{}, string, error) {
+	refPath := pointerJoin(path, "$ref")
+	segments, err := c.refPointer(ref, path)
+	if err != nil {
+		return nil, "", &PathError{Path: refPath, Err: err}
+	}
+	target, ok := lookupPointer(c.schema, segments)
+	if !ok {
+		return nil, "", &PathError{Path: refPath, Err: fmt.Errorf("unresolved $ref %q", ref)}
+	}
+	return target, pointerJoin("#", segments...), nil
+}
+
+// refPointer returns the reference tokens, from the document root, of the
+// subschema a $ref found at path points at. As in a 2020-12 compound schema
+// document, subschemas with an $id (or draft-04 id) are schema resources of
+// their own: the $ref is resolved against the URI of the innermost resource
+// containing it, and its URI part, if any, must name the document or an
+// embedded resource, within which the fragment pointer is followed. So in
+// {"$defs": {"a": {"$id": "https://example.com/a", ...}}}, the reference
+// https://example.com/a#/properties/x, or a#/properties/x from a sibling
+// resource, points at #/definitions/a/properties/x.
+func (c *conversion) refPointer(ref, path string) ([]string, error) {
+	base, resource := c.resourceAt(path)
+	uri, fragment := ref, "#"
+	if i := strings.Index(ref, "#"); i >= 0 {
+		uri, fragment = ref[:i], ref[i:]
+	}
+	if uri != "" {
+		abs, err := resolveURI(base, uri)
+		if err != nil {
+			return nil, fmt.Errorf("invalid $ref %q: %v", ref, err)
+		}
+		var ok bool
+		if resource, ok = c.resources[abs]; !ok {
+			return nil, fmt.Errorf("unresolved $ref %q: no schema with $id %q in the document", ref, abs)
+		}
+	}
+	tokens, err := parsePointer(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	tokens = append(append([]string(nil), resource...), tokens...)
+	if c.defsHoisted && len(tokens) >= 2 && tokens[0] == "$defs" {
+		tokens[0] = "definitions"
+	}
+	return tokens, nil
+}
+
+// resourceAt returns the URI and the reference tokens of the innermost schema
+// resource containing the subschema at path; the document root has no URI
+// unless it declares an $id
+func (c *conversion) resourceAt(path string) (string, []string) {
+	pointer, uri := "#", ""
+	for p, u := range c.resourceURIs {
+		if len(p) > len(pointer) && (path == p || strings.HasPrefix(path, p+"/")) {
+			pointer, uri = p, u
+		}
+	}
+	if pointer == "#" {
+		uri = c.resourceURIs["#"]
+	}
+	tokens, _ := parsePointer(pointer)
+	return uri, tokens
+}
+
+// indexResources records the URI of every subschema of the document with an
+// $id, resolved against the resource containing it, so references can find
+// them. The first of several resources with the same URI wins.
+func (c *conversion) indexResources() {
+	c.resources = make(map[string][]string)
+	c.resourceURIs = make(map[string]string)
+	c.indexResource(c.schema, nil, "")
+}
+
+func (c *conversion) indexResource(node interface{}, tokens []string, base string) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if id, ok := c.schemaIDOf(v); ok {
+			if abs, err := resolveURI(base, id); err == nil && abs != "" {
+				if _, taken := c.resources[abs]; !taken {
+					c.resources[abs] = tokens
+				}
+				c.resourceURIs[pointerJoin("#", tokens...)] = abs
+				base = abs
+			}
+		}
+		for key, child := range v {
+			switch key {
+			case "enum", "const", "default", "examples":
+				// Instance values, not subschemas
+				continue
+			}
+			c.indexResource(child, append(tokens[:len(tokens):len(tokens)], key), base)
+		}
+	case []interface{}:
+		for i, child := range v {
+			c.indexResource(child, append(tokens[:len(tokens):len(tokens)], strconv.Itoa(i)), base)
+		}
+	}
+}
+
+// schemaIDOf returns the $id of a subschema, or its id when the draft allows
+// the draft-04 spelling
+func (c *conversion) schemaIDOf(schema map[string]interface{}) (string, bool) {
+	if id, ok := schema["$id"].(string); ok && !c.draft.before(Draft6) {
+		return id, true
+	}
+	if id, ok := schema["id"].(string); ok && (c.draft == "" || c.draft == Draft4) {
+		return id, true
+	}
+	return "", false
+}
+
+// resolveURI resolves uri against base, when there is one, and drops its
+// fragment
+func resolveURI(base, uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if base != "" {
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		u = b.ResolveReference(u)
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String(), nil
+}
+
+// parsePointer splits a JSON pointer fragment such as #/definitions/a~1b into
+// its unescaped reference tokens
+func parsePointer(ref string) ([]string, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	fragment, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %v", ref, err)
+	}
+	if fragment == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	tokens := strings.Split(fragment[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+	return tokens, nil
+}
+
+// lookupPointer walks node along the reference tokens of a JSON pointer
+func lookupPointer(node interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			node = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			node = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// refName derives the name a referenced subschema would get had it been
+// converted where it is defined, so both uses share the generated type. For
+// example #/definitions/Order/properties/lines/items yields linesItem.
+func refName(tokens []string) string {
+	name := ""
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "properties", "definitions", "$defs", "schemas":
+			if i+1 < len(tokens) {
+				i++
+				name = tokens[i]
+			}
+		case "patternProperties":
+			i++
+			name += "Value"
+		case "additionalProperties":
+			name += "Value"
+		case "items":
+			name += "Item"
+		}
+	}
+	if name == "" && len(tokens) > 0 {
+		name = tokens[len(tokens)-1]
+	}
+	if name == "" {
+		name = "root"
+	}
+	return name
+}
@@ -0,0 +1,358 @@
+package converter
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPointerRefs(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"status": {"$ref": "#/definitions/Order/properties/status"},
+			"buyer": {"$ref": "#/definitions/Order/properties/customer"},
+			"first_line": {"$ref": "#/definitions/Order/properties/lines/items"},
+			"order_id": {"$ref": "#/definitions/Order/properties/id"},
+			"ratio": {"$ref": "#/definitions/Order/properties/a~1b"},
+			"order": {"$ref": "#/definitions/Order"}
+		},
+		"definitions": {
+			"Order": {
+				"type": "object",
+				"properties": {
+					"id": {"type": "string"},
+					"a/b": {"type": "number"},
+					"status": {"type": "string", "enum": ["open", "closed"]},
+					"customer": {"type": "object", "properties": {"name": {"type": "string"}}},
+					"lines": {"type": "array", "items": {"type": "object", "properties": {"sku": {"type": "string"}}}}
+				}
+			}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	for _, want := range []string{
+		"  Customer buyer = 1;",
+		"  LinesItem first_line = 2;",
+		"  Order order = 3;",
+		"  string order_id = 4;",
+		"  double ratio = 5;",
+		"  StatusEnum status = 6;",
+		// The referenced subschemas share the types generated for Order
+		"  Customer customer = 2;",
+		"  repeated LinesItem lines = 4;",
+		"  StatusEnum status = 5;",
+	} {
+		assert.Contains(t, got, want)
+	}
+	assert.Equal(t, 1, strings.Count(got, "message Customer {"))
+	assert.Equal(t, 1, strings.Count(got, "enum StatusEnum {"))
+	assert.Empty(t, Validate(got))
+}
+
+func TestJSONPointerRefErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		path   string
+		want   string
+	}{
+		{
+			name:   "missing target",
+			schema: `{"type": "object", "properties": {"a": {"$ref": "#/definitions/Order/properties/nope"}}, "definitions": {"Order": {"type": "object", "properties": {}}}}`,
+			path:   "#/properties/a/$ref",
+			want:   `unresolved $ref "#/definitions/Order/properties/nope"`,
+		},
+		{
+			name:   "external reference",
+			schema: `{"type": "object", "properties": {"a": {"$ref": "other.json#/definitions/A"}}}`,
+			path:   "#/properties/a/$ref",
+			want:   `unresolved $ref "other.json#/definitions/A": no schema with $id "other.json" in the document`,
+		},
+		{
+			name:   "reference to itself",
+			schema: `{"type": "object", "properties": {"a": {"$ref": "#/properties/a"}}}`,
+			path:   "#/properties/a/$ref",
+			want:   `circular $ref "#/properties/a"`,
+		},
+		{
+			name:   "definitions aliasing each other",
+			schema: `{"type": "object", "properties": {"a": {"$ref": "#/definitions/A"}}, "definitions": {"A": {"$ref": "#/definitions/B"}, "B": {"$ref": "#/definitions/A"}}}`,
+			path:   "#/definitions/A/$ref",
+			want:   `(message A): circular $ref "#/definitions/B"`,
+		},
+		{
+			name:   "unreferenced alias cycle",
+			schema: `{"type": "object", "properties": {}, "definitions": {"A": {"$ref": "#/definitions/B"}, "B": {"$ref": "#/definitions/A"}}}`,
+			path:   "#/definitions/A/$ref",
+			want:   `(message A): circular $ref "#/definitions/B"`,
+		},
+		{
+			name:   "definition aliasing itself",
+			schema: `{"type": "object", "properties": {"a": {"$ref": "#/definitions/A"}, "b": {"$ref": "#/definitions/A"}}, "definitions": {"A": {"$ref": "#/definitions/A"}}}`,
+			path:   "#/definitions/A/$ref",
+			want:   `(message A): circular $ref "#/definitions/A"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ConvertJSONSchemaToProto(tt.schema, DefaultOptions())
+			var convErr *ConversionError
+			require.ErrorAs(t, err, &convErr)
+			// Each problem is reported once, however often it's reached
+			require.Len(t, convErr.Errors, 1)
+			assert.Equal(t, tt.path, convErr.Errors[0].Path)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestNonTypeDefinitions(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"id": {"$ref": "#/definitions/Id"},
+			"tags": {"$ref": "#/definitions/Tags"},
+			"labels": {"$ref": "#/definitions/Labels"},
+			"owner": {"$ref": "#/definitions/Owner"},
+			"points": {"$ref": "#/definitions/Points"}
+		},
+		"definitions": {
+			"Id": {"type": "string", "format": "uuid"},
+			"Tags": {"type": "array", "items": {"type": "string"}},
+			"Labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"Owner": {"$ref": "#/definitions/Person"},
+			"Person": {"type": "object", "properties": {"name": {"type": "string"}}},
+			"Points": {"type": "array", "items": {"type": "object", "properties": {"x": {"type": "number"}}}}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	for _, want := range []string{
+		"  string id = 1;",
+		"  map<string, string> labels = 2;",
+		// An alias uses the type of the definition it refers to
+		"  Person owner = 3;",
+		"  repeated PointsItem points = 4;",
+		"  repeated string tags = 5;",
+		"message PointsItem {\n  double x = 1;\n}",
+	} {
+		assert.Contains(t, got, want)
+	}
+	// Only object definitions generate messages
+	for _, name := range []string{"Id", "Tags", "Labels", "Owner", "Points"} {
+		assert.NotContains(t, got, "message "+name+" {")
+	}
+	assert.Empty(t, Validate(got))
+}
+
+func TestOpenAPINestedComponentRef(t *testing.T) {
+	spec := `{
+		"openapi": "3.0.0",
+		"components": {"schemas": {
+			"Pet": {"type": "object", "properties": {"kind": {"type": "string", "enum": ["cat", "dog"]}}},
+			"Owner": {"type": "object", "properties": {"pet_kind": {"$ref": "#/components/schemas/Pet/properties/kind"}}}
+		}}
+	}`
+	got, err := ConvertOpenAPIToProto(spec, DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, got, "  KindEnum pet_kind = 1;")
+	assert.Empty(t, Validate(got))
+}
+
+func TestBundledResources(t *testing.T) {
+	schema := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id": "https://example.com/schemas/bundle",
+		"type": "object",
+		"properties": {
+			"order": {"$ref": "https://example.com/schemas/order"},
+			"customer": {"$ref": "customer"},
+			"total": {"$ref": "https://example.com/schemas/common#/$defs/Money"}
+		},
+		"$defs": {
+			"common": {
+				"$id": "https://example.com/schemas/common",
+				"$defs": {"Money": {"type": "object", "properties": {"units": {"type": "integer"}}}}
+			},
+			"Order": {
+				"$id": "https://example.com/schemas/order",
+				"type": "object",
+				"properties": {
+					"id": {"type": "string"},
+					"buyer": {"$ref": "customer"},
+					"status": {"$ref": "#/$defs/status"}
+				},
+				"$defs": {"status": {"type": "string", "enum": ["open", "closed"]}}
+			},
+			"Customer": {
+				"$id": "customer",
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"last_order": {"$ref": "order#/properties/id"},
+					"orders": {"type": "array", "items": {"$ref": "https://example.com/schemas/order"}}
+				}
+			}
+		}
+	}`
+
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	for _, want := range []string{
+		"message Root {\n  Customer customer = 1;\n  Order order = 2;\n  Money total = 3;\n}",
+		"message Money {\n  int32 units = 1;\n}",
+		"message Customer {\n  string last_order = 1;\n  string name = 2;\n  repeated Order orders = 3;\n}",
+		"message Order {\n  Customer buyer = 1;\n  string id = 2;\n  StatusEnum status = 3;\n}",
+	} {
+		assert.Contains(t, got, want)
+	}
+	// The common resource only bundles schemas, so has no message
+	assert.NotContains(t, got, "message common")
+	assert.NotContains(t, got, "message Common")
+	assert.Empty(t, Validate(got))
+
+	_, err = ConvertJSONSchemaToProto(`{"$id": "https://example.com/a", "type": "object",
+		"properties": {"b": {"$ref": "b#/properties/x"}}}`, DefaultOptions())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `#/properties/b/$ref (message Root): unresolved $ref "b#/properties/x": no schema with $id "https://example.com/b" in the document`)
+}
+
+func TestRefName(t *testing.T) {
+	tests := map[string][]string{
+		"status":      {"definitions", "Order", "properties", "status"},
+		"linesItem":   {"definitions", "Order", "properties", "lines", "items"},
+		"labelsValue": {"properties", "labels", "additionalProperties"},
+		"extValue":    {"properties", "ext", "patternProperties", "^x-"},
+		"Foo":         {"components", "schemas", "Foo"},
+		"0":           {"allOf", "0"},
+	}
+	for want, tokens := range tests {
+		assert.Equal(t, want, refName(tokens))
+	}
+}
+
+func TestJSONPointerRefConvertedOnce(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"billing": {"$ref": "#/definitions/Order/properties/address"},
+			"shipping": {"$ref": "#/definitions/Order/properties/address"},
+			"contact": {"$ref": "#/definitions/Order/properties/address"}
+		},
+		"definitions": {
+			"Order": {
+				"type": "object",
+				"properties": {
+					"address": {"type": "string", "format": "postal"}
+				}
+			}
+		}
+	}`
+
+	calls := 0
+	opts := DefaultOptions()
+	opts.TypeResolver = func(jsonType, format string, prop map[string]interface{}) (string, bool) {
+		if format == "postal" {
+			calls++
+			return "PostalAddress", true
+		}
+		return "", false
+	}
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	for _, want := range []string{
+		"  PostalAddress billing = 1;",
+		"  PostalAddress contact = 2;",
+		"  PostalAddress shipping = 3;",
+	} {
+		assert.Contains(t, got, want)
+	}
+	// Once for Order's own property and once for the shared $ref target
+	assert.Equal(t, 2, calls)
+}
+
+func TestDefinitionNameCollisions(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"lower": {"$ref": "#/definitions/order"},
+			"upper": {"$ref": "#/definitions/Order"},
+			"snake": {"$ref": "#/definitions/foo_bar"},
+			"camel": {"$ref": "#/definitions/fooBar"}
+		},
+		"definitions": {
+			"order": {"type": "object", "properties": {"id": {"type": "string"}}},
+			"Order": {"type": "object", "properties": {"number": {"type": "integer"}}},
+			"fooBar": {"type": "string", "enum": ["a", "b"]},
+			"foo_bar": {"type": "object", "properties": {"x": {"type": "string"}}}
+		}
+	}`
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	for i := 0; i < 5; i++ {
+		got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+		require.NoError(t, err)
+		for _, want := range []string{
+			"  fooBar camel = 1;",
+			"  order2 lower = 2;",
+			"  foo_bar2 snake = 3;",
+			"  Order upper = 4;",
+			"message Order {\n  int32 number = 1;\n}",
+			"message order2 {\n  string id = 1;\n}",
+			"message foo_bar2 {\n  string x = 1;\n}",
+			"enum fooBar {",
+		} {
+			assert.Contains(t, got, want)
+		}
+		assert.Empty(t, Validate(got))
+	}
+	assert.Contains(t, logs.String(), `warning: #/definitions/order: definition "order" collides with "Order"; generating it as order2`)
+}
+
+func TestRefSiblingKeywords(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"owner": {"$ref": "#/definitions/User", "description": "Who owns the order", "deprecated": true},
+			"code": {"$ref": "#/properties/raw", "description": "Short code", "maxLength": 8},
+			"raw": {"type": "string", "minLength": 1},
+			"count": {"$ref": "#/properties/raw_count", "maximum": 10},
+			"raw_count": {"type": "integer"},
+			"tags": {"$ref": "#/properties/raw_tags", "maxItems": 3},
+			"raw_tags": {"type": "array", "items": {"type": "string"}}
+		},
+		"definitions": {
+			"User": {"type": "object", "properties": {"name": {"type": "string"}}}
+		}
+	}`
+	opts := DefaultOptions()
+	opts.EmitConstraintComments = true
+	opts.EmitValidateOptions = true
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	for _, want := range []string{
+		"  // Short code\n  // length: min=1 max=8\n  string code = 1 [(validate.rules).string = {min_len: 1, max_len: 8}];",
+		"  // range: max=10\n  int32 count = 2 [(validate.rules).int32 = {lte: 10}];",
+		"  // Who owns the order\n  // Deprecated.\n  User owner = 3 [deprecated = true];",
+		"  // length: min=1\n  string raw = 4 [(validate.rules).string = {min_len: 1}];",
+		"  int32 raw_count = 5;",
+		"  repeated string raw_tags = 6;",
+		"  // items: max=3\n  repeated string tags = 7 [(validate.rules).repeated = {max_items: 3}];",
+	} {
+		assert.Contains(t, got, want)
+	}
+	assert.Empty(t, Validate(got))
+}
@@ -0,0 +1,307 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// defaultIndent is used when a ProtoFile doesn't set Indent
+const defaultIndent = "  "
+
+// RenderProto renders a ProtoFile as .proto source text
+func RenderProto(file *ProtoFile) string {
+	var out strings.Builder
+	// strings.Builder never returns a write error
+	_ = WriteProtoFile(&out, file)
+	return out.String()
+}
+
+// WriteProtoFile renders a ProtoFile as .proto source text directly to w
+func WriteProtoFile(w io.Writer, file *ProtoFile) error {
+	indent := file.Indent
+	if indent == "" {
+		indent = defaultIndent
+	}
+	syntax := file.Syntax
+	if syntax == "" {
+		syntax = "proto3"
+	}
+	out := &protoWriter{w: w, commentStyle: file.CommentStyle, indent: indent, proto2: syntax == "proto2"}
+	if file.Header != "" {
+		for _, line := range strings.Split(strings.TrimRight(file.Header, "\n"), "\n") {
+			out.printf("%s\n", strings.TrimRight("// "+line, " "))
+		}
+		out.printf("\n")
+	}
+	out.printf("syntax = \"%s\";\n\n", syntax)
+	out.printf("package %s;\n\n", file.Package)
+
+	if len(file.Options) > 0 {
+		for _, opt := range file.Options {
+			out.printf("option %s = %s;\n", opt.Name, opt.Value)
+		}
+		out.printf("\n")
+	}
+
+	if len(file.Imports) > 0 {
+		for _, imp := range file.Imports {
+			out.printf("import \"%s\";\n", imp)
+		}
+		out.printf("\n")
+	}
+
+	if file.EnumsFirst {
+		for i, enum := range file.Enums {
+			if i > 0 {
+				out.printf("\n")
+			}
+			renderEnum(out, enum)
+		}
+		for i, msg := range file.Messages {
+			if i > 0 || len(file.Enums) > 0 {
+				out.printf("\n")
+			}
+			renderMessage(out, msg)
+		}
+	} else {
+		for i, msg := range file.Messages {
+			if i > 0 {
+				out.printf("\n")
+			}
+			renderMessage(out, msg)
+		}
+		for i, enum := range file.Enums {
+			if i > 0 || len(file.Messages) > 0 {
+				out.printf("\n")
+			}
+			renderEnum(out, enum)
+		}
+	}
+
+	for i, svc := range file.Services {
+		if i > 0 || len(file.Messages) > 0 || len(file.Enums) > 0 {
+			out.printf("\n")
+		}
+		renderService(out, svc)
+	}
+	return out.err
+}
+
+// protoWriter wraps an io.Writer and remembers the first write error so the
+// renderer doesn't need to check every call
+type protoWriter struct {
+	w            io.Writer
+	err          error
+	commentStyle CommentStyle
+	// indent is one level of indentation
+	indent string
+	proto2 bool
+}
+
+func (p *protoWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+// renderMessage writes a single message definition
+func renderMessage(out *protoWriter, msg *Message) {
+	out.comment(msg.Comment, "")
+	out.printf("message %s {\n", msg.Name)
+	for _, opt := range msg.Options {
+		out.printf("%soption %s = %s;\n", out.indent, opt.Name, opt.Value)
+	}
+	for i, field := range msg.Fields {
+		if field.Oneof == "" {
+			renderField(out, field, out.indent)
+			continue
+		}
+		if i == 0 || msg.Fields[i-1].Oneof != field.Oneof {
+			out.printf("%soneof %s {\n", out.indent, field.Oneof)
+		}
+		renderField(out, field, out.indent+out.indent)
+		if i == len(msg.Fields)-1 || msg.Fields[i+1].Oneof != field.Oneof {
+			out.printf("%s}\n", out.indent)
+		}
+	}
+	for i, nested := range msg.Messages {
+		if i > 0 || len(msg.Fields) > 0 {
+			out.printf("\n")
+		}
+		renderNested(out, func(w *protoWriter) { renderMessage(w, nested) })
+	}
+	for i, nested := range msg.Enums {
+		if i > 0 || len(msg.Fields) > 0 || len(msg.Messages) > 0 {
+			out.printf("\n")
+		}
+		renderNested(out, func(w *protoWriter) { renderEnum(w, nested) })
+	}
+	out.printf("}\n")
+}
+
+// renderNested writes the definition render produces indented by one level,
+// for a type nested within a message
+func renderNested(out *protoWriter, render func(*protoWriter)) {
+	var buf bytes.Buffer
+	nested := *out
+	nested.w, nested.err = &buf, nil
+	render(&nested)
+	if nested.err != nil {
+		out.err = nested.err
+		return
+	}
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.TrimSpace(line) == "" {
+			out.printf("%s", line)
+		} else {
+			out.printf("%s%s", out.indent, line)
+		}
+	}
+}
+
+// renderField writes a single field declaration at the given indent
+func renderField(out *protoWriter, field *Field, indent string) {
+	trailing := ""
+	if out.commentStyle == CommentStyleTrailing && isTrailingComment(field.Comment) {
+		trailing = " // " + field.Comment
+	} else {
+		out.comment(field.Comment, indent)
+	}
+	label := ""
+	switch {
+	case field.Repeated:
+		label = "repeated "
+	case field.Optional, out.proto2 && field.Oneof == "" && !strings.HasPrefix(field.Type, "map<"):
+		// proto2 requires a label on every singular field outside a oneof
+		label = "optional "
+	}
+	out.printf("%s%s%s %s = %d%s;%s\n", indent, label, field.Type, field.Name, field.Number, formatFieldOptions(field.Options), trailing)
+}
+
+// renderEnum writes a single enum definition
+func renderEnum(out *protoWriter, enum *Enum) {
+	out.comment(enum.Comment, "")
+	out.printf("enum %s {\n", enum.Name)
+	for _, opt := range enum.Options {
+		out.printf("%soption %s = %s;\n", out.indent, opt.Name, opt.Value)
+	}
+	for _, v := range enum.Values {
+		trailing := ""
+		if v.Comment != "" {
+			trailing = " // " + v.Comment
+		}
+		out.printf("%s%s = %d;%s\n", out.indent, v.Name, v.Number, trailing)
+	}
+	out.printf("}\n")
+}
+
+// renderService writes a single service definition
+func renderService(out *protoWriter, svc *Service) {
+	out.printf("service %s {\n", svc.Name)
+	for _, m := range svc.Methods {
+		out.printf("%srpc %s(%s) returns (%s);\n", out.indent, m.Name, m.Input, m.Output)
+	}
+	out.printf("}\n")
+}
+
+// formatFieldOptions renders a field's options as " [a = b, c = d]", or an
+// empty string when there are none
+func formatFieldOptions(opts []FieldOption) string {
+	if len(opts) == 0 {
+		return ""
+	}
+	parts := make([]string, len(opts))
+	for i, opt := range opts {
+		parts[i] = fmt.Sprintf("%s = %s", opt.Name, opt.Value)
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+// quoteProtoString returns s as a double-quoted proto string literal
+func quoteProtoString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch ch {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if ch < 0x20 || ch == 0x7f {
+				out.WriteString(fmt.Sprintf(`\x%02x`, ch))
+			} else {
+				out.WriteByte(ch)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// maxTrailingCommentLength is the longest description rendered as a trailing
+// comment in CommentStyleTrailing
+const maxTrailingCommentLength = 60
+
+// isTrailingComment reports whether a description is short enough, and on a
+// single line, to follow its field as a trailing comment
+func isTrailingComment(desc string) bool {
+	return desc != "" && len(desc) <= maxTrailingCommentLength && !strings.Contains(desc, "\n")
+}
+
+// comment writes a description as a comment in the writer's comment style,
+// with every line at indent so it lines up with the declaration it
+// documents. Empty descriptions write nothing.
+func (p *protoWriter) comment(desc, indent string) {
+	if desc == "" {
+		return
+	}
+	text := formatDescription(desc)
+	if p.commentStyle == CommentStyleBlock {
+		text = formatBlockComment(desc)
+	}
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n") {
+		p.printf("%s%s", indent, line)
+	}
+	p.printf("\n")
+}
+
+// formatBlockComment formats a description as a /* */ comment, breaking up
+// any "*/" in the text so it can't end the comment early
+func formatBlockComment(desc string) string {
+	desc = strings.ReplaceAll(desc, "*/", "* /")
+	lines := strings.Split(desc, "\n")
+	if len(lines) == 1 {
+		return "/* " + desc + " */\n"
+	}
+	var out strings.Builder
+	out.WriteString("/*\n")
+	for _, line := range lines {
+		out.WriteString(strings.TrimRight(" * "+line, " "))
+		out.WriteString("\n")
+	}
+	out.WriteString(" */\n")
+	return out.String()
+}
+
+// formatDescription formats a description string as a proto comment
+func formatDescription(desc string) string {
+	lines := strings.Split(desc, "\n")
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString("// ")
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"strings"
+)
+
+const (
+	requestSuffix  = "Request"
+	responseSuffix = "Response"
+)
+
+// buildService pairs every <Name>Request message with a matching
+// <Name>Response message and wires each pair as a unary RPC. Request messages
+// without a matching response are reported as warnings and left out.
+func (c *conversion) buildService(messages []*Message) *Service {
+	names := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		names[msg.Name] = true
+	}
+
+	svc := &Service{Name: c.serviceName()}
+	for _, msg := range messages {
+		rpc := strings.TrimSuffix(msg.Name, requestSuffix)
+		if rpc == msg.Name || rpc == "" {
+			continue
+		}
+		if !names[rpc+responseSuffix] {
+			c.warnf(pointerJoin("#/definitions", msg.Name), WarnMissingResponse, "message %s has no matching %s%s; no rpc generated", msg.Name, rpc, responseSuffix)
+			continue
+		}
+		svc.Methods = append(svc.Methods, &Method{
+			Name:   rpc,
+			Input:  msg.Name,
+			Output: rpc + responseSuffix,
+		})
+	}
+	return svc
+}
+
+// serviceName returns the configured service name, defaulting to the
+// PascalCased last segment of the package name followed by "Service"
+func (c *conversion) serviceName() string {
+	if c.opts.ServiceName != "" {
+		return c.opts.ServiceName
+	}
+	pkg := c.opts.PackageName
+	if i := strings.LastIndex(pkg, "."); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return toProtoMessageName(pkg) + "Service"
+}
@@ -0,0 +1,39 @@
+package converter
+
+import "strings"
+
+// addSourceComments ends the comment of every message and enum generated
+// from the schema with a "source: <document>#<pointer>" line locating that
+// schema. Synthesized wrappers, which have no source, are left alone.
+func (c *conversion) addSourceComments(file *ProtoFile) {
+	for _, msg := range file.Messages {
+		if msg.Source != "" {
+			msg.Comment = appendComment(msg.Comment, "source: "+c.sourceLocation(msg.Source))
+		}
+	}
+	for _, enum := range file.Enums {
+		if enum.Source != "" {
+			enum.Comment = appendComment(enum.Comment, "source: "+c.sourceLocation(enum.Source))
+		}
+	}
+}
+
+// sourceLocation returns the location of the schema at pointer. Within an
+// embedded resource with an $id, such as a bundled common.json, it is the
+// resource's URI and the pointer relative to it; otherwise it is the pointer
+// within the document, named by its $id or Options.SourceName when known.
+// Definitions lifted from elsewhere are located under sourcePrefix.
+func (c *conversion) sourceLocation(pointer string) string {
+	uri, resource := c.resourceAt(pointer)
+	if len(resource) > 0 {
+		tokens, _ := parsePointer(pointer)
+		return uri + pointerJoin("#", tokens[len(resource):]...)
+	}
+	if c.sourcePrefix != "" && strings.HasPrefix(pointer, definitionsRefPrefix) {
+		pointer = c.sourcePrefix + strings.TrimPrefix(pointer, definitionsRefPrefix)
+	}
+	if uri == "" {
+		uri = c.opts.SourceName
+	}
+	return strings.TrimSuffix(uri, "#") + pointer
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceComments(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"total": {"$ref": "https://example.com/common.json#/definitions/Money"},
+			"status": {"$ref": "#/definitions/Status"}
+		},
+		"definitions": {
+			"Status": {"enum": ["open", "closed"]},
+			"common": {
+				"$id": "https://example.com/common.json",
+				"definitions": {
+					"Money": {"type": "object", "properties": {
+						"currency": {"type": "string"},
+						"units": {"type": "integer"}
+					}}
+				}
+			}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.EmitSourceComments = true
+	opts.SourceName = "order.json"
+	got, err := ConvertJSONSchemaToProto(schema, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "// source: order.json#\nmessage Root {")
+	assert.Contains(t, got, "// source: https://example.com/common.json#/definitions/Money\nmessage Money {")
+	assert.Contains(t, got, "// source: order.json#/definitions/Status\nenum Status {")
+	assert.Empty(t, Validate(got))
+
+	got, err = ConvertJSONSchemaToProto(schema, DefaultOptions())
+	require.NoError(t, err)
+	assert.NotContains(t, got, "source:")
+}
+
+func TestOpenAPISourceComments(t *testing.T) {
+	spec := `{"openapi": "3.0.0", "components": {"schemas": {
+		"Pet": {"type": "object", "properties": {"owner": {"$ref": "#/components/schemas/Owner"}}},
+		"Owner": {"type": "object", "properties": {"name": {"type": "string"}}}
+	}}}`
+	opts := DefaultOptions()
+	opts.EmitSourceComments = true
+	opts.SourceName = "petstore.json"
+	got, err := ConvertOpenAPIToProto(spec, opts)
+	require.NoError(t, err)
+	assert.Contains(t, got, "// source: petstore.json#/components/schemas/Owner\nmessage Owner {")
+	assert.Contains(t, got, "// source: petstore.json#/components/schemas/Pet\nmessage Pet {")
+}
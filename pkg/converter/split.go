@@ -0,0 +1,273 @@
+package converter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// serviceFileName is the file of the split output holding the service
+const serviceFileName = "service.proto"
+
+// ConvertToFiles converts a JSON Schema into multiple .proto files sharing
+// one package: one file per top-level definition (and one for Root), each
+// importing the files that declare the types it references. Types
+// synthesized for inline objects, enums and wrappers live in the file of the
+// first definition that uses them. Definitions that reference each other in
+// a cycle share a file, since proto imports must not be cyclic. The result
+// maps file names to their contents.
+func ConvertToFiles(schemaStr string, opts *Options) (map[string]string, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	file, err := BuildProtoFile(schemaStr, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string)
+	for name, f := range SplitProtoFile(file, opts) {
+		out[name] = RenderProto(f)
+	}
+	return out, nil
+}
+
+// SplitProtoFile splits a ProtoFile into one file per top-level definition
+// as described for ConvertToFiles. Any service is placed in service.proto,
+// and a definition that would also be written there, such as one named
+// Service, goes to service2.proto instead.
+func SplitProtoFile(file *ProtoFile, opts *Options) map[string]*ProtoFile {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	messages := make(map[string]*Message)
+	enums := make(map[string]*Enum)
+	var units []string
+	for _, msg := range file.Messages {
+		messages[msg.Name] = msg
+		if isTopLevelSource(msg.Source) {
+			units = append(units, msg.Name)
+		}
+	}
+	for _, enum := range file.Enums {
+		enums[enum.Name] = enum
+		if isTopLevelSource(enum.Source) {
+			units = append(units, enum.Name)
+		}
+	}
+	sort.Strings(units)
+
+	// Each synthesized type is owned by the first unit that reaches it
+	owner := make(map[string]string)
+	for _, u := range units {
+		owner[u] = u
+	}
+	for _, u := range units {
+		queue := []string{u}
+		for len(queue) > 0 {
+			msg := messages[queue[0]]
+			queue = queue[1:]
+			if msg == nil {
+				continue
+			}
+			for _, dep := range messageDependencies(msg) {
+				if _, owned := owner[dep]; owned || (messages[dep] == nil && enums[dep] == nil) {
+					continue
+				}
+				owner[dep] = u
+				queue = append(queue, dep)
+			}
+		}
+	}
+	// Types unreachable from any unit (e.g. when the schema has no
+	// definitions) get a file of their own
+	for name := range messages {
+		if _, ok := owner[name]; !ok {
+			owner[name] = name
+			units = append(units, name)
+		}
+	}
+	for name := range enums {
+		if _, ok := owner[name]; !ok {
+			owner[name] = name
+			units = append(units, name)
+		}
+	}
+
+	// Units depend on the owners of the types they reference
+	edges := make(map[string][]string)
+	for name, msg := range messages {
+		from := owner[name]
+		for _, dep := range messageDependencies(msg) {
+			if to, ok := owner[dep]; ok && to != from {
+				edges[from] = append(edges[from], to)
+			}
+		}
+	}
+	for from := range edges {
+		edges[from] = uniqueSorted(edges[from])
+	}
+
+	// Units in a dependency cycle are merged into one file. A file name
+	// already taken, such as service.proto by the service, gets a number
+	// appended.
+	fileOf := make(map[string]string)
+	taken := make(map[string]bool)
+	if len(file.Services) > 0 {
+		taken[serviceFileName] = true
+	}
+	for _, component := range stronglyConnected(units, edges) {
+		base := strings.TrimSuffix(protoFileName(component[0]), ".proto")
+		name := base + ".proto"
+		for n := 2; taken[name]; n++ {
+			name = base + strconv.Itoa(n) + ".proto"
+		}
+		taken[name] = true
+		for _, u := range component {
+			fileOf[u] = name
+		}
+	}
+
+	files := make(map[string]*ProtoFile)
+	get := func(name string) *ProtoFile {
+		f, ok := files[name]
+		if !ok {
+			f = &ProtoFile{Syntax: file.Syntax, Package: file.Package, Header: file.Header, Options: file.Options, CommentStyle: file.CommentStyle, Indent: file.Indent, EnumsFirst: file.EnumsFirst}
+			files[name] = f
+		}
+		return f
+	}
+	for _, msg := range file.Messages {
+		f := get(fileOf[owner[msg.Name]])
+		f.Messages = append(f.Messages, msg)
+	}
+	for _, enum := range file.Enums {
+		f := get(fileOf[owner[enum.Name]])
+		f.Enums = append(f.Enums, enum)
+	}
+	if len(file.Services) > 0 {
+		get(serviceFileName).Services = file.Services
+	}
+
+	for name, f := range files {
+		imports := collectImports(f, opts.Imports, opts.TypeImports)
+		for _, dep := range fileDependencies(f) {
+			if u, ok := owner[dep]; ok && fileOf[u] != name {
+				imports = append(imports, fileOf[u])
+			}
+		}
+		f.Imports = uniqueSorted(imports)
+	}
+	if opts.PackagePerDefinition {
+		packageFiles(files, owner, fileOf, opts.GoPackage)
+	}
+	return files
+}
+
+// packageFiles gives each split file its own package nested under the
+// original one, e.g. schema.order for order.proto, and qualifies the types
+// each file references from other files. With a go_package, each file's Go
+// package is a subdirectory of it named after the file. Messages and
+// services are copied before their types are rewritten.
+func packageFiles(files map[string]*ProtoFile, owner, fileOf map[string]string, goPackage string) {
+	packages := make(map[string]string, len(files))
+	for name, f := range files {
+		sub := strings.TrimSuffix(name, ".proto")
+		packages[name] = sub
+		if f.Package != "" {
+			packages[name] = f.Package + "." + sub
+		}
+	}
+
+	for name, f := range files {
+		qualify := func(typ string) string {
+			if u, ok := owner[typ]; ok && fileOf[u] != name {
+				return packages[fileOf[u]] + "." + typ
+			}
+			return typ
+		}
+		f.Package = packages[name]
+
+		messages := make([]*Message, len(f.Messages))
+		for i, msg := range f.Messages {
+			copied := *msg
+			copied.Fields = make([]*Field, len(msg.Fields))
+			for j, field := range msg.Fields {
+				fieldCopy := *field
+				fieldCopy.Type = mapFieldType(field.Type, qualify)
+				copied.Fields[j] = &fieldCopy
+			}
+			messages[i] = &copied
+		}
+		f.Messages = messages
+
+		services := make([]*Service, len(f.Services))
+		for i, svc := range f.Services {
+			copied := &Service{Name: svc.Name}
+			for _, m := range svc.Methods {
+				copied.Methods = append(copied.Methods, &Method{Name: m.Name, Input: qualify(m.Input), Output: qualify(m.Output)})
+			}
+			services[i] = copied
+		}
+		f.Services = services
+
+		if goPackage != "" {
+			// Drop any explicit ";name" so the Go package is named after
+			// its directory
+			path, _, _ := strings.Cut(goPackage, ";")
+			options := make([]FileOption, len(f.Options))
+			copy(options, f.Options)
+			for i, opt := range options {
+				if opt.Name == "go_package" {
+					options[i].Value = quoteProtoString(path + "/" + strings.TrimSuffix(name, ".proto"))
+				}
+			}
+			f.Options = options
+		}
+	}
+}
+
+// fileDependencies returns every type referenced by the messages and
+// services in f
+func fileDependencies(f *ProtoFile) []string {
+	var deps []string
+	for _, msg := range f.Messages {
+		deps = append(deps, messageDependencies(msg)...)
+	}
+	for _, svc := range f.Services {
+		for _, m := range svc.Methods {
+			deps = append(deps, m.Input, m.Output)
+		}
+	}
+	return deps
+}
+
+// isTopLevelSource reports whether a source pointer names the schema root or
+// a top-level definition
+func isTopLevelSource(source string) bool {
+	if source == "#" {
+		return true
+	}
+	rest := strings.TrimPrefix(source, definitionsRefPrefix)
+	return rest != source && rest != "" && !strings.Contains(rest, "/")
+}
+
+// protoFileName returns the snake_case .proto file name for a type name
+func protoFileName(typeName string) string {
+	return strings.ToLower(toEnumValueName(typeName)) + ".proto"
+}
+
+// uniqueSorted returns the sorted, de-duplicated values
+func uniqueSorted(values []string) []string {
+	set := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !set[v] {
+			set[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,198 @@
+package converter
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertToFiles(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"order": {"$ref": "#/definitions/Order"}
+		},
+		"definitions": {
+			"Order": {
+				"type": "object",
+				"properties": {
+					"customer": {"$ref": "#/definitions/Customer"},
+					"status": {"type": "string", "enum": ["open", "closed"]},
+					"placed_at": {"type": "string", "format": "date-time"},
+					"shipping": {"type": "object", "properties": {"carrier": {"type": "string"}}}
+				}
+			},
+			"Customer": {
+				"type": "object",
+				"properties": {"name": {"type": "string"}}
+			},
+			"Node": {
+				"type": "object",
+				"properties": {"edges": {"type": "array", "items": {"$ref": "#/definitions/Edge"}}}
+			},
+			"Edge": {
+				"type": "object",
+				"properties": {"target": {"$ref": "#/definitions/Node"}}
+			}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.UseWellKnownTypes = true
+	files, err := ConvertToFiles(schema, opts)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"customer.proto", "edge.proto", "order.proto", "root.proto"}, names)
+
+	assert.Equal(t, `syntax = "proto3";
+
+package schema;
+
+import "order.proto";
+
+message Root {
+  Order order = 1;
+}
+`, files["root.proto"])
+
+	assert.Equal(t, `syntax = "proto3";
+
+package schema;
+
+import "customer.proto";
+import "google/protobuf/timestamp.proto";
+
+message Order {
+  Customer customer = 1;
+  google.protobuf.Timestamp placed_at = 2;
+  Shipping shipping = 3;
+  StatusEnum status = 4;
+}
+
+message Shipping {
+  string carrier = 1;
+}
+
+enum StatusEnum {
+  STATUS_ENUM_UNSPECIFIED = 0;
+  STATUS_ENUM_OPEN = 1;
+  STATUS_ENUM_CLOSED = 2;
+}
+`, files["order.proto"])
+
+	// Node and Edge reference each other, so they share a file
+	assert.Contains(t, files["edge.proto"], "message Edge {")
+	assert.Contains(t, files["edge.proto"], "message Node {")
+	assert.NotContains(t, files["edge.proto"], "import")
+
+	for name, content := range files {
+		assert.Contains(t, content, "package schema;", name)
+	}
+}
+
+func TestConvertToFilesService(t *testing.T) {
+	schema := `{
+		"definitions": {
+			"PingRequest": {"type": "object", "properties": {}},
+			"PingResponse": {"type": "object", "properties": {}}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.GenerateService = true
+	files, err := ConvertToFiles(schema, opts)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+	assert.Contains(t, files["service.proto"], "import \"ping_request.proto\";\nimport \"ping_response.proto\";\n\nservice ")
+	assert.Contains(t, files["service.proto"], "rpc Ping(PingRequest) returns (PingResponse);")
+}
+
+func TestConvertToFilesServiceDefinition(t *testing.T) {
+	schema := `{
+		"definitions": {
+			"Service": {"type": "object", "properties": {"name": {"type": "string"}}},
+			"GetRequest": {"type": "object", "properties": {"service": {"$ref": "#/definitions/Service"}}},
+			"GetResponse": {"type": "object", "properties": {"name": {"type": "string"}}}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.GenerateService = true
+	files, err := ConvertToFiles(schema, opts)
+	require.NoError(t, err)
+	assert.Len(t, files, 4)
+	// The service keeps service.proto; the definition moves aside so the
+	// files don't import each other
+	assert.Contains(t, files["service.proto"], "rpc Get(GetRequest) returns (GetResponse);")
+	assert.NotContains(t, files["service.proto"], "message Service")
+	assert.Contains(t, files["service2.proto"], "message Service {\n  string name = 1;\n}")
+	assert.Contains(t, files["get_request.proto"], "import \"service2.proto\";")
+	for name, content := range files {
+		assert.NotContains(t, content, "import \"service.proto\";", name)
+	}
+}
+
+func TestConvertToFilesPackagePerDefinition(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"order": {"$ref": "#/definitions/Order"},
+			"lookup": {"type": "object", "additionalProperties": {"$ref": "#/definitions/Customer"}}
+		},
+		"definitions": {
+			"Order": {
+				"type": "object",
+				"properties": {
+					"customer": {"$ref": "#/definitions/Customer"},
+					"shipping": {"type": "object", "properties": {"carrier": {"type": "string"}}}
+				}
+			},
+			"Customer": {"type": "object", "properties": {"name": {"type": "string"}}},
+			"GetOrderRequest": {"type": "object", "properties": {"id": {"type": "string"}}},
+			"GetOrderResponse": {"type": "object", "properties": {"order": {"$ref": "#/definitions/Order"}}}
+		}
+	}`
+
+	opts := DefaultOptions()
+	opts.PackagePerDefinition = true
+	opts.GenerateService = true
+	opts.GoPackage = "example.com/gen;gen"
+	files, err := ConvertToFiles(schema, opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, `syntax = "proto3";
+
+package schema.root;
+
+option go_package = "example.com/gen/root";
+
+import "customer.proto";
+import "order.proto";
+
+message Root {
+  map<string, schema.customer.Customer> lookup = 1;
+  schema.order.Order order = 2;
+}
+`, files["root.proto"])
+	assert.Contains(t, files["order.proto"], "package schema.order;")
+	assert.Contains(t, files["order.proto"], "message Order {\n  schema.customer.Customer customer = 1;\n  Shipping shipping = 2;\n}")
+	assert.Contains(t, files["service.proto"], "package schema.service;")
+	assert.Contains(t, files["service.proto"], "rpc GetOrder(schema.get_order_request.GetOrderRequest) returns (schema.get_order_response.GetOrderResponse);")
+	for name, content := range files {
+		assert.Empty(t, Validate(content), name)
+	}
+
+	// The single-file output and the ProtoFile it came from are unchanged
+	file, err := BuildProtoFile(schema, opts)
+	require.NoError(t, err)
+	SplitProtoFile(file, opts)
+	assert.Equal(t, "schema", file.Package)
+	assert.Contains(t, RenderProto(file), "  Order order = 2;")
+}
@@ -0,0 +1,510 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Field numbers 19000 through 19999 are reserved for the protobuf implementation
+const (
+	reservedFieldNumberStart = 19000
+	reservedFieldNumberEnd   = 19999
+	maxFieldNumber           = 1<<29 - 1
+)
+
+// protoIdentifier matches a legal proto identifier
+var protoIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// scalarTypes are the proto scalar value types
+var scalarTypes = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true,
+	"uint64": true, "sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true, "bool": true, "string": true, "bytes": true,
+}
+
+// Validate performs a lightweight structural check of .proto source text. It
+// reports syntax errors, references to undefined message or enum types, duplicate
+// or out-of-range field numbers, enums whose first value isn't 0, duplicate
+// enum value names, enum value numbers used twice without allow_alias and
+// illegal identifiers. Qualified type names that aren't defined in the file are assumed
+// to come from an import. A nil result means no problems were found.
+func Validate(proto string) []error {
+	p := &protoParser{tokens: tokenizeProto(proto)}
+	p.parseFile()
+	if p.syntaxErr != nil {
+		return []error{p.syntaxErr}
+	}
+
+	defined := make(map[string]bool)
+	for _, d := range p.definitions {
+		defined[d] = true
+	}
+	for _, ref := range p.references {
+		if !p.resolves(ref, defined) {
+			p.errorf("%s: field %s references undefined type %s", ref.owner, ref.field, ref.typeName)
+		}
+	}
+	return p.errs
+}
+
+// protoToken is a lexical token with the line it appeared on
+type protoToken struct {
+	text string
+	line int
+}
+
+// tokenizeProto splits proto source into words, string literals and symbols,
+// dropping whitespace and comments
+func tokenizeProto(src string) []protoToken {
+	var tokens []protoToken
+	line := 1
+	for i := 0; i < len(src); {
+		ch := src[i]
+		switch {
+		case ch == '\n':
+			line++
+			i++
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case ch == '"' || ch == '\'':
+			start := i
+			i++
+			for i < len(src) && src[i] != ch && src[i] != '\n' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+			if i > len(src) {
+				i = len(src)
+			}
+			tokens = append(tokens, protoToken{text: src[start:i], line: line})
+		case isWordChar(ch):
+			start := i
+			for i < len(src) && isWordChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, protoToken{text: src[start:i], line: line})
+		default:
+			tokens = append(tokens, protoToken{text: string(ch), line: line})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || ch == '.' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+// typeReference records a field's use of a message or enum type
+type typeReference struct {
+	scope    string
+	owner    string
+	field    string
+	typeName string
+}
+
+// protoParser is a recursive-descent parser over the subset of proto syntax
+// needed to validate generated files
+type protoParser struct {
+	tokens      []protoToken
+	pos         int
+	pkg         string
+	hasImports  bool
+	definitions []string
+	references  []typeReference
+	errs        []error
+	syntaxErr   error
+}
+
+func (p *protoParser) errorf(format string, args ...interface{}) {
+	p.errs = append(p.errs, fmt.Errorf(format, args...))
+}
+
+func (p *protoParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *protoParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+// expect consumes the next token, recording a syntax error if it isn't want
+func (p *protoParser) expect(want string) bool {
+	if p.syntaxErr != nil {
+		return false
+	}
+	line := p.line()
+	if got := p.next(); got != want {
+		p.syntaxErr = fmt.Errorf("line %d: expected %q, got %q", line, want, got)
+		return false
+	}
+	return true
+}
+
+func (p *protoParser) line() int {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].line
+	}
+	if len(p.tokens) > 0 {
+		return p.tokens[len(p.tokens)-1].line
+	}
+	return 1
+}
+
+func (p *protoParser) ok() bool {
+	return p.syntaxErr == nil && p.pos < len(p.tokens)
+}
+
+func (p *protoParser) parseFile() {
+	for p.ok() {
+		switch p.peek() {
+		case "syntax", "edition":
+			p.skipStatement()
+		case "package":
+			p.next()
+			p.pkg = p.next()
+			p.expect(";")
+		case "import":
+			p.hasImports = true
+			p.skipStatement()
+		case "option":
+			p.skipStatement()
+		case "message":
+			p.parseMessage("")
+		case "enum":
+			p.parseEnum("")
+		case "service":
+			p.parseService()
+		case ";":
+			p.next()
+		default:
+			p.syntaxErr = fmt.Errorf("line %d: unexpected %q", p.line(), p.peek())
+		}
+	}
+}
+
+// skipStatement consumes tokens through the next top-level semicolon,
+// skipping over aggregate option values in braces
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for p.syntaxErr == nil {
+		if p.pos >= len(p.tokens) {
+			p.syntaxErr = fmt.Errorf("line %d: unexpected end of file", p.line())
+			return
+		}
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// skipFieldOptions consumes a bracketed [name = value, ...] option list if
+// one follows
+func (p *protoParser) skipFieldOptions() {
+	if p.peek() != "[" {
+		return
+	}
+	depth := 0
+	for p.ok() {
+		switch p.next() {
+		case "[":
+			depth++
+		case "]":
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+	if p.syntaxErr == nil {
+		p.syntaxErr = fmt.Errorf("line %d: unterminated field options", p.line())
+	}
+}
+
+// checkIdentifier records an error if name isn't a legal proto identifier
+func (p *protoParser) checkIdentifier(kind, name string) {
+	if !protoIdentifier.MatchString(name) {
+		p.errorf("invalid %s name %q", kind, name)
+	}
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *protoParser) parseMessage(scope string) {
+	p.next() // message
+	name := p.next()
+	p.checkIdentifier("message", name)
+	fullName := qualify(scope, name)
+	p.definitions = append(p.definitions, fullName)
+	if !p.expect("{") {
+		return
+	}
+
+	numbers := make(map[int]string)
+	for p.ok() && p.peek() != "}" {
+		switch p.peek() {
+		case "message":
+			p.parseMessage(fullName)
+		case "enum":
+			p.parseEnum(fullName)
+		case "option", "reserved", "extensions":
+			p.skipStatement()
+		case "oneof":
+			p.next()
+			p.checkIdentifier("oneof", p.next())
+			if !p.expect("{") {
+				return
+			}
+			for p.ok() && p.peek() != "}" {
+				if p.peek() == "option" {
+					p.skipStatement()
+					continue
+				}
+				p.parseField(fullName, numbers)
+			}
+			p.expect("}")
+		case ";":
+			p.next()
+		default:
+			p.parseField(fullName, numbers)
+		}
+	}
+	p.expect("}")
+}
+
+// parseField parses a field declaration, checking its name and number and
+// recording the types it references. numbers tracks the field numbers already
+// used in the enclosing message.
+func (p *protoParser) parseField(owner string, numbers map[int]string) {
+	switch p.peek() {
+	case "repeated", "optional", "required":
+		p.next()
+	}
+
+	var types []string
+	if p.peek() == "map" {
+		p.next()
+		if !p.expect("<") {
+			return
+		}
+		types = append(types, p.next())
+		if !p.expect(",") {
+			return
+		}
+		types = append(types, p.next())
+		if !p.expect(">") {
+			return
+		}
+	} else {
+		types = append(types, p.next())
+	}
+
+	name := p.next()
+	p.checkIdentifier("field", name)
+	if !p.expect("=") {
+		return
+	}
+	numText := p.next()
+	p.skipFieldOptions()
+	if !p.expect(";") {
+		return
+	}
+
+	number, err := strconv.ParseInt(numText, 0, 64)
+	switch {
+	case err != nil:
+		p.errorf("%s: field %s has invalid number %q", owner, name, numText)
+	case number < 1 || number > maxFieldNumber:
+		p.errorf("%s: field %s number %d is out of range", owner, name, number)
+	case number >= reservedFieldNumberStart && number <= reservedFieldNumberEnd:
+		p.errorf("%s: field %s uses reserved number %d", owner, name, number)
+	default:
+		if other, dup := numbers[int(number)]; dup {
+			p.errorf("%s: duplicate field number %d (%s, %s)", owner, number, other, name)
+		} else {
+			numbers[int(number)] = name
+		}
+	}
+
+	for _, typ := range types {
+		if !scalarTypes[typ] {
+			p.references = append(p.references, typeReference{scope: owner, owner: owner, field: name, typeName: typ})
+		}
+	}
+}
+
+func (p *protoParser) parseEnum(scope string) {
+	p.next() // enum
+	name := p.next()
+	p.checkIdentifier("enum", name)
+	fullName := qualify(scope, name)
+	p.definitions = append(p.definitions, fullName)
+	if !p.expect("{") {
+		return
+	}
+
+	first := true
+	allowAlias := false
+	names := make(map[string]bool)
+	numbers := make(map[int64]string)
+	for p.ok() && p.peek() != "}" {
+		switch p.peek() {
+		case "option":
+			if p.pos+3 < len(p.tokens) && p.tokens[p.pos+1].text == "allow_alias" && p.tokens[p.pos+3].text == "true" {
+				allowAlias = true
+			}
+			p.skipStatement()
+			continue
+		case "reserved":
+			p.skipStatement()
+			continue
+		case ";":
+			p.next()
+			continue
+		}
+		valueName := p.next()
+		p.checkIdentifier("enum value", valueName)
+		if !p.expect("=") {
+			return
+		}
+		numText := p.next()
+		if numText == "-" {
+			numText += p.next()
+		}
+		p.skipFieldOptions()
+		if !p.expect(";") {
+			return
+		}
+		number, err := strconv.ParseInt(numText, 0, 32)
+		if err != nil {
+			p.errorf("%s: enum value %s has invalid number %q", fullName, valueName, numText)
+		} else if first && number != 0 {
+			p.errorf("%s: first enum value %s must be 0, got %d", fullName, valueName, number)
+		} else if other, dup := numbers[number]; dup && !allowAlias {
+			p.errorf("%s: duplicate enum value number %d (%s, %s) without allow_alias", fullName, number, other, valueName)
+		} else if !dup {
+			numbers[number] = valueName
+		}
+		if names[valueName] {
+			p.errorf("%s: duplicate enum value %s", fullName, valueName)
+		}
+		names[valueName] = true
+		first = false
+	}
+	p.expect("}")
+}
+
+func (p *protoParser) parseService() {
+	p.next() // service
+	name := p.next()
+	p.checkIdentifier("service", name)
+	if !p.expect("{") {
+		return
+	}
+	for p.ok() && p.peek() != "}" {
+		switch p.peek() {
+		case "option":
+			p.skipStatement()
+			continue
+		case ";":
+			p.next()
+			continue
+		}
+		if !p.expect("rpc") {
+			return
+		}
+		method := p.next()
+		p.checkIdentifier("rpc", method)
+		owner := name + "." + method
+		for _, part := range []string{"input", "output"} {
+			if part == "output" && !p.expect("returns") {
+				return
+			}
+			if !p.expect("(") {
+				return
+			}
+			if p.peek() == "stream" {
+				p.next()
+			}
+			p.references = append(p.references, typeReference{owner: owner, field: part, typeName: p.next()})
+			if !p.expect(")") {
+				return
+			}
+		}
+		if p.peek() == "{" {
+			depth := 0
+			for p.ok() {
+				tok := p.next()
+				if tok == "{" {
+					depth++
+				} else if tok == "}" {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+		} else {
+			p.expect(";")
+		}
+	}
+	p.expect("}")
+}
+
+// resolves reports whether a type reference names a type defined in the file,
+// following proto scoping rules from the innermost scope outwards. Qualified
+// names that don't resolve are assumed to be imported when the file has imports.
+func (p *protoParser) resolves(ref typeReference, defined map[string]bool) bool {
+	name := strings.TrimPrefix(ref.typeName, ".")
+	if p.pkg != "" {
+		name = strings.TrimPrefix(name, p.pkg+".")
+	}
+	scope := ref.scope
+	for {
+		if defined[qualify(scope, name)] {
+			return true
+		}
+		if scope == "" {
+			break
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+	return strings.Contains(name, ".") && p.hasImports
+}
@@ -0,0 +1,180 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		proto string
+		want  []string
+	}{
+		{
+			name: "valid file",
+			proto: `syntax = "proto3";
+
+package schema;
+
+import "google/protobuf/timestamp.proto";
+
+// A user
+message User {
+  string name = 1 [json_name = "userName"];
+  repeated Role roles = 2;
+  map<string, Address> addresses = 3;
+  google.protobuf.Timestamp created = 4;
+  oneof contact {
+    string email = 5;
+    string phone = 6;
+  }
+  message Address {
+    string city = 1;
+  }
+}
+
+enum Role {
+  ROLE_UNSPECIFIED = 0;
+  ROLE_ADMIN = 1;
+}
+
+service UserService {
+  rpc GetUser(User) returns (User);
+}
+`,
+		},
+		{
+			name: "undefined type",
+			proto: `syntax = "proto3";
+package schema;
+message A {
+  Missing b = 1;
+}
+`,
+			want: []string{"A: field b references undefined type Missing"},
+		},
+		{
+			name: "qualified type without imports",
+			proto: `syntax = "proto3";
+package schema;
+message A {
+  google.protobuf.Any b = 1;
+}
+`,
+			want: []string{"A: field b references undefined type google.protobuf.Any"},
+		},
+		{
+			name: "duplicate field numbers across oneof",
+			proto: `syntax = "proto3";
+package schema;
+message A {
+  string b = 1;
+  oneof c {
+    string d = 1;
+  }
+}
+`,
+			want: []string{"A: duplicate field number 1 (b, d)"},
+		},
+		{
+			name: "reserved and out of range numbers",
+			proto: `syntax = "proto3";
+package schema;
+message A {
+  string b = 19000;
+  string c = 0;
+}
+`,
+			want: []string{"A: field b uses reserved number 19000", "A: field c number 0 is out of range"},
+		},
+		{
+			name: "first enum value not zero",
+			proto: `syntax = "proto3";
+package schema;
+enum Color {
+  RED = 1;
+  GREEN = 2;
+}
+`,
+			want: []string{"Color: first enum value RED must be 0, got 1"},
+		},
+		{
+			name: "duplicate enum values",
+			proto: `syntax = "proto3";
+package schema;
+enum Color {
+  COLOR_UNSPECIFIED = 0;
+  COLOR_RED = 1;
+  COLOR_RED = 2;
+  COLOR_CRIMSON = 1;
+}
+enum Shade {
+  option allow_alias = true;
+  SHADE_UNSPECIFIED = 0;
+  SHADE_DARK = 1;
+  SHADE_DIM = 1;
+}
+`,
+			want: []string{"Color: duplicate enum value COLOR_RED", "Color: duplicate enum value number 1 (COLOR_RED, COLOR_CRIMSON) without allow_alias"},
+		},
+		{
+			name: "illegal identifiers",
+			proto: `syntax = "proto3";
+package schema;
+message Foo.bar {
+  string 1st = 1;
+}
+`,
+			want: []string{`invalid message name "Foo.bar"`, `invalid field name "1st"`},
+		},
+		{
+			name: "syntax error",
+			proto: `syntax = "proto3";
+package schema;
+message A {
+  repeated repeated string b = 1;
+}
+`,
+			want: []string{`line 4: expected "=", got "b"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Validate(tt.proto)
+			got := make([]string, len(errs))
+			for i, err := range errs {
+				got[i] = err.Error()
+			}
+			assert.Equal(t, len(tt.want), len(got), "errors: %v", got)
+			for _, want := range tt.want {
+				assert.Contains(t, got, want)
+			}
+		})
+	}
+}
+
+func TestValidateGeneratedOutput(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"user": {"$ref": "#/definitions/User"},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"payload": {}
+		},
+		"definitions": {
+			"User": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"address": {"type": "object", "properties": {"city": {"type": "string"}}}
+				}
+			}
+		}
+	}`
+	got, err := ConvertJSONSchemaToProto(schema, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Empty(t, Validate(got))
+}
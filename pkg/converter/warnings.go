@@ -0,0 +1,62 @@
+package converter
+
+// Warning is a non-fatal problem found while converting a schema, such as a
+// property that had to fall back to google.protobuf.Any
+type Warning struct {
+	// Path is the JSON pointer of the schema location, e.g. #/properties/id
+	Path string
+	// Code identifies the kind of problem
+	Code WarningCode
+	// Message describes the problem and how it was handled
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Path + ": " + w.Message
+}
+
+// WarningCode identifies the kind of problem a Warning reports
+type WarningCode string
+
+const (
+	// WarnUntyped marks a schema without a type that became
+	// google.protobuf.Any
+	WarnUntyped WarningCode = "untyped"
+	// WarnFalseSchema marks a false schema, or false allOf member, that was
+	// skipped because no value matches it
+	WarnFalseSchema WarningCode = "false-schema"
+	// WarnConflictingTypes marks allOf members declaring different types
+	WarnConflictingTypes WarningCode = "conflicting-types"
+	// WarnUnrepresentableEnum marks an enum whose values a proto enum can't
+	// hold
+	WarnUnrepresentableEnum WarningCode = "unrepresentable-enum"
+	// WarnMixedMapValues marks a map whose values have differing types
+	WarnMixedMapValues WarningCode = "mixed-map-values"
+	// WarnEmptyOneOf marks a oneOf without non-null alternatives
+	WarnEmptyOneOf WarningCode = "empty-oneof"
+	// WarnNestedOneOf marks a oneOf used as array items or map values, where
+	// a proto oneof can't appear
+	WarnNestedOneOf WarningCode = "nested-oneof"
+	// WarnNameCollision marks a definition renamed because its name clashes
+	// with another
+	WarnNameCollision WarningCode = "name-collision"
+	// WarnInvalidTitle marks a title that couldn't be used as the root
+	// message name
+	WarnInvalidTitle WarningCode = "invalid-title"
+	// WarnConditional marks if/then/else subschemas, which proto can't
+	// express
+	WarnConditional WarningCode = "conditional"
+	// WarnUnknownDraft marks a $schema naming no supported draft
+	WarnUnknownDraft WarningCode = "unknown-draft"
+	// WarnMissingResponse marks a Request message without a matching
+	// Response message, for which no rpc was generated
+	WarnMissingResponse WarningCode = "missing-response"
+	// WarnDependencies marks dependentRequired, dependentSchemas or
+	// dependencies keywords, which proto can't express
+	WarnDependencies WarningCode = "dependencies"
+	// WarnUnknownRequired marks a required entry naming no declared
+	// property
+	WarnUnknownRequired WarningCode = "unknown-required"
+	// WarnRecursiveRef marks a recursive $ref that Flatten couldn't inline
+	WarnRecursiveRef WarningCode = "recursive-ref"
+)
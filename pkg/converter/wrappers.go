@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// listWrapper returns the name of a message wrapping a repeated field of
+// elemType, e.g. message StringList { repeated string values = 1; }. proto has
+// no nested repeated types, so arrays of arrays are expressed through these
+// wrappers. The same wrapper is reused for every occurrence of elemType.
+func (c *conversion) listWrapper(elemType string) string {
+	return c.wrapper("repeated "+elemType, wrapperBase(elemType)+"List",
+		&Field{Name: "values", Type: elemType, Number: 1, Repeated: true})
+}
+
+// mapWrapper returns the name of a message wrapping a field of the given
+// map<K, V> type, e.g. message Int32Map { map<string, int32> values = 1; }.
+// Map values and repeated elements can't themselves be maps, so nested maps
+// are expressed through these wrappers.
+func (c *conversion) mapWrapper(mapType string) string {
+	valueType := mapType
+	if i := strings.Index(mapType, ","); i >= 0 {
+		valueType = strings.TrimSuffix(strings.TrimSpace(mapType[i+1:]), ">")
+	}
+	return c.wrapper(mapType, wrapperBase(valueType)+"Map",
+		&Field{Name: "values", Type: mapType, Number: 1})
+}
+
+// wrapper returns the name of the message holding field, generated on first
+// use of key. The name is base unless a generated type, a definition or the
+// root message has it, in which case a number is appended.
+func (c *conversion) wrapper(key, base string, field *Field) string {
+	if name, ok := c.wrappers[key]; ok {
+		return name
+	}
+	name := base
+	for i := 2; c.reservedTypes[name] || c.messages[name] != nil || c.enums[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	c.wrappers[key] = name
+	c.messages[name] = &Message{Name: name, Fields: []*Field{field}}
+	return name
+}
+
+// wrapperBase returns the PascalCase name of the last segment of a type name,
+// e.g. google.protobuf.Timestamp becomes Timestamp
+func wrapperBase(typeName string) string {
+	if i := strings.LastIndex(typeName, "."); i >= 0 {
+		typeName = typeName[i+1:]
+	}
+	return toProtoMessageName(typeName)
+}
+
+// wrapNested returns the name of a type usable as a map value or repeated
+// element for ft, wrapping repeated and map types in a message since proto
+// can't nest them directly
+func (c *conversion) wrapNested(ft fieldType) string {
+	switch {
+	case ft.repeated:
+		return c.listWrapper(ft.name)
+	case strings.HasPrefix(ft.name, "map<"):
+		return c.mapWrapper(ft.name)
+	}
+	return ft.name
+}